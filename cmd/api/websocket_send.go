@@ -0,0 +1,68 @@
+package libaws
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["websocket-send"] = websocketSend
+	lib.Args["websocket-send"] = websocketSendArgs{}
+}
+
+type websocketSendArgs struct {
+	Domain       string   `arg:"positional,required" help:"websocket domain, like: api.example.com or ID.execute-api.REGION.amazonaws.com/$default"`
+	Data         string   `arg:"positional" help:"data to send, read from stdin if empty"`
+	ConnectionID []string `arg:"-c,--connection-id,separate" help:"send to this connection id"`
+	Table        string   `arg:"-t,--table" help:"dynamodb connection table, broadcast to a channel"`
+	Channel      string   `arg:"--channel" help:"channel to broadcast to, default: $all"`
+	User         string   `arg:"-u,--user" help:"broadcast to every connection of this user id"`
+	Concurrency  int      `arg:"--concurrency" default:"32"`
+}
+
+func (websocketSendArgs) Description() string {
+	return "\nsend data to websocket connections by id, or broadcast to a channel in a connection table\n"
+}
+
+func websocketSend() {
+	var args websocketSendArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	data := []byte(args.Data)
+	if args.Data == "" {
+		var err error
+		data, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+	}
+	if len(args.ConnectionID) == 0 && args.Table == "" {
+		lib.Logger.Fatal("error: one of --connection-id or --table is required")
+	}
+	if args.User != "" && args.Channel != "" {
+		lib.Logger.Fatal("error: only one of --user or --channel can be used")
+	}
+	for _, connectionID := range args.ConnectionID {
+		err := lib.ApiWebsocketSend(ctx, args.Domain, connectionID, data)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+	}
+	if args.Table != "" {
+		channel := args.Channel
+		if args.User != "" {
+			channel = lib.WebsocketUserChannel(args.User)
+		} else if channel == "" {
+			channel = lib.WebsocketChannelAll
+		}
+		result, err := lib.WebsocketBroadcast(ctx, args.Table, args.Domain, channel, data, args.Concurrency)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		lib.Logger.Println("sent:", result.Sent, "gone:", result.Gone)
+	}
+}
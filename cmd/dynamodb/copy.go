@@ -0,0 +1,49 @@
+package libaws
+
+import (
+	"context"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["dynamodb-copy"] = dynamodbCopy
+	lib.Args["dynamodb-copy"] = dynamodbCopyArgs{}
+}
+
+type dynamodbCopyArgs struct {
+	Source            string `arg:"positional,required"`
+	Destination       string `arg:"positional,required"`
+	SourceRegion      string `arg:"--src-region" help:"default: current region"`
+	DestinationRegion string `arg:"--dst-region" help:"default: current region"`
+	Segments          int    `arg:"-n,--segments" default:"1" help:"parallel scan segments and writers"`
+}
+
+func (dynamodbCopyArgs) Description() string {
+	return `
+copy all items from one dynamodb table to another, which must already exist
+
+example:
+ - libaws dynamodb-copy prod-table staging-table --segments 8
+ - libaws dynamodb-copy test-table test-table --src-region us-west-2 --dst-region us-east-1
+
+`
+}
+
+func dynamodbCopy() {
+	var args dynamodbCopyArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	count, err := lib.DynamoDBCopy(ctx, &lib.DynamoDBCopyInput{
+		Source:            args.Source,
+		SourceRegion:      args.SourceRegion,
+		Destination:       args.Destination,
+		DestinationRegion: args.DestinationRegion,
+		Segments:          args.Segments,
+	})
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	lib.Logger.Println("dynamodb copied:", args.Source, args.Destination, count)
+}
@@ -47,4 +47,11 @@ func dynamodbDescribe() {
 		}
 		fmt.Println(strings.ToLower(strings.Join(vals, ":")))
 	}
+	settings, err := lib.DynamoDBDescribeSettings(ctx, args.Table)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	for _, attr := range settings.Attrs(true) {
+		fmt.Println(attr)
+	}
 }
@@ -56,6 +56,13 @@ optional attrs:
  - GlobalSecondaryIndexes.INTEGER.ProvisionedThroughput.WriteCapacityUnits=VALUE
 
  - ttl=ATTR_NAME
+
+ - DeletionProtectionEnabled=VALUE,  shortcut: deletion-protection=VALUE, values: true | false
+ - TableClass=VALUE,                 shortcut: class=VALUE,               values: standard | standard_infrequent_access
+ - PointInTimeRecoveryEnabled=VALUE, shortcut: pitr=VALUE,                values: true | false
+ - ContributorInsightsEnabled=VALUE, shortcut: insights=VALUE,            values: true | false
+
+ these settings are left unchanged when not specified, set them to false to disable them
 `
 }
 
@@ -72,11 +79,11 @@ func dynamodbEnsure() {
 			keys = append(keys, param)
 		}
 	}
-	input, ttl, err := lib.DynamoDBEnsureInput("", args.Name, keys, attrs)
+	input, options, err := lib.DynamoDBEnsureInput("", args.Name, keys, attrs)
 	if err != nil {
 		lib.Logger.Fatal("error: ", err)
 	}
-	err = lib.DynamoDBEnsure(ctx, input, ttl, args.Preview)
+	err = lib.DynamoDBEnsure(ctx, input, options, args.Preview)
 	if err != nil {
 		lib.Logger.Fatal("error: ", err)
 	}
@@ -0,0 +1,67 @@
+package libaws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["dynamodb-export"] = dynamodbExport
+	lib.Args["dynamodb-export"] = dynamodbExportArgs{}
+}
+
+type dynamodbExportArgs struct {
+	Table    string `arg:"positional,required"`
+	Segments int    `arg:"-n,--segments" default:"1" help:"parallel scan segments"`
+	S3       string `arg:"-s,--s3" help:"native export to s3://bucket/prefix instead of json lines on stdout"`
+	NoWait   bool   `arg:"--no-wait" help:"do not wait for native export to finish"`
+}
+
+func (dynamodbExportArgs) Description() string {
+	return `
+export dynamodb table
+
+by default scan the table and print items as json lines, which dynamodb-import reads
+
+with --s3 start a native export of dynamodb json to s3, which needs point-in-time recovery enabled on the table
+
+example:
+ - libaws dynamodb-export test-table --segments 8 > items.jsonl
+ - libaws dynamodb-export test-table --s3 s3://test-bucket/exports
+
+`
+}
+
+func dynamodbExport() {
+	var args dynamodbExportArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	if args.S3 != "" {
+		bucket, prefix, _ := strings.Cut(strings.TrimPrefix(args.S3, "s3://"), "/")
+		export, err := lib.DynamoDBExportToS3(ctx, args.Table, bucket, prefix, args.NoWait)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		fmt.Println(aws.ToString(export.ExportArn), export.ExportStatus, aws.ToString(export.ExportManifest))
+		return
+	}
+	err := lib.DynamoDBScan(ctx, &lib.DynamoDBScanInput{
+		TableName: args.Table,
+		Segments:  args.Segments,
+	}, func(item map[string]ddbtypes.AttributeValue) {
+		bytes, err := lib.DynamoDBItemToJSON(item)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		fmt.Println(string(bytes))
+	})
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
@@ -0,0 +1,70 @@
+package libaws
+
+import (
+	"bufio"
+	"context"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["dynamodb-import"] = dynamodbImport
+	lib.Args["dynamodb-import"] = dynamodbImportArgs{}
+}
+
+type dynamodbImportArgs struct {
+	Table string `arg:"positional,required"`
+}
+
+func (dynamodbImportArgs) Description() string {
+	return `
+import json lines from stdin into dynamodb table
+
+strings, numbers and bools are stored as s, n and b like dynamodb-item-put, lists and objects as l and m
+
+example:
+ - libaws dynamodb-export prod-table | libaws dynamodb-import staging-table
+
+`
+}
+
+func dynamodbImport() {
+	var args dynamodbImportArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	var items []map[string]ddbtypes.AttributeValue
+	count := 0
+	flush := func() {
+		err := lib.DynamoDBBatchPut(ctx, "", args.Table, items)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		count += len(items)
+		items = nil
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // max item size is 400KB
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		item, err := lib.DynamoDBItemFromJSON(line)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		items = append(items, item)
+		if len(items) == 1000 {
+			flush()
+		}
+	}
+	err := scanner.Err()
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	flush()
+	lib.Logger.Println("dynamodb imported:", args.Table, count)
+}
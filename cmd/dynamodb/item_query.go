@@ -0,0 +1,104 @@
+package libaws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["dynamodb-item-query"] = dynamodbItemQuery
+	lib.Args["dynamodb-item-query"] = dynamodbItemQueryArgs{}
+}
+
+type dynamodbItemQueryArgs struct {
+	Table      string   `arg:"positional,required"`
+	Conditions []string `arg:"positional,required" help:"partition key condition, then an optional sort key condition"`
+	Index      string   `arg:"-i,--index" help:"query a global or local secondary index"`
+	Reverse    bool     `arg:"-r,--reverse" help:"descending sort key order"`
+	Limit      int      `arg:"-l,--limit" default:"0" help:"stop after this many items and print the start key of the next page to stderr"`
+	Start      []string `arg:"-s,--start,separate" help:"exclusive start key of a page, like: user:s:john"`
+}
+
+func (dynamodbItemQueryArgs) Description() string {
+	return `
+query dynamodb table, printing items as json lines
+
+describe the partition key like: $name:s|n:$value
+
+describe the optional sort key like:
+ - $name:s|n:$value
+ - $name:s|n:OPERATOR:$value, with operator: = | < | <= | > | >= | begins_with
+ - $name:s|n:between:$low:$high
+
+example:
+ - libaws dynamodb-item-query test-table user:s:john
+ - libaws dynamodb-item-query test-table user:s:john date:n:between:100:200 --reverse
+ - libaws dynamodb-item-query test-table hometown:s:austin --index hometown-index --limit 10
+ - libaws dynamodb-item-query test-table user:s:john --limit 10 --start user:s:john --start date:n:150
+
+`
+}
+
+func dynamodbItemQuery() {
+	var args dynamodbItemQueryArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	input, err := lib.DynamoDBQueryInput(args.Table, args.Index, args.Conditions)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	input.ScanIndexForward = aws.Bool(!args.Reverse)
+	if len(args.Start) != 0 {
+		input.ExclusiveStartKey = map[string]ddbtypes.AttributeValue{}
+		for _, key := range args.Start {
+			name, av, err := lib.DynamoDBKeyValue(key)
+			if err != nil {
+				lib.Logger.Fatal("error: ", err)
+			}
+			input.ExclusiveStartKey[name] = av
+		}
+	}
+	count := 0
+	for {
+		if args.Limit != 0 {
+			input.Limit = aws.Int32(int32(args.Limit - count))
+		}
+		out, err := lib.DynamoDBClient().Query(ctx, input)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		for _, item := range out.Items {
+			bytes, err := lib.DynamoDBItemToJSON(item)
+			if err != nil {
+				lib.Logger.Fatal("error: ", err)
+			}
+			fmt.Println(string(bytes))
+		}
+		count += len(out.Items)
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		if args.Limit != 0 && count >= args.Limit {
+			var start []string
+			for name, av := range out.LastEvaluatedKey {
+				key, err := lib.DynamoDBKeyString(name, av)
+				if err != nil {
+					lib.Logger.Fatal("error: ", err)
+				}
+				start = append(start, "--start "+key)
+			}
+			sort.Strings(start)
+			fmt.Fprintln(os.Stderr, "next page:", strings.Join(start, " "))
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
@@ -2,13 +2,9 @@ package libaws
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"github.com/alexflint/go-arg"
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/nathants/libaws/lib"
 )
@@ -19,48 +15,51 @@ func init() {
 }
 
 type dynamodbItemScanArgs struct {
-	Table string `arg:"positional"`
-	Limit int    `arg:"-l,--limit" default:"0"`
+	Table      string   `arg:"positional"`
+	Limit      int      `arg:"-l,--limit" default:"0"`
+	Segments   int      `arg:"-n,--segments" default:"1" help:"parallel scan segments"`
+	Attributes []string `arg:"-a,--attr,separate" help:"only include these attributes"`
+	Filters    []string `arg:"-f,--filter,separate" help:"only include items matching all filters"`
+	Consistent bool     `arg:"-c,--consistent" help:"strongly consistent reads"`
 }
 
 func (dynamodbItemScanArgs) Description() string {
-	return "\nscan dynamodb table\n"
+	return `
+scan dynamodb table, printing items as json lines
+
+with more than one segment, items are printed in no particular order
+
+describe filters like:
+ - $name:s|n:$value
+ - $name:s|n:OPERATOR:$value, with operator: = | <> | < | <= | > | >= | begins_with | contains
+ - $name:s|n:between:$low:$high
+
+example:
+ - libaws dynamodb-item-scan test-table --segments 8 > items.jsonl
+ - libaws dynamodb-item-scan test-table -f age:n:>:30 -f name:s:begins_with:jo -a name -a age
+
+`
 }
 
 func dynamodbItemScan() {
 	var args dynamodbItemScanArgs
 	arg.MustParse(&args)
 	ctx := context.Background()
-	var start map[string]ddbtypes.AttributeValue
-	count := 0
-	for {
-		out, err := lib.DynamoDBClient().Scan(ctx, &dynamodb.ScanInput{
-			TableName:         aws.String(args.Table),
-			ExclusiveStartKey: start,
-			Limit:             aws.Int32(1000),
-		})
+	err := lib.DynamoDBScan(ctx, &lib.DynamoDBScanInput{
+		TableName:      args.Table,
+		Segments:       args.Segments,
+		Attributes:     args.Attributes,
+		Filters:        args.Filters,
+		ConsistentRead: args.Consistent,
+		Limit:          args.Limit,
+	}, func(item map[string]ddbtypes.AttributeValue) {
+		bytes, err := lib.DynamoDBItemToJSON(item)
 		if err != nil {
-			panic(err)
-		}
-		for _, item := range out.Items {
-			if args.Limit != 0 && args.Limit < count {
-				break
-			}
-			count++
-			val := map[string]any{}
-			err = attributevalue.UnmarshalMap(item, &val)
-			if err != nil {
-				panic(err)
-			}
-			bytes, err := json.Marshal(val)
-			if err != nil {
-				panic(err)
-			}
-			fmt.Println(string(bytes))
-		}
-		if out.LastEvaluatedKey == nil {
-			break
+			lib.Logger.Fatal("error: ", err)
 		}
-		start = out.LastEvaluatedKey
+		fmt.Println(string(bytes))
+	})
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
 	}
 }
@@ -0,0 +1,88 @@
+package libaws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["dynamodb-item-update"] = dynamodbItemUpdate
+	lib.Args["dynamodb-item-update"] = dynamodbItemUpdateArgs{}
+}
+
+type dynamodbItemUpdateArgs struct {
+	Table     string   `arg:"positional,required"`
+	Keys      []string `arg:"positional,required"`
+	Set       []string `arg:"-s,--set,separate" help:"set attribute like name:s|n|b:value"`
+	Remove    []string `arg:"-r,--remove,separate" help:"remove attribute by name"`
+	Add       []string `arg:"-a,--add,separate" help:"add to number attribute like visits:n:1"`
+	If        []string `arg:"-i,--if,separate" help:"only update if condition like version:n:3 or age:n:<:30"`
+	Exists    []string `arg:"-e,--exists,separate" help:"only update if attribute exists"`
+	NotExists []string `arg:"-x,--not-exists,separate" help:"only update if attribute does not exist"`
+	Return    string   `arg:"--return" default:"all_new" help:"none | all_old | updated_old | all_new | updated_new"`
+}
+
+func (dynamodbItemUpdateArgs) Description() string {
+	return `
+update item, printing the returned values as json
+
+describe keys like: $name:s|n:$value
+
+describe conditions like:
+ - $name:s|n:$value
+ - $name:s|n:OPERATOR:$value, with operator: = | <> | < | <= | > | >= | begins_with | contains
+ - $name:s|n:between:$low:$high
+
+example:
+ - libaws dynamodb-item-update test-table user:s:john --set name:s:jane --remove nickname --add visits:n:1
+ - libaws dynamodb-item-update test-table user:s:john --set email:s:j@example.com --add version:n:1 --if version:n:3
+ - libaws dynamodb-item-update test-table user:s:john --set active:b:true --exists user --return all_old
+
+`
+}
+
+func dynamodbItemUpdate() {
+	var args dynamodbItemUpdateArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	returnValues := ddbtypes.ReturnValue(strings.ToUpper(strings.ReplaceAll(args.Return, "-", "_")))
+	if !slices.Contains(returnValues.Values(), returnValues) {
+		lib.Logger.Fatal("error: ", fmt.Errorf("unknown return values: %s", args.Return))
+	}
+	input, err := lib.DynamoDBUpdateItemInput(&lib.DynamoDBUpdateInput{
+		TableName:    args.Table,
+		Keys:         args.Keys,
+		Set:          args.Set,
+		Remove:       args.Remove,
+		Add:          args.Add,
+		Conditions:   args.If,
+		Exists:       args.Exists,
+		NotExists:    args.NotExists,
+		ReturnValues: returnValues,
+	})
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	out, err := lib.DynamoDBClient().UpdateItem(ctx, input)
+	if err != nil {
+		if lib.DynamoDBConditionFailed(err) {
+			lib.Logger.Fatal("error: condition failed: ", strings.Join(append(append(args.If, args.Exists...), args.NotExists...), " "))
+		}
+		lib.Logger.Fatal("error: ", err)
+	}
+	if len(out.Attributes) != 0 {
+		// the update already succeeded, so only warn when attributes can't be printed
+		bytes, err := lib.DynamoDBItemToJSON(out.Attributes)
+		if err != nil {
+			lib.Logger.Println("warning: updated item but could not print attributes:", err)
+			return
+		}
+		fmt.Println(string(bytes))
+	}
+}
@@ -0,0 +1,48 @@
+package libaws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["events-ensure-rule"] = eventsEnsureRule
+	lib.Args["events-ensure-rule"] = eventsEnsureRuleArgs{}
+}
+
+type eventsEnsureRuleArgs struct {
+	RuleName  string `arg:"positional,required"`
+	Pattern   string `arg:"positional,required" help:"json event pattern"`
+	TargetArn string `arg:"positional,required"`
+	BusName   string `arg:"-b,--bus" default:"default"`
+	Preview   bool   `arg:"-p,--preview"`
+}
+
+func (eventsEnsureRuleArgs) Description() string {
+	return `
+ensure an event rule with a single target
+
+the target must allow events.amazonaws.com, for lambda targets prefer an event trigger
+
+example:
+ - libaws events-ensure-rule paid-orders '{"source": ["com.example.orders"]}' arn:aws:sqs:us-west-2:123456789012:paid-orders --bus orders
+
+`
+}
+
+func eventsEnsureRule() {
+	var args eventsEnsureRuleArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	if !json.Valid([]byte(args.Pattern)) {
+		lib.Logger.Fatal("error: ", fmt.Errorf("event pattern should be json, got: %s", args.Pattern))
+	}
+	_, err := lib.EventsEnsureRule(ctx, "", args.BusName, args.RuleName, args.Pattern, args.TargetArn, args.Preview)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
@@ -0,0 +1,70 @@
+package libaws
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["events-put"] = eventsPut
+	lib.Args["events-put"] = eventsPutArgs{}
+}
+
+type eventsPutArgs struct {
+	Source     string   `arg:"positional,required"`
+	DetailType string   `arg:"positional,required"`
+	Detail     []string `arg:"positional" help:"json detail of each event, read one per line from stdin if none"`
+	BusName    string   `arg:"-b,--bus" default:"default"`
+}
+
+func (eventsPutArgs) Description() string {
+	return `
+put events to a bus
+
+example:
+ - libaws events-put com.example.orders 'Order Paid' '{"id": "123"}' --bus orders
+ - cat events.jsonl | libaws events-put com.example.orders 'Order Paid'
+
+`
+}
+
+func eventsPut() {
+	var args eventsPutArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	details := args.Detail
+	if len(details) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 256*1024), 256*1024) // max event size
+		for scanner.Scan() {
+			if scanner.Text() != "" {
+				details = append(details, scanner.Text())
+			}
+		}
+		err := scanner.Err()
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+	}
+	var entries []lib.EventsPutEntry
+	for _, detail := range details {
+		if !json.Valid([]byte(detail)) {
+			lib.Logger.Fatal("error: ", fmt.Errorf("event detail should be json, got: %s", detail))
+		}
+		entries = append(entries, lib.EventsPutEntry{
+			Source:     args.Source,
+			DetailType: args.DetailType,
+			Detail:     detail,
+		})
+	}
+	err := lib.EventsPut(ctx, args.BusName, entries)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
@@ -0,0 +1,53 @@
+package libaws
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["events-replay"] = eventsReplay
+	lib.Args["events-replay"] = eventsReplayArgs{}
+}
+
+type eventsReplayArgs struct {
+	BusName string `arg:"positional,required" help:"bus whose archive is replayed"`
+	Start   string `arg:"-s,--start,required" help:"start of the time window, like: 2030-01-01T00:00:00Z"`
+	End     string `arg:"-e,--end" help:"end of the time window, default: now"`
+}
+
+func (eventsReplayArgs) Description() string {
+	return `
+replay a time window of archived events back into their bus and wait for completion
+
+the bus must have been archived with event-bus attr: archive=true
+
+example:
+ - libaws events-replay orders --start 2030-01-01T00:00:00Z --end 2030-01-02T00:00:00Z
+
+`
+}
+
+func eventsReplay() {
+	var args eventsReplayArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	start, err := time.Parse(time.RFC3339, args.Start)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	end := time.Now().UTC()
+	if args.End != "" {
+		end, err = time.Parse(time.RFC3339, args.End)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+	}
+	err = lib.EventsReplay(ctx, lib.EventsArchiveName(args.BusName), args.BusName, start, end)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
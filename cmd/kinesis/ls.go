@@ -0,0 +1,34 @@
+package libaws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["kinesis-ls"] = kinesisLs
+	lib.Args["kinesis-ls"] = kinesisLsArgs{}
+}
+
+type kinesisLsArgs struct {
+}
+
+func (kinesisLsArgs) Description() string {
+	return "\nlist kinesis streams\n"
+}
+
+func kinesisLs() {
+	var args kinesisLsArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	names, err := lib.KinesisListStreams(ctx)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+}
@@ -0,0 +1,68 @@
+package libaws
+
+import (
+	"bufio"
+	"context"
+	"os"
+
+	"github.com/alexflint/go-arg"
+	"github.com/gofrs/uuid"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["kinesis-put"] = kinesisPut
+	lib.Args["kinesis-put"] = kinesisPutArgs{}
+}
+
+type kinesisPutArgs struct {
+	Name         string   `arg:"positional,required"`
+	Data         []string `arg:"positional" help:"data of each record, read one per line from stdin if none"`
+	PartitionKey string   `arg:"-k,--partition-key" help:"partition key of every record, default: a random key per record"`
+}
+
+func (kinesisPutArgs) Description() string {
+	return `
+put records to a kinesis stream
+
+example:
+ - libaws kinesis-put telemetry '{"id": "123"}' -k device-123
+ - cat records.jsonl | libaws kinesis-put telemetry
+
+`
+}
+
+func kinesisPut() {
+	var args kinesisPutArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	data := args.Data
+	if len(data) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // max record size
+		for scanner.Scan() {
+			if scanner.Text() != "" {
+				data = append(data, scanner.Text())
+			}
+		}
+		err := scanner.Err()
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+	}
+	var entries []lib.KinesisPutEntry
+	for _, d := range data {
+		partitionKey := args.PartitionKey
+		if partitionKey == "" {
+			partitionKey = uuid.Must(uuid.NewV4()).String()
+		}
+		entries = append(entries, lib.KinesisPutEntry{
+			PartitionKey: partitionKey,
+			Data:         []byte(d),
+		})
+	}
+	err := lib.KinesisPut(ctx, args.Name, entries)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
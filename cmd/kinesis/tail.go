@@ -0,0 +1,83 @@
+package libaws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexflint/go-arg"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	kinesistypes "github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["kinesis-tail"] = kinesisTail
+	lib.Args["kinesis-tail"] = kinesisTailArgs{}
+}
+
+type kinesisTailArgs struct {
+	Name    string `arg:"positional,required"`
+	Start   string `arg:"-s,--start" default:"latest" help:"latest, trim_horizon, or an RFC3339 timestamp"`
+	Verbose bool   `arg:"-v,--verbose" help:"print json with shard, sequence number, partition key and arrival time"`
+}
+
+func (kinesisTailArgs) Description() string {
+	return `
+tail records from every shard of a kinesis stream
+
+example:
+ - libaws kinesis-tail telemetry
+ - libaws kinesis-tail telemetry --start trim_horizon
+ - libaws kinesis-tail telemetry --start 2024-01-01T00:00:00Z -v
+
+`
+}
+
+type kinesisTailRecord struct {
+	ShardID        string    `json:"shard_id"`
+	SequenceNumber string    `json:"sequence_number"`
+	PartitionKey   string    `json:"partition_key"`
+	ArrivalTime    time.Time `json:"arrival_time"`
+	Data           string    `json:"data"`
+}
+
+func kinesisTail() {
+	var args kinesisTailArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	var timestamp *time.Time
+	iteratorType := kinesistypes.ShardIteratorType(strings.ToUpper(args.Start))
+	switch iteratorType {
+	case kinesistypes.ShardIteratorTypeLatest, kinesistypes.ShardIteratorTypeTrimHorizon:
+	default:
+		t, err := time.Parse(time.RFC3339, args.Start)
+		if err != nil {
+			lib.Logger.Fatal("error: ", fmt.Errorf("--start should be latest, trim_horizon or an RFC3339 timestamp, got: %s", args.Start))
+		}
+		iteratorType = kinesistypes.ShardIteratorTypeAtTimestamp
+		timestamp = &t
+	}
+	err := lib.KinesisTail(ctx, args.Name, iteratorType, timestamp, func(shardID string, record kinesistypes.Record) {
+		if !args.Verbose {
+			fmt.Println(string(record.Data))
+			return
+		}
+		bytes, err := json.Marshal(kinesisTailRecord{
+			ShardID:        shardID,
+			SequenceNumber: aws.ToString(record.SequenceNumber),
+			PartitionKey:   aws.ToString(record.PartitionKey),
+			ArrivalTime:    aws.ToTime(record.ApproximateArrivalTimestamp),
+			Data:           string(record.Data),
+		})
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		fmt.Println(string(bytes))
+	})
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
@@ -0,0 +1,89 @@
+package libaws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["lambda-url-curl"] = lambdaUrlCurl
+	lib.Args["lambda-url-curl"] = lambdaUrlCurlArgs{}
+}
+
+type lambdaUrlCurlArgs struct {
+	Name    string   `arg:"positional,required" help:"lambda name or function url"`
+	Path    string   `arg:"positional" default:"/"`
+	Method  string   `arg:"-X,--method" default:"GET"`
+	Data    string   `arg:"-d,--data" help:"request body, or @file to read it from a file"`
+	Header  []string `arg:"-H,--header,separate" help:"header as 'Key: value'"`
+	Include bool     `arg:"-i,--include" help:"print the response status and headers to stderr"`
+}
+
+func (lambdaUrlCurlArgs) Description() string {
+	return "\ncurl a lambda function url, signing the request with sigv4 for urls with auth=iam\n"
+}
+
+func lambdaUrlCurl() {
+	var args lambdaUrlCurlArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	url := args.Name
+	if !strings.HasPrefix(url, "https://") {
+		var err error
+		url, err = lib.FuncUrl(ctx, args.Name)
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+	}
+	url = strings.TrimRight(url, "/") + "/" + strings.TrimLeft(args.Path, "/")
+	var body []byte
+	if strings.HasPrefix(args.Data, "@") {
+		var err error
+		body, err = os.ReadFile(strings.TrimPrefix(args.Data, "@"))
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+	} else {
+		body = []byte(args.Data)
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(args.Method), url, bytes.NewReader(body))
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	for _, header := range args.Header {
+		k, v, err := lib.SplitOnce(header, ":")
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		req.Header.Set(strings.TrimSpace(k), strings.TrimSpace(v))
+	}
+	err = lib.LambdaUrlSign(ctx, req, body)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if args.Include {
+		fmt.Fprintln(os.Stderr, resp.Proto, resp.Status)
+		_ = resp.Header.Write(os.Stderr)
+		fmt.Fprintln(os.Stderr)
+	}
+	_, err = io.Copy(os.Stdout, resp.Body)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	if resp.StatusCode >= 400 {
+		os.Exit(1)
+	}
+}
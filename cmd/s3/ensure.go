@@ -32,11 +32,17 @@ optional attrs:
  - cors=VALUE       (values = true | false,     default = false)
  - ttldays=VALUE    (values = 0 | n,            default = 0)
  - allow_put=VALUE  (values = $principal.amazonaws.com)
+ - eventbridge=VALUE (values = true | false,    default = false)
+ - notify=VALUE     (values = sqs:QUEUE | sns:TOPIC, optional prefix=VALUE suffix=VALUE event=VALUE)
 
 setting 'cors=true' uses '*' for allowed origins. to specify one or more explicit origins, do this instead:
  - corsorigin=http://localhost:8080
  - corsorigin=https://example.com
 
+notify sends bucket events to a queue or topic, whose policy is updated to allow the bucket:
+ - notify="sqs:test-queue prefix=uploads/ event=created"
+ - notify="sns:test-topic suffix=.json"
+
 note: bucket acl can only be set at bucket creation time
 
 `
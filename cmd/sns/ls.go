@@ -0,0 +1,34 @@
+package libaws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["sns-ls"] = snsLs
+	lib.Args["sns-ls"] = snsLsArgs{}
+}
+
+type snsLsArgs struct {
+}
+
+func (snsLsArgs) Description() string {
+	return "\nlist sns topics\n"
+}
+
+func snsLs() {
+	var args snsLsArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	topicArns, err := lib.SNSListTopics(ctx)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	for _, topicArn := range topicArns {
+		fmt.Println(lib.SNSArnToName(topicArn))
+	}
+}
@@ -0,0 +1,78 @@
+package libaws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["sns-publish"] = snsPublish
+	lib.Args["sns-publish"] = snsPublishArgs{}
+}
+
+type snsPublishArgs struct {
+	Name    string   `arg:"positional,required"`
+	Message string   `arg:"positional,required"`
+	Subject string   `arg:"-s,--subject"`
+	GroupID string   `arg:"-g,--group-id" help:"required for fifo topics"`
+	DedupID string   `arg:"-d,--dedup-id" help:"for fifo topics without content-dedup"`
+	Attr    []string `arg:"-a,--attr,separate" help:"message attribute key=value, can be used multiple times"`
+}
+
+func (snsPublishArgs) Description() string {
+	return `
+publish a message to a sns topic
+
+example:
+ - libaws sns-publish orders '{"id": "123"}' -a type=paid
+ - libaws sns-publish orders.fifo '{"id": "123"}' -g 123
+
+`
+}
+
+func snsPublish() {
+	var args snsPublishArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	topicArn, err := lib.SNSArn(ctx, args.Name)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	input := &sns.PublishInput{
+		TopicArn: aws.String(topicArn),
+		Message:  aws.String(args.Message),
+	}
+	if args.Subject != "" {
+		input.Subject = aws.String(args.Subject)
+	}
+	if args.GroupID != "" {
+		input.MessageGroupId = aws.String(args.GroupID)
+	}
+	if args.DedupID != "" {
+		input.MessageDeduplicationId = aws.String(args.DedupID)
+	}
+	for _, attr := range args.Attr {
+		k, v, err := lib.SplitOnce(attr, "=")
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		if input.MessageAttributes == nil {
+			input.MessageAttributes = map[string]snstypes.MessageAttributeValue{}
+		}
+		input.MessageAttributes[k] = snstypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(v),
+		}
+	}
+	out, err := lib.SNSClient().Publish(ctx, input)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	fmt.Println(*out.MessageId)
+}
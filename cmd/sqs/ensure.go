@@ -31,6 +31,12 @@ optional attrs:
  - MessageRetentionPeriod=VALUE,        shortcut: retention=VALUE  default: 345600
  - ReceiveMessageWaitTimeSeconds=VALUE, shortcut: wait=VALUE       default: 0
  - VisibilityTimeout=VALUE,             shortcut: timeout=VALUE    default: 30
+ - ContentBasedDeduplication=VALUE,     shortcut: contentdedup=VALUE default: false, fifo only
+ - highthroughput=VALUE,                                           default: false, fifo only
+ - DeadLetterQueue=VALUE,               shortcut: dlq=VALUE        default: none
+ - MaxReceiveCount=VALUE,               shortcut: maxreceive=VALUE default: 5
+
+queue names ending in .fifo are fifo queues. a dead-letter queue must be the same type as its source queue.
 
 `
 }
@@ -0,0 +1,119 @@
+package libaws
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["sqs-receive"] = sqsReceive
+	lib.Args["sqs-receive"] = sqsReceiveArgs{}
+}
+
+type sqsReceiveArgs struct {
+	Name       string `arg:"positional,required"`
+	Count      int    `arg:"-n,--count" help:"stop after this many messages, default: until the queue is empty"`
+	Follow     bool   `arg:"-f,--follow" help:"keep polling after the queue is empty"`
+	Delete     bool   `arg:"-d,--delete" help:"delete messages after printing them"`
+	Timeout    int    `arg:"-t,--timeout" help:"visibility timeout seconds, default: the queue visibility timeout"`
+	Wait       int    `arg:"-w,--wait" default:"20" help:"long polling wait seconds"`
+	Attributes bool   `arg:"-a,--attributes" help:"include system and message attributes"`
+	Json       bool   `arg:"-j,--json" help:"print one json object per message"`
+}
+
+func (sqsReceiveArgs) Description() string {
+	return `
+receive messages from a sqs queue, printing the body of each message
+
+messages not deleted become visible again after the visibility timeout
+
+example:
+ - libaws sqs-receive test-queue
+ - libaws sqs-receive test-queue -n 1 -a
+ - libaws sqs-receive test-queue --delete --json > messages.jsonl
+
+`
+}
+
+type sqsReceiveMessage struct {
+	MessageID         string            `json:"message_id"`
+	Body              string            `json:"body"`
+	Attributes        map[string]string `json:"attributes,omitempty"`
+	MessageAttributes map[string]string `json:"message_attributes,omitempty"`
+}
+
+func sqsReceive() {
+	var args sqsReceiveArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	url, err := lib.SQSQueueUrl(ctx, args.Name)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	received := 0
+	for args.Count == 0 || received < args.Count {
+		maxMessages := 10
+		if args.Count != 0 {
+			maxMessages = min(maxMessages, args.Count-received)
+		}
+		messages, err := lib.SQSReceive(ctx, &lib.SQSReceiveInput{
+			QueueUrl:          url,
+			MaxMessages:       maxMessages,
+			WaitSeconds:       args.Wait,
+			VisibilityTimeout: args.Timeout,
+			Attributes:        args.Attributes,
+		})
+		if err != nil {
+			lib.Logger.Fatal("error: ", err)
+		}
+		if len(messages) == 0 {
+			if args.Follow {
+				continue
+			}
+			break
+		}
+		for _, message := range messages {
+			if !args.Attributes && !args.Json {
+				fmt.Println(aws.ToString(message.Body))
+				continue
+			}
+			m := sqsReceiveMessage{
+				MessageID:  aws.ToString(message.MessageId),
+				Body:       aws.ToString(message.Body),
+				Attributes: message.Attributes,
+			}
+			for k, v := range message.MessageAttributes {
+				if m.MessageAttributes == nil {
+					m.MessageAttributes = map[string]string{}
+				}
+				if v.StringValue != nil {
+					m.MessageAttributes[k] = *v.StringValue
+				} else {
+					m.MessageAttributes[k] = base64.StdEncoding.EncodeToString(v.BinaryValue)
+				}
+			}
+			if !args.Json {
+				fmt.Println(lib.Pformat(m))
+				continue
+			}
+			bytes, err := json.Marshal(m)
+			if err != nil {
+				lib.Logger.Fatal("error: ", err)
+			}
+			fmt.Println(string(bytes))
+		}
+		received += len(messages)
+		if args.Delete {
+			err := lib.SQSDeleteMessages(ctx, url, messages)
+			if err != nil {
+				lib.Logger.Fatal("error: ", err)
+			}
+		}
+	}
+}
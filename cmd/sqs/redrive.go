@@ -0,0 +1,63 @@
+package libaws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["sqs-redrive"] = sqsRedrive
+	lib.Args["sqs-redrive"] = sqsRedriveArgs{}
+}
+
+type sqsRedriveArgs struct {
+	Source      string `arg:"positional,required"`
+	Destination string `arg:"positional,required"`
+	Contains    string `arg:"-c,--contains" help:"only move messages whose body contains this string"`
+	Match       string `arg:"-m,--match" help:"only move messages whose json body has a value at a path, like: .detail.type=order"`
+	Rate        int    `arg:"-r,--rate" help:"maximum messages moved per second, default: unlimited"`
+	Timeout     int    `arg:"-t,--timeout" default:"300" help:"seconds that skipped messages stay hidden while redriving"`
+}
+
+func (sqsRedriveArgs) Description() string {
+	return `
+move messages from one sqs queue to another, usually from a dead-letter queue back to its source
+
+without filters this uses a sqs message move task, which requires the source to be a dead-letter queue, and otherwise receives, sends and deletes messages
+
+example:
+ - libaws sqs-redrive test-queue-dlq test-queue
+ - libaws sqs-redrive test-queue-dlq test-queue --rate 10
+ - libaws sqs-redrive test-queue-dlq test-queue --match .detail.type=order
+ - libaws sqs-redrive test-queue-dlq test-queue --contains customer-123
+
+`
+}
+
+func sqsRedrive() {
+	var args sqsRedriveArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	input := &lib.SQSRedriveInput{
+		Source:            args.Source,
+		Destination:       args.Destination,
+		Contains:          args.Contains,
+		Rate:              args.Rate,
+		VisibilityTimeout: args.Timeout,
+	}
+	if args.Match != "" {
+		path, value, err := lib.SplitOnce(args.Match, "=")
+		if err != nil {
+			lib.Logger.Fatal("error: ", fmt.Errorf("--match should be like .path.to.key=value, got: %s", args.Match))
+		}
+		input.MatchPath = path
+		input.MatchValue = value
+	}
+	err := lib.SQSRedrive(ctx, input)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
@@ -0,0 +1,88 @@
+package libaws
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alexflint/go-arg"
+	"github.com/nathants/libaws/lib"
+)
+
+func init() {
+	lib.Commands["sqs-send-batch"] = sqsSendBatch
+	lib.Args["sqs-send-batch"] = sqsSendBatchArgs{}
+}
+
+type sqsSendBatchArgs struct {
+	Name    string `arg:"positional,required"`
+	GroupID string `arg:"-g,--group-id" help:"message group id, required for fifo queues"`
+}
+
+func (sqsSendBatchArgs) Description() string {
+	return `
+send json lines from stdin to a sqs queue, each line as one message, in batches of up to ten messages and 256KiB
+
+batches are sent as stdin is read
+
+for fifo queues the deduplication id of each message is the sha256 of its body
+
+example:
+ - cat messages.jsonl | libaws sqs-send-batch test-queue
+ - cat messages.jsonl | libaws sqs-send-batch test-queue.fifo -g backfill
+
+`
+}
+
+func sqsSendBatch() {
+	var args sqsSendBatchArgs
+	arg.MustParse(&args)
+	ctx := context.Background()
+	fifo := strings.HasSuffix(args.Name, ".fifo")
+	if fifo && args.GroupID == "" {
+		lib.Logger.Fatal("error: ", fmt.Errorf("--group-id is required for fifo queues"))
+	}
+	url, err := lib.SQSQueueUrl(ctx, args.Name)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	var entries []lib.SQSSendEntry
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // max message size
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if !json.Valid([]byte(line)) {
+			lib.Logger.Fatal("error: ", fmt.Errorf("invalid json line: %s", line))
+		}
+		entry := lib.SQSSendEntry{Body: line}
+		if fifo {
+			sum := sha256.Sum256([]byte(line))
+			entry.GroupID = args.GroupID
+			entry.DeduplicationID = hex.EncodeToString(sum[:])
+		}
+		entries = append(entries, entry)
+		if len(entries) == lib.SQSBatchSize {
+			err := lib.SQSSendBatch(ctx, url, entries)
+			if err != nil {
+				lib.Logger.Fatal("error: ", err)
+			}
+			entries = nil
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+	err = lib.SQSSendBatch(ctx, url, entries)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
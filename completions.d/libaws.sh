@@ -52,6 +52,11 @@ _libaws () {
         elif [ ${COMP_WORDS[1]} = dynamodb-item-rm-all   ]; then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
         elif [ ${COMP_WORDS[1]} = dynamodb-item-put  ]; then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
         elif [ ${COMP_WORDS[1]} = dynamodb-item-get  ]; then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = dynamodb-item-query ]; then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = dynamodb-item-update ]; then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = dynamodb-export ]; then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = dynamodb-import ]; then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = dynamodb-copy ]; then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
 
         elif [ ${COMP_WORDS[1]} = infra-parse ];  then COMPREPLY=($(find . -type f 2>/dev/null | grep -E -e '\.yml$' -e '\.yaml$'  | sed s:./:: | grep "^${COMP_WORDS[2]}"))
         elif [ ${COMP_WORDS[1]} = infra-ensure ]; then COMPREPLY=($(find . -type f 2>/dev/null | grep -E -e '\.yml$' -e '\.yaml$'  | sed s:./:: | grep "^${COMP_WORDS[2]}"))
@@ -70,6 +75,14 @@ _libaws () {
         elif [ ${COMP_WORDS[1]} = sqs-stats ]; then COMPREPLY=($(libaws sqs-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
         elif [ ${COMP_WORDS[1]} = sqs-purge ]; then COMPREPLY=($(libaws sqs-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
         elif [ ${COMP_WORDS[1]} = sqs-rm    ]; then COMPREPLY=($(libaws sqs-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = sqs-receive ]; then COMPREPLY=($(libaws sqs-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = sqs-send-batch ]; then COMPREPLY=($(libaws sqs-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = sqs-redrive ]; then COMPREPLY=($(libaws sqs-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
+
+        elif [ ${COMP_WORDS[1]} = sns-publish ]; then COMPREPLY=($(libaws sns-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
+
+        elif [ ${COMP_WORDS[1]} = kinesis-put  ]; then COMPREPLY=($(libaws kinesis-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
+        elif [ ${COMP_WORDS[1]} = kinesis-tail ]; then COMPREPLY=($(libaws kinesis-ls 2>/dev/null | grep "^${COMP_WORDS[2]}"))
 
         elif [ ${COMP_WORDS[1]} = logs-search ]; then COMPREPLY=($(libaws logs-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
         elif [ ${COMP_WORDS[1]} = logs-near   ]; then COMPREPLY=($(libaws logs-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[2]}"))
@@ -90,6 +103,7 @@ _libaws () {
 
         if   [ ${COMP_WORDS[1]} = cloudwatch-ls-dimensions ]; then COMPREPLY=($(libaws cloudwatch-ls-metrics "${COMP_WORDS[2]}" 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[3]}"))
         elif [ ${COMP_WORDS[1]} = cloudwatch-get-metric ];    then COMPREPLY=($(libaws cloudwatch-ls-metrics "${COMP_WORDS[2]}" 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[3]}"))
+        elif [ ${COMP_WORDS[1]} = dynamodb-copy ];        then COMPREPLY=($(libaws dynamodb-ls 2>/dev/null | awk '{print $1}' | grep "^${COMP_WORDS[3]}"))
         elif [ ${COMP_WORDS[1]} = dynamodb-item-get ];    then COMPREPLY=($(libaws dynamodb-item-scan "${COMP_WORDS[2]}" 2>/dev/null | jq -r .id | grep "^${COMP_WORDS[3]}" | sed s/^/id:s:/))
         fi
 
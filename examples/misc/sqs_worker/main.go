@@ -0,0 +1,41 @@
+package main
+
+// a worker like those run on ec2, processing messages outside of lambda
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/nathants/libaws/lib"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lib.SignalHandler(cancel)
+	exitAfter, _ := strconv.Atoi(os.Getenv("exit_after")) // unset runs until signalled
+	var processed atomic.Int64
+	worker := &lib.SQSWorker{
+		QueueName:   "test-queue-" + os.Getenv("uid"),
+		Concurrency: 4,
+		Handler: func(ctx context.Context, message sqstypes.Message) error {
+			fmt.Println("thanks for:", aws.ToString(message.Body))
+			return nil
+		},
+		OnSuccess: func(message sqstypes.Message, duration time.Duration) {
+			if processed.Add(1) == int64(exitAfter) {
+				cancel()
+			}
+		},
+	}
+	err := worker.Run(ctx)
+	if err != nil {
+		lib.Logger.Fatal("error: ", err)
+	}
+}
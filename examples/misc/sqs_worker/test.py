@@ -0,0 +1,46 @@
+# type: ignore
+import pytest
+import sys
+import uuid
+import shell
+import yaml
+import os
+
+run = lambda *a, **kw: shell.run(*a, stream=True, **kw)
+
+
+def test():
+    assert os.environ["LIBAWS_TEST_ACCOUNT"] == run("libaws aws-account")
+    os.environ["uid"] = uid = str(uuid.uuid4())[-12:]
+    infra = yaml.safe_load(run("libaws infra-ls --env-values"))
+    assert sorted(infra["infraset"].keys()) == ["none"], infra
+    assert sorted(infra["infraset"]["none"].keys()) == ["user"], infra
+    run("libaws infra-ensure infra.yaml --preview")
+    run("libaws infra-ensure infra.yaml")
+    infra = yaml.safe_load(run("libaws infra-ls --env-values"))
+    infra.pop("region")
+    infra.pop("account")
+    infra["infraset"].pop("none")
+    expected = {
+        "infraset": {
+            f"test-infraset-{uid}": {
+                "sqs": {
+                    f"test-queue-{uid}": {"attr": ["VisibilityTimeout=60"]}
+                },
+            }
+        }
+    }
+    assert infra == expected, infra
+    run(f"seq 10 | libaws sqs-send-batch test-queue-{uid}")
+    output = run("exit_after=10 go run main.go")
+    bodies = [line.split()[-1] for line in output.splitlines() if line.startswith("thanks for:")]
+    assert sorted(bodies, key=int) == [str(i) for i in range(1, 11)], output
+    run("libaws infra-rm infra.yaml --preview")
+    run("libaws infra-rm infra.yaml")
+    infra = yaml.safe_load(run("libaws infra-ls --env-values"))
+    assert sorted(infra["infraset"].keys()) == ["none"], infra
+    assert sorted(infra["infraset"]["none"].keys()) == ["user"], infra
+
+
+if __name__ == "__main__":
+    sys.exit(pytest.main([__file__, "-svvx", "--tb", "native"]))
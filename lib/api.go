@@ -3,6 +3,8 @@ package lib
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -131,13 +133,23 @@ func ApiUrl(ctx context.Context, name string) (string, error) {
 	return url, nil
 }
 
+var apiWebsocketClients = map[string]*apigatewaymanagementapi.Client{}
+var apiWebsocketClientsLock sync.Mutex
+
 func apiWebsocketApi(domain string) *apigatewaymanagementapi.Client {
-	return apigatewaymanagementapi.NewFromConfig(
-		*Session(),
-		func(o *apigatewaymanagementapi.Options) {
-			o.BaseEndpoint = aws.String("https://" + domain)
-		},
-	)
+	apiWebsocketClientsLock.Lock()
+	defer apiWebsocketClientsLock.Unlock()
+	client, ok := apiWebsocketClients[domain]
+	if !ok {
+		client = apigatewaymanagementapi.NewFromConfig(
+			*Session(),
+			func(o *apigatewaymanagementapi.Options) {
+				o.BaseEndpoint = aws.String("https://" + domain)
+			},
+		)
+		apiWebsocketClients[domain] = client
+	}
+	return client
 }
 
 func ApiWebsocketSend(ctx context.Context, domain, connectionID string, data []byte) error {
@@ -154,3 +166,288 @@ func ApiWebsocketClose(ctx context.Context, domain, connectionID string) error {
 	})
 	return err
 }
+
+var apiRouteMethods = []string{"ANY", "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// apiRouteInput parses a shared api route like "GET /users/{id}=lambda-name"
+// or "$default=lambda-name" into its route key and lambda name
+func apiRouteInput(route string) (string, string, error) {
+	idx := strings.LastIndex(route, "=")
+	if idx == -1 {
+		err := fmt.Errorf("api route should be 'METHOD /path=LAMBDA', got: %s", route)
+		Logger.Println("error:", err)
+		return "", "", err
+	}
+	routeKey := strings.TrimSpace(route[:idx])
+	lambdaName := strings.TrimSpace(route[idx+1:])
+	if lambdaName == "" {
+		err := fmt.Errorf("api route is missing lambda name: %s", route)
+		Logger.Println("error:", err)
+		return "", "", err
+	}
+	if routeKey == lambdaDollarDefault {
+		return routeKey, lambdaName, nil
+	}
+	method, pth, err := SplitOnce(routeKey, " ")
+	if err != nil || !slices.Contains(apiRouteMethods, method) || !strings.HasPrefix(pth, "/") || strings.Contains(pth, " ") {
+		err := fmt.Errorf("api route should be 'METHOD /path=LAMBDA' with method one of %v, got: %s", apiRouteMethods, route)
+		Logger.Println("error:", err)
+		return "", "", err
+	}
+	return routeKey, lambdaName, nil
+}
+
+// apiAttrInput parses the attrs of a shared http api, which are the
+// authorizer and stage attrs of an api trigger and apply to all routes
+func apiAttrInput(infraApi *InfraApi) (*lambdaApiAuth, *lambdaApiStage, error) {
+	for _, line := range infraApi.Attr {
+		k, _, err := SplitOnce(line, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, nil, err
+		}
+		switch k {
+		case lambdaTriggerApiAttrJwtIssuer, lambdaTriggerApiAttrJwtAudience, lambdaTriggerApiAttrAuthorizer, lambdaTriggerApiAttrAuthorizerIdentity, lambdaTriggerApiAttrAuthorizerTTL:
+		case lambdaTriggerApiAttrThrottleRate, lambdaTriggerApiAttrThrottleBurst, lambdaTriggerApiAttrAccessLogs, lambdaTriggerApiAttrAccessLogsTTLDays, lambdaTriggerApiAttrDetailedMetrics:
+		default:
+			err := fmt.Errorf("unknown api attr: %s", line)
+			Logger.Println("error:", err)
+			return nil, nil, err
+		}
+	}
+	trigger := &InfraTrigger{Type: lambdaTriggerApi, Attr: infraApi.Attr}
+	auth, err := lambdaApiAuthInput(trigger)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, nil, err
+	}
+	stage, err := lambdaApiStageInput(trigger)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, nil, err
+	}
+	return auth, stage, nil
+}
+
+// ApiEnsure ensures a shared http api with routes mapped to lambdas in the
+// infraset, reusing the integration, stage, route and dns code of api triggers
+func ApiEnsure(ctx context.Context, infraSetName, name string, infraApi *InfraApi, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "ApiEnsure"}
+		d.Start()
+		defer d.End()
+	}
+	routeLambdas := map[string]string{}
+	var routeKeys []string
+	for _, route := range infraApi.Route {
+		routeKey, lambdaName, err := apiRouteInput(route)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if _, ok := routeLambdas[routeKey]; ok {
+			err := fmt.Errorf("duplicate api route: %s %s", name, routeKey)
+			Logger.Println("error:", err)
+			return err
+		}
+		routeLambdas[routeKey] = lambdaName
+		routeKeys = append(routeKeys, routeKey)
+	}
+	auth, stage, err := apiAttrInput(infraApi)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	if stage.accessLogs {
+		err := LogsEnsureGroup(ctx, infraSetName, lambdaApiAccessLogGroup(name), stage.accessLogsTTLDays, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	api, err := lambdaEnsureTriggerApi(ctx, infraSetName, name, "", apitypes.ProtocolTypeHttp, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	if api == nil {
+		for _, routeKey := range routeKeys {
+			Logger.Println(PreviewString(preview)+"created api route:", name, routeKey, routeLambdas[routeKey])
+		}
+		return nil
+	}
+	account, err := StsAccount(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	integrations, err := lambdaApiGetIntegrations(ctx, name, api)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	integrationIds := map[string]string{}
+	for _, routeKey := range routeKeys {
+		lambdaName := routeLambdas[routeKey]
+		if _, ok := integrationIds[lambdaName]; ok {
+			continue
+		}
+		arnLambda := fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", Region(), account, lambdaName)
+		var integration *apitypes.Integration
+		for _, i := range integrations {
+			if aws.ToString(i.IntegrationUri) == arnLambda {
+				integration = &i
+				break
+			}
+		}
+		integrationId, err := lambdaEnsureTriggerApiIntegration(ctx, name, arnLambda, api, integration, 30000, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		integrationIds[lambdaName] = integrationId
+		_, err = lambdaEnsurePermission(ctx, lambdaName, "apigateway.amazonaws.com", apiPermissionArn(account, *api.ApiId), preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	err = lambdaEnsureTriggerApiStage(ctx, name, account, api, stage, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	authorizerId, err := lambdaEnsureTriggerApiAuthorizer(ctx, name, account, api, auth, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	authorizationType := apitypes.AuthorizationType(lambdaAuthorizationType)
+	var routeAuthorizerId *string
+	if auth != nil {
+		authorizationType = auth.authorizationType()
+		if authorizerId != "" {
+			routeAuthorizerId = aws.String(authorizerId)
+		}
+	}
+	routes, err := lambdaApiGetRoutes(ctx, name, api)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, routeKey := range routeKeys {
+		err := lambdaEnsureTriggerApiRoute(ctx, name, routeKey, integrationIds[routeLambdas[routeKey]], api, routes, authorizationType, routeAuthorizerId, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	for _, route := range routes {
+		if _, ok := routeLambdas[*route.RouteKey]; ok {
+			continue
+		}
+		if !preview {
+			_, err := ApiClient().DeleteRoute(ctx, &apigatewayv2.DeleteRouteInput{
+				ApiId:   api.ApiId,
+				RouteId: route.RouteId,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"deleted api route:", name, *route.RouteKey)
+	}
+	if !(preview && auth != nil && authorizerId == "") { // in preview a new authorizer has no id yet
+		err = lambdaRemoveUnusedApiAuthorizers(ctx, name, api, authorizerId, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	for _, integration := range integrations {
+		if _, ok := integrationIds[LambdaArnToLambdaName(aws.ToString(integration.IntegrationUri))]; ok {
+			continue
+		}
+		if !preview {
+			_, err := ApiClient().DeleteIntegration(ctx, &apigatewayv2.DeleteIntegrationInput{
+				ApiId:         api.ApiId,
+				IntegrationId: integration.IntegrationId,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"deleted api integration:", name, LambdaArnToLambdaName(aws.ToString(integration.IntegrationUri)))
+	}
+	domain := infraApi.Dns
+	if infraApi.Dns != "" {
+		err := lambdaEnsureTriggerApiDns(ctx, name, infraApi.Dns, api, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	} else if infraApi.Domain != "" {
+		domain = infraApi.Domain
+		err := lambdaEnsureTriggerApiDomainName(ctx, name, infraApi.Domain, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		err = lambdaEnsureTriggerApiMapping(ctx, name, infraApi.Domain, api, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	domains, err := ApiListDomains(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, d := range domains {
+		if *d.DomainName == domain {
+			continue
+		}
+		err := lambdaTriggerApiDeleteDns(ctx, name, api, d, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func apiPermissionArn(account, apiId string) string {
+	return fmt.Sprintf("arn:aws:execute-api:%s:%s:%s/*/*", Region(), account, apiId)
+}
+
+func ApiDelete(ctx context.Context, name string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "ApiDelete"}
+		d.Start()
+		defer d.End()
+	}
+	api, err := Api(ctx, name)
+	if err != nil {
+		if err.Error() == ErrApiNotFound {
+			return nil
+		}
+		Logger.Println("error:", err)
+		return err
+	}
+	domains, err := ApiListDomains(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, domain := range domains {
+		err := lambdaTriggerApiDeleteDns(ctx, name, api, domain, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return lambdaTriggerApiDeleteApi(ctx, name, api, preview)
+}
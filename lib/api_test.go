@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestApiRouteInput(t *testing.T) {
+	type test struct {
+		route      string
+		routeKey   string
+		lambdaName string
+		err        bool
+	}
+	tests := []test{
+		{"GET /users/{id}=users", "GET /users/{id}", "users", false},
+		{"ANY /orders/{proxy+}=orders", "ANY /orders/{proxy+}", "orders", false},
+		{"$default=web", "$default", "web", false},
+		{"POST /a = b", "POST /a", "b", false},
+		{"GET /users", "", "", true},
+		{"FETCH /users=users", "", "", true},
+		{"GET users=users", "", "", true},
+		{"GET /users=", "", "", true},
+	}
+	for _, test := range tests {
+		routeKey, lambdaName, err := apiRouteInput(test.route)
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error for: %s", test.route)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nunexpected error: %s", err)
+			continue
+		}
+		if routeKey != test.routeKey || lambdaName != test.lambdaName {
+			t.Errorf("\ngot: %s %s\nwant: %s %s", routeKey, lambdaName, test.routeKey, test.lambdaName)
+		}
+	}
+}
+
+func TestApiAttrInput(t *testing.T) {
+	type test struct {
+		attr          []string
+		auth          bool
+		throttleBurst int32
+		err           bool
+	}
+	tests := []test{
+		{nil, false, 0, false},
+		{[]string{"authorizer=auth", "throttle-burst=20"}, true, 20, false},
+		{[]string{"jwt-issuer=https://example.auth0.com/", "jwt-audience=api"}, true, 0, false},
+		{[]string{"timeout=10000"}, false, 0, true},
+		{[]string{"dns=api.example.com"}, false, 0, true},
+		{[]string{"jwt-issuer=https://example.auth0.com/"}, false, 0, true},
+	}
+	for _, test := range tests {
+		auth, stage, err := apiAttrInput(&InfraApi{Attr: test.attr})
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error for: %v", test.attr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nunexpected error: %s", err)
+			continue
+		}
+		throttleBurst := int32(0)
+		if stage.throttleBurst != nil {
+			throttleBurst = *stage.throttleBurst
+		}
+		if (auth != nil) != test.auth || throttleBurst != test.throttleBurst {
+			t.Errorf("\ngot: %v %d\nwant: %v %d", auth != nil, throttleBurst, test.auth, test.throttleBurst)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package lib
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"math/rand/v2"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,8 +23,11 @@ import (
 	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+const dynamoDBBatchWriteSize = 25
+
 var dynamoDBClient *dynamodb.Client
 var dynamoDBClientLock sync.Mutex
+var dynamoDBClientsRegional = map[string]*dynamodb.Client{}
 
 func DynamoDBClientExplicit(accessKeyID, accessKeySecret, region string) *dynamodb.Client {
 	return dynamodb.NewFromConfig(*SessionExplicit(accessKeyID, accessKeySecret, region))
@@ -34,6 +42,25 @@ func DynamoDBClient() *dynamodb.Client {
 	return dynamoDBClient
 }
 
+// DynamoDBClientRegion returns a client for region, or the default client when region is empty
+func DynamoDBClientRegion(region string) (*dynamodb.Client, error) {
+	if region == "" {
+		return DynamoDBClient(), nil
+	}
+	dynamoDBClientLock.Lock()
+	defer dynamoDBClientLock.Unlock()
+	client, ok := dynamoDBClientsRegional[region]
+	if !ok {
+		sess, err := SessionRegion(region)
+		if err != nil {
+			return nil, err
+		}
+		client = dynamodb.NewFromConfig(*sess)
+		dynamoDBClientsRegional[region] = client
+	}
+	return client, nil
+}
+
 func dynamoDBTableAttrShortcut(s string) string {
 	s2, ok := map[string]string{
 		"read":   "ProvisionedThroughput.ReadCapacityUnits",
@@ -46,13 +73,42 @@ func dynamoDBTableAttrShortcut(s string) string {
 	return s
 }
 
-func DynamoDBEnsureInput(infraSetName, tableName string, keys []string, attrs []string) (*dynamodb.CreateTableInput, *ddbtypes.TimeToLiveSpecification, error) {
+const (
+	dynamoDBAttrDeletionProtection  = "DeletionProtectionEnabled"
+	dynamoDBAttrTableClass          = "TableClass"
+	dynamoDBAttrPointInTimeRecovery = "PointInTimeRecoveryEnabled"
+	dynamoDBAttrContributorInsights = "ContributorInsightsEnabled"
+)
+
+func dynamoDBSettingAttrShortcut(s string) string {
+	s2, ok := map[string]string{
+		"deletion-protection": dynamoDBAttrDeletionProtection,
+		"class":               dynamoDBAttrTableClass,
+		"pitr":                dynamoDBAttrPointInTimeRecovery,
+		"insights":            dynamoDBAttrContributorInsights,
+	}[s]
+	if ok {
+		return s2
+	}
+	return s
+}
+
+// DynamoDBEnsureOptions are table settings managed outside of CreateTableInput
+// and UpdateTableInput. nil settings are left as they are, except for ttl
+// which is disabled when nil.
+type DynamoDBEnsureOptions struct {
+	TTL                 *ddbtypes.TimeToLiveSpecification
+	PointInTimeRecovery *bool
+	ContributorInsights *bool
+}
+
+func DynamoDBEnsureInput(infraSetName, tableName string, keys []string, attrs []string) (*dynamodb.CreateTableInput, *DynamoDBEnsureOptions, error) {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "DynamoDBEnsureInput"}
 		d.Start()
 		defer d.End()
 	}
-	var ttl *ddbtypes.TimeToLiveSpecification
+	options := &DynamoDBEnsureOptions{}
 	input := &dynamodb.CreateTableInput{
 		TableName:        aws.String(tableName),
 		BillingMode:      ddbtypes.BillingModePayPerRequest,
@@ -94,12 +150,38 @@ func DynamoDBEnsureInput(infraSetName, tableName string, keys []string, attrs []
 			return nil, nil, err
 		}
 		if attr == "ttl" {
-			ttl = &ddbtypes.TimeToLiveSpecification{
+			options.TTL = &ddbtypes.TimeToLiveSpecification{
 				AttributeName: aws.String(value),
 				Enabled:       aws.Bool(true),
 			}
 			continue
 		}
+		switch dynamoDBSettingAttrShortcut(attr) {
+		case dynamoDBAttrDeletionProtection, dynamoDBAttrPointInTimeRecovery, dynamoDBAttrContributorInsights:
+			if value != "true" && value != "false" {
+				err := fmt.Errorf("dynamodb attr %s should be true or false, got: %s", attr, value)
+				Logger.Println("error:", err)
+				return nil, nil, err
+			}
+			switch dynamoDBSettingAttrShortcut(attr) {
+			case dynamoDBAttrDeletionProtection:
+				input.DeletionProtectionEnabled = aws.Bool(value == "true")
+			case dynamoDBAttrPointInTimeRecovery:
+				options.PointInTimeRecovery = aws.Bool(value == "true")
+			case dynamoDBAttrContributorInsights:
+				options.ContributorInsights = aws.Bool(value == "true")
+			}
+			continue
+		case dynamoDBAttrTableClass:
+			class := ddbtypes.TableClass(strings.ToUpper(value))
+			if !slices.Contains(class.Values(), class) {
+				err := fmt.Errorf("dynamodb attr %s should be one of %v, got: %s", attr, class.Values(), value)
+				Logger.Println("error:", err)
+				return nil, nil, err
+			}
+			input.TableClass = class
+			continue
+		}
 		attr = dynamoDBTableAttrShortcut(attr)
 		head, tail, err := SplitOnce(attr, ".")
 		if err != nil {
@@ -460,10 +542,202 @@ func DynamoDBEnsureInput(infraSetName, tableName string, keys []string, attrs []
 		input.SSESpecification.SSEType == "" {
 		input.SSESpecification = nil
 	}
-	return input, ttl, nil
+	return input, options, nil
+}
+
+type DynamoDBTableSettings struct {
+	DeletionProtection  bool
+	TableClass          ddbtypes.TableClass
+	PointInTimeRecovery bool
+	ContributorInsights bool
+}
+
+// Attrs returns settings as table attrs, omitting defaults unless all is true.
+func (s *DynamoDBTableSettings) Attrs(all bool) []string {
+	var attrs []string
+	if all || s.DeletionProtection {
+		attrs = append(attrs, fmt.Sprintf("%s=%t", dynamoDBAttrDeletionProtection, s.DeletionProtection))
+	}
+	if all || s.TableClass != ddbtypes.TableClassStandard {
+		attrs = append(attrs, fmt.Sprintf("%s=%s", dynamoDBAttrTableClass, s.TableClass))
+	}
+	if all || s.PointInTimeRecovery {
+		attrs = append(attrs, fmt.Sprintf("%s=%t", dynamoDBAttrPointInTimeRecovery, s.PointInTimeRecovery))
+	}
+	if all || s.ContributorInsights {
+		attrs = append(attrs, fmt.Sprintf("%s=%t", dynamoDBAttrContributorInsights, s.ContributorInsights))
+	}
+	return attrs
+}
+
+func DynamoDBDescribeSettings(ctx context.Context, tableName string) (*DynamoDBTableSettings, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "DynamoDBDescribeSettings"}
+		d.Start()
+		defer d.End()
+	}
+	out, err := DynamoDBClient().DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return DynamoDBTableDescriptionSettings(ctx, out.Table, true)
+}
+
+// DynamoDBTableDescriptionSettings reads settings from an existing describe,
+// only calling out for settings not included in TableDescription. when wait is
+// false, contributor insights that are enabling count as enabled.
+func DynamoDBTableDescriptionSettings(ctx context.Context, table *ddbtypes.TableDescription, wait bool) (*DynamoDBTableSettings, error) {
+	tableName := aws.ToString(table.TableName)
+	settings := &DynamoDBTableSettings{
+		DeletionProtection: aws.ToBool(table.DeletionProtectionEnabled),
+		TableClass:         ddbtypes.TableClassStandard,
+	}
+	if table.TableClassSummary != nil && table.TableClassSummary.TableClass != "" {
+		settings.TableClass = table.TableClassSummary.TableClass
+	}
+	pitr, err := dynamoDBPointInTimeRecoveryStatus(ctx, tableName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	settings.PointInTimeRecovery = pitr == ddbtypes.PointInTimeRecoveryStatusEnabled
+	var insights ddbtypes.ContributorInsightsStatus
+	if wait {
+		insights, err = dynamoDBContributorInsightsStatus(ctx, tableName)
+	} else {
+		insights, err = dynamoDBDescribeContributorInsights(ctx, tableName)
+	}
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	settings.ContributorInsights = insights == ddbtypes.ContributorInsightsStatusEnabled || insights == ddbtypes.ContributorInsightsStatusEnabling
+	return settings, nil
+}
+
+func dynamoDBPointInTimeRecoveryStatus(ctx context.Context, tableName string) (ddbtypes.PointInTimeRecoveryStatus, error) {
+	out, err := DynamoDBClient().DescribeContinuousBackups(ctx, &dynamodb.DescribeContinuousBackupsInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	if out.ContinuousBackupsDescription == nil || out.ContinuousBackupsDescription.PointInTimeRecoveryDescription == nil {
+		return ddbtypes.PointInTimeRecoveryStatusDisabled, nil
+	}
+	return out.ContinuousBackupsDescription.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus, nil
+}
+
+func dynamoDBDescribeContributorInsights(ctx context.Context, tableName string) (ddbtypes.ContributorInsightsStatus, error) {
+	out, err := DynamoDBClient().DescribeContributorInsights(ctx, &dynamodb.DescribeContributorInsightsInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	return out.ContributorInsightsStatus, nil
+}
+
+// dynamoDBContributorInsightsStatus waits up to 10 minutes while insights are
+// enabling or disabling
+func dynamoDBContributorInsightsStatus(ctx context.Context, tableName string) (ddbtypes.ContributorInsightsStatus, error) {
+	for range 300 {
+		status, err := dynamoDBDescribeContributorInsights(ctx, tableName)
+		if err != nil {
+			Logger.Println("error:", err)
+			return "", err
+		}
+		switch status {
+		case ddbtypes.ContributorInsightsStatusEnabling, ddbtypes.ContributorInsightsStatusDisabling:
+			Logger.Println("waiting for table contributor insights status to finish updating:", tableName, status)
+		default:
+			return status, nil
+		}
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			Logger.Println("error:", ctx.Err())
+			return "", ctx.Err()
+		}
+	}
+	err := fmt.Errorf("timed out waiting for table contributor insights status to finish updating: %s", tableName)
+	Logger.Println("error:", err)
+	return "", err
+}
+
+// dynamoDBEnsureSettings reconciles point in time recovery and contributor
+// insights, leaving nil settings as they are. exists is false when the table
+// is being created in preview.
+func dynamoDBEnsureSettings(ctx context.Context, tableName string, options *DynamoDBEnsureOptions, exists, preview bool) error {
+	if options.PointInTimeRecovery != nil {
+		enabled := false
+		if exists {
+			status, err := dynamoDBPointInTimeRecoveryStatus(ctx, tableName)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			enabled = status == ddbtypes.PointInTimeRecoveryStatusEnabled
+		}
+		if enabled != *options.PointInTimeRecovery {
+			if !preview {
+				err := Retry(ctx, func() error {
+					_, err := DynamoDBClient().UpdateContinuousBackups(ctx, &dynamodb.UpdateContinuousBackupsInput{
+						TableName: aws.String(tableName),
+						PointInTimeRecoverySpecification: &ddbtypes.PointInTimeRecoverySpecification{
+							PointInTimeRecoveryEnabled: options.PointInTimeRecovery,
+						},
+					})
+					return err
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Printf(PreviewString(preview)+"updated point in time recovery for table %s: %t => %t\n", tableName, enabled, *options.PointInTimeRecovery)
+		}
+	}
+	if options.ContributorInsights != nil {
+		enabled := false
+		if exists {
+			status, err := dynamoDBContributorInsightsStatus(ctx, tableName)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			enabled = status == ddbtypes.ContributorInsightsStatusEnabled
+		}
+		if enabled != *options.ContributorInsights {
+			action := ddbtypes.ContributorInsightsActionDisable
+			if *options.ContributorInsights {
+				action = ddbtypes.ContributorInsightsActionEnable
+			}
+			if !preview {
+				err := Retry(ctx, func() error {
+					_, err := DynamoDBClient().UpdateContributorInsights(ctx, &dynamodb.UpdateContributorInsightsInput{
+						TableName:                 aws.String(tableName),
+						ContributorInsightsAction: action,
+					})
+					return err
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Printf(PreviewString(preview)+"updated contributor insights for table %s: %t => %t\n", tableName, enabled, *options.ContributorInsights)
+		}
+	}
+	return nil
 }
 
-func DynamoDBEnsure(ctx context.Context, input *dynamodb.CreateTableInput, ttl *ddbtypes.TimeToLiveSpecification, preview bool) error {
+func DynamoDBEnsure(ctx context.Context, input *dynamodb.CreateTableInput, options *DynamoDBEnsureOptions, preview bool) error {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "DynamoDBEnsure"}
 		d.Start()
@@ -483,6 +757,20 @@ func DynamoDBEnsure(ctx context.Context, input *dynamodb.CreateTableInput, ttl *
 				}
 			}
 			Logger.Println(PreviewString(preview)+"created table:", *input.TableName)
+			if aws.ToBool(options.PointInTimeRecovery) || aws.ToBool(options.ContributorInsights) {
+				if !preview {
+					err := DynamoDBWaitForReady(ctx, *input.TableName)
+					if err != nil {
+						Logger.Println("error:", err)
+						return err
+					}
+				}
+				err := dynamoDBEnsureSettings(ctx, *input.TableName, options, !preview, preview)
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
 			return nil
 		}
 		Logger.Println("error:", err)
@@ -593,6 +881,30 @@ func DynamoDBEnsure(ctx context.Context, input *dynamodb.CreateTableInput, ttl *
 			input.StreamSpecification.StreamViewType,
 		)
 	}
+	if input.DeletionProtectionEnabled != nil && *input.DeletionProtectionEnabled != aws.ToBool(table.Table.DeletionProtectionEnabled) {
+		needsUpdate = true
+		update.DeletionProtectionEnabled = input.DeletionProtectionEnabled
+		Logger.Printf(
+			PreviewString(preview)+"will update DeletionProtectionEnabled for table %s: %t => %t\n",
+			*input.TableName,
+			aws.ToBool(table.Table.DeletionProtectionEnabled),
+			*input.DeletionProtectionEnabled,
+		)
+	}
+	existingClass := ddbtypes.TableClassStandard
+	if table.Table.TableClassSummary != nil && table.Table.TableClassSummary.TableClass != "" {
+		existingClass = table.Table.TableClassSummary.TableClass
+	}
+	if input.TableClass != "" && input.TableClass != existingClass {
+		needsUpdate = true
+		update.TableClass = input.TableClass
+		Logger.Printf(
+			PreviewString(preview)+"will update TableClass for table %s: %s => %s\n",
+			*input.TableName,
+			existingClass,
+			input.TableClass,
+		)
+	}
 	existingLocalIndices := map[string]ddbtypes.LocalSecondaryIndexDescription{}
 	for _, index := range table.Table.LocalSecondaryIndexes {
 		existingLocalIndices[*index.IndexName] = index
@@ -866,6 +1178,7 @@ func DynamoDBEnsure(ctx context.Context, input *dynamodb.CreateTableInput, ttl *
 		Logger.Println("waiting for table ttl status to finish updating:", *input.TableName, status)
 		time.Sleep(2 * time.Second)
 	}
+	ttl := options.TTL
 	if ttl == nil {
 		if ttlOut.TimeToLiveDescription.TimeToLiveStatus == ddbtypes.TimeToLiveStatusEnabled {
 			if !preview {
@@ -903,6 +1216,11 @@ func DynamoDBEnsure(ctx context.Context, input *dynamodb.CreateTableInput, ttl *
 			Logger.Println(PreviewString(preview)+"enable ttl attr:", *ttl.AttributeName+", table:", *input.TableName)
 		}
 	}
+	err = dynamoDBEnsureSettings(ctx, *input.TableName, options, true, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
 	return nil
 }
 
@@ -1164,3 +1482,960 @@ func DynamoDBItemDeleteAll(ctx context.Context, tableName string, keyNames []str
 	}
 	return nil
 }
+
+// DynamoDBKeyValue parses a key value like: user:s:john or date:n:123
+func DynamoDBKeyValue(key string) (string, ddbtypes.AttributeValue, error) {
+	name, kind, value, err := SplitTwice(key, ":")
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", nil, err
+	}
+	av, err := dynamoDBKeyAttributeValue(kind, value)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", nil, err
+	}
+	return name, av, nil
+}
+
+func dynamoDBKeyAttributeValue(kind, value string) (ddbtypes.AttributeValue, error) {
+	switch strings.ToUpper(kind) {
+	case "S":
+		return &ddbtypes.AttributeValueMemberS{Value: value}, nil
+	case "N":
+		return &ddbtypes.AttributeValueMemberN{Value: value}, nil
+	default:
+		err := fmt.Errorf("key type should be s or n, got: %s", kind)
+		return nil, err
+	}
+}
+
+// DynamoDBKeyString formats a key attribute like DynamoDBKeyValue parses it
+func DynamoDBKeyString(name string, av ddbtypes.AttributeValue) (string, error) {
+	switch v := av.(type) {
+	case *ddbtypes.AttributeValueMemberS:
+		return name + ":s:" + v.Value, nil
+	case *ddbtypes.AttributeValueMemberN:
+		return name + ":n:" + v.Value, nil
+	default:
+		err := fmt.Errorf("key type should be s or n, got: %T", av)
+		Logger.Println("error:", err)
+		return "", err
+	}
+}
+
+var dynamoDBQueryOperators = []string{"=", "<", "<=", ">", ">=", "between", "begins_with"}
+
+var dynamoDBFilterOperators = []string{"=", "<>", "<", "<=", ">", ">=", "between", "begins_with", "contains"}
+
+// dynamoDBConditionExpression parses a condition like name:s:value or
+// name:n:OPERATOR:value into an expression, adding its name and values with
+// refs like #k0 and :v00 for namePrefix #k, valuePrefix :v and i 0
+func dynamoDBConditionExpression(condition string, i int, namePrefix, valuePrefix string, operators []string, names map[string]string, values map[string]ddbtypes.AttributeValue) (string, error) {
+	name, kind, rest, err := SplitTwice(condition, ":")
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	operator := "="
+	operands := []string{rest}
+	op, tail, err := SplitOnce(rest, ":")
+	if err == nil && slices.Contains(operators, strings.ToLower(op)) {
+		operator = strings.ToLower(op)
+		operands = []string{tail}
+		if operator == "between" {
+			low, high, err := SplitOnce(tail, ":")
+			if err != nil {
+				err := fmt.Errorf("between should be like name:n:between:LOW:HIGH, got: %s", condition)
+				Logger.Println("error:", err)
+				return "", err
+			}
+			operands = []string{low, high}
+		}
+	}
+	nameRef := fmt.Sprintf("%s%d", namePrefix, i)
+	names[nameRef] = name
+	var valueRefs []string
+	for j, operand := range operands {
+		av, err := dynamoDBKeyAttributeValue(kind, operand)
+		if err != nil {
+			Logger.Println("error:", err)
+			return "", err
+		}
+		valueRef := fmt.Sprintf("%s%d%d", valuePrefix, i, j)
+		values[valueRef] = av
+		valueRefs = append(valueRefs, valueRef)
+	}
+	switch operator {
+	case "between":
+		return fmt.Sprintf("%s BETWEEN %s AND %s", nameRef, valueRefs[0], valueRefs[1]), nil
+	case "begins_with", "contains":
+		return fmt.Sprintf("%s(%s, %s)", operator, nameRef, valueRefs[0]), nil
+	default:
+		return fmt.Sprintf("%s %s %s", nameRef, operator, valueRefs[0]), nil
+	}
+}
+
+// DynamoDBQueryInput builds a query from a partition key condition like
+// user:s:john, and an optional sort key condition like date:n:123,
+// date:n:>=:123, date:n:between:100:200 or name:s:begins_with:jo
+func DynamoDBQueryInput(tableName, indexName string, conditions []string) (*dynamodb.QueryInput, error) {
+	if len(conditions) != 1 && len(conditions) != 2 {
+		err := fmt.Errorf("query needs a partition key condition and an optional sort key condition, got: %v", conditions)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(tableName),
+		ExpressionAttributeNames:  map[string]string{},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{},
+	}
+	if indexName != "" {
+		input.IndexName = aws.String(indexName)
+	}
+	var expressions []string
+	for i, condition := range conditions {
+		operators := dynamoDBQueryOperators
+		if i == 0 {
+			operators = nil // partition key is always equality
+		}
+		expression, err := dynamoDBConditionExpression(condition, i, "#k", ":v", operators, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		expressions = append(expressions, expression)
+	}
+	input.KeyConditionExpression = aws.String(strings.Join(expressions, " AND "))
+	return input, nil
+}
+
+type DynamoDBScanInput struct {
+	TableName      string
+	Segments       int      // parallel scan segments, default: 1
+	Attributes     []string // projection, default: all attributes
+	Filters        []string // conditions like age:n:>:30, which must all match
+	ConsistentRead bool
+	Limit          int    // stop after this many items, default: no limit
+	Region         string // default: current region
+}
+
+// dynamoDBScanInput builds the scan of one segment
+func dynamoDBScanInput(input *DynamoDBScanInput) (*dynamodb.ScanInput, error) {
+	scan := &dynamodb.ScanInput{
+		TableName:                 aws.String(input.TableName),
+		ConsistentRead:            aws.Bool(input.ConsistentRead),
+		ExpressionAttributeNames:  map[string]string{},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{},
+	}
+	if input.Segments > 1 {
+		scan.TotalSegments = aws.Int32(int32(input.Segments))
+	}
+	var projections []string
+	for i, attr := range input.Attributes {
+		ref := fmt.Sprintf("#p%d", i)
+		scan.ExpressionAttributeNames[ref] = attr
+		projections = append(projections, ref)
+	}
+	if len(projections) != 0 {
+		scan.ProjectionExpression = aws.String(strings.Join(projections, ", "))
+	}
+	var filters []string
+	for i, filter := range input.Filters {
+		expression, err := dynamoDBConditionExpression(filter, i, "#f", ":f", dynamoDBFilterOperators, scan.ExpressionAttributeNames, scan.ExpressionAttributeValues)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		filters = append(filters, expression)
+	}
+	if len(filters) != 0 {
+		scan.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+	if len(scan.ExpressionAttributeNames) == 0 {
+		scan.ExpressionAttributeNames = nil
+	}
+	if len(scan.ExpressionAttributeValues) == 0 {
+		scan.ExpressionAttributeValues = nil
+	}
+	return scan, nil
+}
+
+func dynamoDBThrottled(err error) bool {
+	var throughput *ddbtypes.ProvisionedThroughputExceededException
+	var requestLimit *ddbtypes.RequestLimitExceeded
+	return errors.As(err, &throughput) || errors.As(err, &requestLimit) || strings.Contains(err.Error(), "ThrottlingException")
+}
+
+// DynamoDBScan scans a table, in parallel segments if requested, calling
+// callback for each item. callback is never called concurrently. throttled
+// requests are retried with backoff.
+func DynamoDBScan(ctx context.Context, input *DynamoDBScanInput, callback func(item map[string]ddbtypes.AttributeValue)) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "DynamoDBScan"}
+		d.Start()
+		defer d.End()
+	}
+	client, err := DynamoDBClientRegion(input.Region)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	return dynamoDBScan(ctx, client, input, callback)
+}
+
+// dynamoDBScanAPI is the part of the dynamodb client used by DynamoDBScan
+type dynamoDBScanAPI interface {
+	Scan(ctx context.Context, input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+func dynamoDBScan(ctx context.Context, client dynamoDBScanAPI, input *DynamoDBScanInput, callback func(item map[string]ddbtypes.AttributeValue)) error {
+	segments := max(input.Segments, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lock sync.Mutex
+	count := 0
+	errChan := make(chan error, segments)
+	for segment := range segments {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logRecover(r)
+				}
+			}()
+			scan, err := dynamoDBScanInput(input)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if segments > 1 {
+				scan.Segment = aws.Int32(int32(segment))
+			}
+			backoff := 100 * time.Millisecond
+			for {
+				out, err := client.Scan(ctx, scan)
+				if err != nil {
+					if ctx.Err() != nil {
+						errChan <- nil
+						return
+					}
+					if dynamoDBThrottled(err) {
+						Logger.Println("throttled, backing off:", backoff)
+						time.Sleep(backoff)
+						backoff = min(backoff*2, 20*time.Second)
+						continue
+					}
+					errChan <- err
+					return
+				}
+				backoff = 100 * time.Millisecond
+				lock.Lock()
+				for _, item := range out.Items {
+					if input.Limit != 0 && count >= input.Limit {
+						break
+					}
+					callback(item)
+					count++
+				}
+				done := input.Limit != 0 && count >= input.Limit
+				lock.Unlock()
+				if done {
+					cancel()
+					errChan <- nil
+					return
+				}
+				if out.LastEvaluatedKey == nil {
+					errChan <- nil
+					return
+				}
+				scan.ExclusiveStartKey = out.LastEvaluatedKey
+			}
+		}()
+	}
+	var firstErr error
+	for range segments {
+		err := <-errChan
+		if err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	if firstErr != nil {
+		Logger.Println("error:", firstErr)
+		return firstErr
+	}
+	return nil
+}
+
+// DynamoDBItemToJSON encodes an item as a json object with sorted keys. like
+// dynamodb-item-put, S is a string, N is a number and BOOL is a bool. numbers
+// keep their exact value. types with no plain json form are objects with a
+// single type key: {"SS": ["a"]}, {"NS": [1]}, {"B": "base64"} and {"BS":
+// ["base64"]}. a map whose only key is one of those, or M, is wrapped as
+// {"M": {...}} so that DynamoDBItemFromJSON reads every item back exactly.
+func DynamoDBItemToJSON(item map[string]ddbtypes.AttributeValue) ([]byte, error) {
+	m := map[string]any{}
+	for k, av := range item {
+		val, err := dynamoDBJSONValue(av)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		m[k] = val
+	}
+	return json.Marshal(m)
+}
+
+// dynamoDBJSONTypes are the single keys of objects holding a typed value
+var dynamoDBJSONTypes = []string{"B", "BS", "M", "NS", "SS"}
+
+func dynamoDBJSONValue(av ddbtypes.AttributeValue) (any, error) {
+	switch v := av.(type) {
+	case *ddbtypes.AttributeValueMemberS:
+		return v.Value, nil
+	case *ddbtypes.AttributeValueMemberN:
+		return json.Number(v.Value), nil
+	case *ddbtypes.AttributeValueMemberBOOL:
+		return v.Value, nil
+	case *ddbtypes.AttributeValueMemberNULL:
+		return nil, nil
+	case *ddbtypes.AttributeValueMemberB:
+		return map[string]any{"B": v.Value}, nil
+	case *ddbtypes.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}, nil
+	case *ddbtypes.AttributeValueMemberNS:
+		numbers := []json.Number{}
+		for _, n := range v.Value {
+			numbers = append(numbers, json.Number(n))
+		}
+		return map[string]any{"NS": numbers}, nil
+	case *ddbtypes.AttributeValueMemberBS:
+		return map[string]any{"BS": v.Value}, nil
+	case *ddbtypes.AttributeValueMemberL:
+		list := []any{}
+		for _, av := range v.Value {
+			val, err := dynamoDBJSONValue(av)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		return list, nil
+	case *ddbtypes.AttributeValueMemberM:
+		m := map[string]any{}
+		for k, av := range v.Value {
+			val, err := dynamoDBJSONValue(av)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = val
+		}
+		if len(m) == 1 {
+			for k := range m {
+				if slices.Contains(dynamoDBJSONTypes, k) {
+					return map[string]any{"M": m}, nil
+				}
+			}
+		}
+		return m, nil
+	default:
+		err := fmt.Errorf("unknown dynamodb attribute value type: %T", av)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+}
+
+// DynamoDBItemFromJSON decodes a json object as written by DynamoDBItemToJSON
+func DynamoDBItemFromJSON(data []byte) (map[string]ddbtypes.AttributeValue, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var val map[string]any
+	err := decoder.Decode(&val)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if val == nil {
+		err := fmt.Errorf("dynamodb item should be a json object, got: %s", string(data))
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	item := map[string]ddbtypes.AttributeValue{}
+	for k, v := range val {
+		item[k], err = dynamoDBAttributeValueFromJSON(v)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	return item, nil
+}
+
+func dynamoDBAttributeValueFromJSON(val any) (ddbtypes.AttributeValue, error) {
+	switch v := val.(type) {
+	case string:
+		return &ddbtypes.AttributeValueMemberS{Value: v}, nil
+	case json.Number:
+		return &ddbtypes.AttributeValueMemberN{Value: v.String()}, nil
+	case bool:
+		return &ddbtypes.AttributeValueMemberBOOL{Value: v}, nil
+	case []any:
+		list := []ddbtypes.AttributeValue{}
+		for _, x := range v {
+			av, err := dynamoDBAttributeValueFromJSON(x)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, av)
+		}
+		return &ddbtypes.AttributeValueMemberL{Value: list}, nil
+	case map[string]any:
+		if len(v) == 1 {
+			for k, x := range v {
+				if slices.Contains(dynamoDBJSONTypes, k) {
+					return dynamoDBTypedValueFromJSON(k, x)
+				}
+			}
+		}
+		return dynamoDBMapFromJSON(v)
+	default:
+		return &ddbtypes.AttributeValueMemberNULL{Value: true}, nil
+	}
+}
+
+func dynamoDBMapFromJSON(v map[string]any) (ddbtypes.AttributeValue, error) {
+	m := map[string]ddbtypes.AttributeValue{}
+	for k, x := range v {
+		av, err := dynamoDBAttributeValueFromJSON(x)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = av
+	}
+	return &ddbtypes.AttributeValueMemberM{Value: m}, nil
+}
+
+// dynamoDBTypedValueFromJSON decodes the value of a single type key object
+func dynamoDBTypedValueFromJSON(kind string, val any) (ddbtypes.AttributeValue, error) {
+	err := fmt.Errorf("dynamodb json %s has an invalid value: %v", kind, val)
+	switch kind {
+	case "M":
+		m, ok := val.(map[string]any)
+		if !ok {
+			return nil, err
+		}
+		return dynamoDBMapFromJSON(m)
+	case "B":
+		s, ok := val.(string)
+		if !ok {
+			return nil, err
+		}
+		data, decodeErr := base64.StdEncoding.DecodeString(s)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		return &ddbtypes.AttributeValueMemberB{Value: data}, nil
+	}
+	list, ok := val.([]any)
+	if !ok {
+		return nil, err
+	}
+	switch kind {
+	case "SS":
+		set := &ddbtypes.AttributeValueMemberSS{Value: []string{}}
+		for _, x := range list {
+			s, ok := x.(string)
+			if !ok {
+				return nil, err
+			}
+			set.Value = append(set.Value, s)
+		}
+		return set, nil
+	case "NS":
+		set := &ddbtypes.AttributeValueMemberNS{Value: []string{}}
+		for _, x := range list {
+			n, ok := x.(json.Number)
+			if !ok {
+				return nil, err
+			}
+			set.Value = append(set.Value, n.String())
+		}
+		return set, nil
+	default: // BS
+		set := &ddbtypes.AttributeValueMemberBS{Value: [][]byte{}}
+		for _, x := range list {
+			s, ok := x.(string)
+			if !ok {
+				return nil, err
+			}
+			data, decodeErr := base64.StdEncoding.DecodeString(s)
+			if decodeErr != nil {
+				return nil, decodeErr
+			}
+			set.Value = append(set.Value, data)
+		}
+		return set, nil
+	}
+}
+
+// DynamoDBBatchPut writes items in batches, retrying unprocessed items and
+// throttled requests with backoff. region defaults to the current region.
+func DynamoDBBatchPut(ctx context.Context, region, tableName string, items []map[string]ddbtypes.AttributeValue) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "DynamoDBBatchPut"}
+		d.Start()
+		defer d.End()
+	}
+	client, err := DynamoDBClientRegion(region)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	return dynamoDBBatchPut(ctx, client, tableName, items)
+}
+
+// dynamoDBBatchWriteItemAPI is the part of the dynamodb client used by DynamoDBBatchPut
+type dynamoDBBatchWriteItemAPI interface {
+	BatchWriteItem(ctx context.Context, input *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+func dynamoDBBatchPut(ctx context.Context, client dynamoDBBatchWriteItemAPI, tableName string, items []map[string]ddbtypes.AttributeValue) error {
+	for chunk := range slices.Chunk(items, dynamoDBBatchWriteSize) {
+		var reqs []ddbtypes.WriteRequest
+		for _, item := range chunk {
+			reqs = append(reqs, ddbtypes.WriteRequest{
+				PutRequest: &ddbtypes.PutRequest{Item: item},
+			})
+		}
+		backoff := 100 * time.Millisecond
+		for len(reqs) != 0 {
+			out, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]ddbtypes.WriteRequest{tableName: reqs},
+			})
+			if err != nil {
+				if !dynamoDBThrottled(err) {
+					Logger.Println("error:", err)
+					return err
+				}
+			} else {
+				reqs = out.UnprocessedItems[tableName]
+				if len(reqs) == 0 {
+					break
+				}
+			}
+			Logger.Println("throttled, backing off:", backoff)
+			time.Sleep(backoff)
+			backoff = min(backoff*2, 20*time.Second)
+		}
+	}
+	return nil
+}
+
+type DynamoDBCopyInput struct {
+	Source            string
+	SourceRegion      string // default: current region
+	Destination       string
+	DestinationRegion string // default: current region
+	Segments          int    // parallel scan segments and writers, default: 1
+}
+
+// DynamoDBCopy puts every item of the source table into the destination
+// table, which must already exist, and returns the number of items copied.
+func DynamoDBCopy(ctx context.Context, input *DynamoDBCopyInput) (int, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "DynamoDBCopy"}
+		d.Start()
+		defer d.End()
+	}
+	if input.Source == input.Destination && input.SourceRegion == input.DestinationRegion {
+		err := fmt.Errorf("dynamodb copy source and destination are the same table: %s", input.Source)
+		Logger.Println("error:", err)
+		return 0, err
+	}
+	source, err := DynamoDBClientRegion(input.SourceRegion)
+	if err != nil {
+		Logger.Println("error:", err)
+		return 0, err
+	}
+	destination, err := DynamoDBClientRegion(input.DestinationRegion)
+	if err != nil {
+		Logger.Println("error:", err)
+		return 0, err
+	}
+	return dynamoDBCopy(ctx, source, destination, input)
+}
+
+func dynamoDBCopy(ctx context.Context, source dynamoDBScanAPI, destination dynamoDBBatchWriteItemAPI, input *DynamoDBCopyInput) (int, error) {
+	segments := max(input.Segments, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	items := make(chan map[string]ddbtypes.AttributeValue, segments*dynamoDBBatchWriteSize)
+	var lock sync.Mutex
+	count := 0
+	errChan := make(chan error, segments)
+	for range segments {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logRecover(r)
+				}
+			}()
+			var batch []map[string]ddbtypes.AttributeValue
+			flush := func() error {
+				err := dynamoDBBatchPut(ctx, destination, input.Destination, batch)
+				if err != nil {
+					return err
+				}
+				lock.Lock()
+				count += len(batch)
+				lock.Unlock()
+				batch = nil
+				return nil
+			}
+			for item := range items {
+				batch = append(batch, item)
+				if len(batch) == dynamoDBBatchWriteSize {
+					err := flush()
+					if err != nil {
+						cancel()
+						errChan <- err
+						return
+					}
+				}
+			}
+			errChan <- flush()
+		}()
+	}
+	err := dynamoDBScan(ctx, source, &DynamoDBScanInput{
+		TableName: input.Source,
+		Segments:  segments,
+	}, func(item map[string]ddbtypes.AttributeValue) {
+		select {
+		case items <- item:
+		case <-ctx.Done():
+		}
+	})
+	close(items)
+	for range segments {
+		writeErr := <-errChan
+		if writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		Logger.Println("error:", err)
+		return count, err
+	}
+	return count, nil
+}
+
+// DynamoDBExportToS3 starts a native export of a table as dynamodb json to
+// s3://bucket/prefix and waits for it to finish unless noWait. the table
+// needs point-in-time recovery enabled.
+func DynamoDBExportToS3(ctx context.Context, tableName, bucket, prefix string, noWait bool) (*ddbtypes.ExportDescription, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "DynamoDBExportToS3"}
+		d.Start()
+		defer d.End()
+	}
+	tableArn, err := DynamoDBArn(ctx, tableName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	input := &dynamodb.ExportTableToPointInTimeInput{
+		TableArn:     aws.String(tableArn),
+		S3Bucket:     aws.String(bucket),
+		ExportFormat: ddbtypes.ExportFormatDynamodbJson,
+	}
+	if prefix != "" {
+		input.S3Prefix = aws.String(prefix)
+	}
+	out, err := DynamoDBClient().ExportTableToPointInTime(ctx, input)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	Logger.Println("dynamodb exporting:", tableName, "s3://"+bucket+"/"+prefix)
+	export := out.ExportDescription
+	for !noWait && export.ExportStatus == ddbtypes.ExportStatusInProgress {
+		time.Sleep(10 * time.Second)
+		out, err := DynamoDBClient().DescribeExport(ctx, &dynamodb.DescribeExportInput{
+			ExportArn: export.ExportArn,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		export = out.ExportDescription
+	}
+	if export.ExportStatus == ddbtypes.ExportStatusFailed {
+		err := fmt.Errorf("dynamodb export failed: %s %s %s", tableName, aws.ToString(export.FailureCode), aws.ToString(export.FailureMessage))
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return export, nil
+}
+
+type DynamoDBUpdateInput struct {
+	TableName    string
+	Keys         []string // like user:s:john
+	Set          []string // like name:s:jane, age:n:30 or admin:b:true
+	Remove       []string // attribute names
+	Add          []string // like visits:n:1
+	Conditions   []string // like version:n:3 or age:n:<:30, which must all match
+	Exists       []string // attribute names which must exist
+	NotExists    []string // attribute names which must not exist
+	ReturnValues ddbtypes.ReturnValue
+}
+
+// dynamoDBAttrValue parses an attribute like dynamodb-item-put: name:s|n|b:value
+func dynamoDBAttrValue(attr string) (string, ddbtypes.AttributeValue, error) {
+	name, kind, value, err := SplitTwice(attr, ":")
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", nil, err
+	}
+	if strings.ToUpper(kind) == "B" {
+		if value != "true" && value != "false" {
+			err := fmt.Errorf("bool value should be true or false, got: %s", attr)
+			Logger.Println("error:", err)
+			return "", nil, err
+		}
+		return name, &ddbtypes.AttributeValueMemberBOOL{Value: value == "true"}, nil
+	}
+	av, err := dynamoDBKeyAttributeValue(kind, value)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", nil, err
+	}
+	return name, av, nil
+}
+
+// DynamoDBUpdateItemInput builds an update with SET, REMOVE and ADD clauses and
+// a condition that all of Conditions, Exists and NotExists hold. refs are like
+// #s0 and :s0 for set, #r0 for remove, #a0 and :a0 for add, and #c0, :c00, #e0
+// and #x0 for conditions.
+func DynamoDBUpdateItemInput(input *DynamoDBUpdateInput) (*dynamodb.UpdateItemInput, error) {
+	if len(input.Set)+len(input.Remove)+len(input.Add) == 0 {
+		err := fmt.Errorf("update needs at least one set, remove or add: %s", input.TableName)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	update := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(input.TableName),
+		Key:                       map[string]ddbtypes.AttributeValue{},
+		ExpressionAttributeNames:  map[string]string{},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{},
+		ReturnValues:              input.ReturnValues,
+	}
+	for _, key := range input.Keys {
+		name, av, err := DynamoDBKeyValue(key)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		update.Key[name] = av
+	}
+	var clauses []string
+	var sets []string
+	for i, attr := range input.Set {
+		name, av, err := dynamoDBAttrValue(attr)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		update.ExpressionAttributeNames[fmt.Sprintf("#s%d", i)] = name
+		update.ExpressionAttributeValues[fmt.Sprintf(":s%d", i)] = av
+		sets = append(sets, fmt.Sprintf("#s%d = :s%d", i, i))
+	}
+	if len(sets) != 0 {
+		clauses = append(clauses, "SET "+strings.Join(sets, ", "))
+	}
+	var removes []string
+	for i, name := range input.Remove {
+		update.ExpressionAttributeNames[fmt.Sprintf("#r%d", i)] = name
+		removes = append(removes, fmt.Sprintf("#r%d", i))
+	}
+	if len(removes) != 0 {
+		clauses = append(clauses, "REMOVE "+strings.Join(removes, ", "))
+	}
+	var adds []string
+	for i, attr := range input.Add {
+		name, av, err := dynamoDBAttrValue(attr)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		_, ok := av.(*ddbtypes.AttributeValueMemberN)
+		if !ok {
+			err := fmt.Errorf("add value should be a number like visits:n:1, got: %s", attr)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		update.ExpressionAttributeNames[fmt.Sprintf("#a%d", i)] = name
+		update.ExpressionAttributeValues[fmt.Sprintf(":a%d", i)] = av
+		adds = append(adds, fmt.Sprintf("#a%d :a%d", i, i))
+	}
+	if len(adds) != 0 {
+		clauses = append(clauses, "ADD "+strings.Join(adds, ", "))
+	}
+	update.UpdateExpression = aws.String(strings.Join(clauses, " "))
+	var conditions []string
+	for i, condition := range input.Conditions {
+		expression, err := dynamoDBConditionExpression(condition, i, "#c", ":c", dynamoDBFilterOperators, update.ExpressionAttributeNames, update.ExpressionAttributeValues)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		conditions = append(conditions, expression)
+	}
+	for i, name := range input.Exists {
+		update.ExpressionAttributeNames[fmt.Sprintf("#e%d", i)] = name
+		conditions = append(conditions, fmt.Sprintf("attribute_exists(#e%d)", i))
+	}
+	for i, name := range input.NotExists {
+		update.ExpressionAttributeNames[fmt.Sprintf("#x%d", i)] = name
+		conditions = append(conditions, fmt.Sprintf("attribute_not_exists(#x%d)", i))
+	}
+	if len(conditions) != 0 {
+		update.ConditionExpression = aws.String(strings.Join(conditions, " AND "))
+	}
+	if len(update.ExpressionAttributeValues) == 0 {
+		update.ExpressionAttributeValues = nil
+	}
+	return update, nil
+}
+
+// DynamoDBConditionFailed reports whether a write was rejected by its condition
+func DynamoDBConditionFailed(err error) bool {
+	var conditionFailed *ddbtypes.ConditionalCheckFailedException
+	return errors.As(err, &conditionFailed)
+}
+
+// dynamoDBPutVersionedInput builds a put of item with its version incremented,
+// conditioned on the stored version being unchanged. item is not modified.
+func dynamoDBPutVersionedInput(tableName, versionAttr string, item map[string]ddbtypes.AttributeValue) (*dynamodb.PutItemInput, error) {
+	input := &dynamodb.PutItemInput{
+		TableName:                aws.String(tableName),
+		Item:                     maps.Clone(item),
+		ExpressionAttributeNames: map[string]string{"#v": versionAttr},
+	}
+	version := 0
+	av, ok := item[versionAttr]
+	if ok {
+		n, ok := av.(*ddbtypes.AttributeValueMemberN)
+		if !ok {
+			err := fmt.Errorf("version attribute should be a number: %s %T", versionAttr, av)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		var err error
+		version, err = strconv.Atoi(n.Value)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		input.ConditionExpression = aws.String("#v = :v")
+		input.ExpressionAttributeValues = map[string]ddbtypes.AttributeValue{":v": av}
+	} else {
+		input.ConditionExpression = aws.String("attribute_not_exists(#v)")
+	}
+	input.Item[versionAttr] = &ddbtypes.AttributeValueMemberN{Value: strconv.Itoa(version + 1)}
+	return input, nil
+}
+
+// DynamoDBPutVersioned puts an item only if the stored version attribute still
+// equals the item's, or the item does not exist yet when the item has no
+// version. on success the version is incremented in the table and in item. on
+// a concurrent write the error satisfies DynamoDBConditionFailed.
+func DynamoDBPutVersioned(ctx context.Context, tableName, versionAttr string, item map[string]ddbtypes.AttributeValue) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "DynamoDBPutVersioned"}
+		d.Start()
+		defer d.End()
+	}
+	input, err := dynamoDBPutVersionedInput(tableName, versionAttr, item)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	_, err = DynamoDBClient().PutItem(ctx, input)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	item[versionAttr] = input.Item[versionAttr]
+	return nil
+}
+
+// DynamoDBUpdateVersioned reads an item with a consistent read, calls update
+// to modify it, and writes it with DynamoDBPutVersioned. when another writer
+// wins the race it retries with the fresh item, up to attempts times, after a
+// jittered backoff. update is called with just the key when the item does not
+// exist yet, and must not change the key attributes.
+func DynamoDBUpdateVersioned(ctx context.Context, tableName string, key map[string]ddbtypes.AttributeValue, versionAttr string, attempts int, update func(item map[string]ddbtypes.AttributeValue) error) (map[string]ddbtypes.AttributeValue, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "DynamoDBUpdateVersioned"}
+		d.Start()
+		defer d.End()
+	}
+	var err error
+	backoff := 50 * time.Millisecond
+	for attempt := range max(attempts, 1) {
+		if attempt != 0 {
+			select {
+			case <-time.After(backoff/2 + rand.N(backoff)):
+			case <-ctx.Done():
+				Logger.Println("error:", ctx.Err())
+				return nil, ctx.Err()
+			}
+			backoff = min(backoff*2, 5*time.Second)
+		}
+		var out *dynamodb.GetItemOutput
+		out, err = DynamoDBClient().GetItem(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(tableName),
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		item := out.Item
+		if item == nil {
+			item = maps.Clone(key)
+		}
+		version := item[versionAttr]
+		err = update(item)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for k, v := range key {
+			if !reflect.DeepEqual(item[k], v) {
+				err := fmt.Errorf("dynamodb update should not change key attribute: %s", k)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		}
+		if version == nil {
+			delete(item, versionAttr)
+		} else {
+			item[versionAttr] = version
+		}
+		err = DynamoDBPutVersioned(ctx, tableName, versionAttr, item)
+		if err == nil {
+			return item, nil
+		}
+		if !DynamoDBConditionFailed(err) {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	Logger.Println("error:", err)
+	return nil, err
+}
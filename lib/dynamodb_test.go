@@ -3,8 +3,11 @@ package lib
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -65,6 +68,42 @@ func TestDynamoDBEnsureInput(t *testing.T) {
 		err   bool
 	}
 	tests := []test{
+		{
+			"table",
+			[]string{"userid:s:hash"},
+			[]string{"deletion-protection=true", "class=standard_infrequent_access", "pitr=true", "insights=false"},
+			&dynamodb.CreateTableInput{
+				TableName:   aws.String("table"),
+				BillingMode: ddbtypes.BillingModePayPerRequest,
+				StreamSpecification: &ddbtypes.StreamSpecification{
+					StreamEnabled: aws.Bool(false),
+				},
+				AttributeDefinitions: []ddbtypes.AttributeDefinition{
+					{AttributeName: aws.String("userid"), AttributeType: ddbtypes.ScalarAttributeTypeS},
+				},
+				KeySchema: []ddbtypes.KeySchemaElement{
+					{AttributeName: aws.String("userid"), KeyType: ddbtypes.KeyTypeHash},
+				},
+				DeletionProtectionEnabled: aws.Bool(true),
+				TableClass:                ddbtypes.TableClassStandardInfrequentAccess,
+				Tags:                      []ddbtypes.Tag{{Key: aws.String(infraSetTagName), Value: aws.String("")}},
+			},
+			false,
+		},
+		{
+			"table",
+			[]string{"userid:s:hash"},
+			[]string{"pitr=yes"},
+			&dynamodb.CreateTableInput{},
+			true,
+		},
+		{
+			"table",
+			[]string{"userid:s:hash"},
+			[]string{"class=cold"},
+			&dynamodb.CreateTableInput{},
+			true,
+		},
 		{
 			"table",
 			[]string{"userid:s:hash"},
@@ -363,6 +402,48 @@ func TestDynamoDBEnsureInput(t *testing.T) {
 	}
 }
 
+func TestDynamoDBEnsureInputOptions(t *testing.T) {
+	type test struct {
+		attrs   []string
+		options *DynamoDBEnsureOptions
+	}
+	tests := []test{
+		{
+			[]string{},
+			&DynamoDBEnsureOptions{},
+		},
+		{
+			[]string{"ttl=expires", "pitr=true", "insights=false"},
+			&DynamoDBEnsureOptions{
+				TTL: &ddbtypes.TimeToLiveSpecification{
+					AttributeName: aws.String("expires"),
+					Enabled:       aws.Bool(true),
+				},
+				PointInTimeRecovery: aws.Bool(true),
+				ContributorInsights: aws.Bool(false),
+			},
+		},
+		{
+			[]string{"PointInTimeRecoveryEnabled=false", "ContributorInsightsEnabled=true"},
+			&DynamoDBEnsureOptions{
+				PointInTimeRecovery: aws.Bool(false),
+				ContributorInsights: aws.Bool(true),
+			},
+		},
+	}
+	for _, test := range tests {
+		_, options, err := DynamoDBEnsureInput("", "table", []string{"userid:s:hash"}, test.attrs)
+		if err != nil {
+			t.Errorf("\nerror: %s", err)
+			continue
+		}
+		if !reflect.DeepEqual(options, test.options) {
+			t.Errorf("\ngot:\n%+v\nwant:\n%+v\n", options, test.options)
+			continue
+		}
+	}
+}
+
 func TestDynamoDBEnsureTableSeveralTimes(t *testing.T) {
 	checkAccountDynamoDB()
 	ctx := context.Background()
@@ -1104,3 +1185,524 @@ func TestDynamoDBEnsureTableLocalIndicesCannotBeDeleted(t *testing.T) {
 		return
 	}
 }
+
+func TestDynamoDBQueryInput(t *testing.T) {
+	type test struct {
+		conditions []string
+		expression string
+		values     map[string]ddbtypes.AttributeValue
+		err        bool
+	}
+	tests := []test{
+		{
+			[]string{"user:s:john"},
+			"#k0 = :v00",
+			map[string]ddbtypes.AttributeValue{":v00": &ddbtypes.AttributeValueMemberS{Value: "john"}},
+			false,
+		},
+		{
+			[]string{"user:s:john", "date:n:>=:100"},
+			"#k0 = :v00 AND #k1 >= :v10",
+			map[string]ddbtypes.AttributeValue{
+				":v00": &ddbtypes.AttributeValueMemberS{Value: "john"},
+				":v10": &ddbtypes.AttributeValueMemberN{Value: "100"},
+			},
+			false,
+		},
+		{
+			[]string{"user:s:john", "date:n:between:100:200"},
+			"#k0 = :v00 AND #k1 BETWEEN :v10 AND :v11",
+			map[string]ddbtypes.AttributeValue{
+				":v00": &ddbtypes.AttributeValueMemberS{Value: "john"},
+				":v10": &ddbtypes.AttributeValueMemberN{Value: "100"},
+				":v11": &ddbtypes.AttributeValueMemberN{Value: "200"},
+			},
+			false,
+		},
+		{
+			[]string{"user:s:john", "name:s:begins_with:a:b"},
+			"#k0 = :v00 AND begins_with(#k1, :v10)",
+			map[string]ddbtypes.AttributeValue{
+				":v00": &ddbtypes.AttributeValueMemberS{Value: "john"},
+				":v10": &ddbtypes.AttributeValueMemberS{Value: "a:b"},
+			},
+			false,
+		},
+		{[]string{"user:x:john"}, "", nil, true},
+		{[]string{"user:s:john", "date:n:between:100"}, "", nil, true},
+		{[]string{}, "", nil, true},
+	}
+	for _, test := range tests {
+		input, err := DynamoDBQueryInput("table", "", test.conditions)
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error for: %v", test.conditions)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nerror: %s", err)
+			continue
+		}
+		if *input.KeyConditionExpression != test.expression || !reflect.DeepEqual(input.ExpressionAttributeValues, test.values) {
+			t.Errorf("\ngot:\n%s %v\nwant:\n%s %v\n", *input.KeyConditionExpression, input.ExpressionAttributeValues, test.expression, test.values)
+		}
+	}
+}
+
+func TestDynamoDBScanInput(t *testing.T) {
+	type test struct {
+		input      *DynamoDBScanInput
+		projection string
+		filter     string
+		names      map[string]string
+		values     map[string]ddbtypes.AttributeValue
+		err        bool
+	}
+	tests := []test{
+		{
+			&DynamoDBScanInput{TableName: "table"},
+			"",
+			"",
+			nil,
+			nil,
+			false,
+		},
+		{
+			&DynamoDBScanInput{TableName: "table", Attributes: []string{"name", "age"}},
+			"#p0, #p1",
+			"",
+			map[string]string{"#p0": "name", "#p1": "age"},
+			nil,
+			false,
+		},
+		{
+			&DynamoDBScanInput{TableName: "table", Attributes: []string{"name"}, Filters: []string{"age:n:>:30", "name:s:contains:jo"}},
+			"#p0",
+			"#f0 > :f00 AND contains(#f1, :f10)",
+			map[string]string{"#p0": "name", "#f0": "age", "#f1": "name"},
+			map[string]ddbtypes.AttributeValue{
+				":f00": &ddbtypes.AttributeValueMemberN{Value: "30"},
+				":f10": &ddbtypes.AttributeValueMemberS{Value: "jo"},
+			},
+			false,
+		},
+		{
+			&DynamoDBScanInput{TableName: "table", Filters: []string{"age:x:30"}},
+			"", "", nil, nil, true,
+		},
+	}
+	for _, test := range tests {
+		input, err := dynamoDBScanInput(test.input)
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error for: %v", test.input.Filters)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nerror: %s", err)
+			continue
+		}
+		projection := aws.ToString(input.ProjectionExpression)
+		filter := aws.ToString(input.FilterExpression)
+		if projection != test.projection || filter != test.filter || !reflect.DeepEqual(input.ExpressionAttributeNames, test.names) || !reflect.DeepEqual(input.ExpressionAttributeValues, test.values) {
+			t.Errorf("\ngot:\n%s %s %v %v\nwant:\n%s %s %v %v\n", projection, filter, input.ExpressionAttributeNames, input.ExpressionAttributeValues, test.projection, test.filter, test.names, test.values)
+		}
+	}
+}
+
+func TestDynamoDBItemJSON(t *testing.T) {
+	type test struct {
+		item map[string]ddbtypes.AttributeValue
+		json string
+	}
+	tests := []test{
+		{
+			map[string]ddbtypes.AttributeValue{
+				"user":  &ddbtypes.AttributeValueMemberS{Value: "jane"},
+				"dob":   &ddbtypes.AttributeValueMemberN{Value: "1984"},
+				"admin": &ddbtypes.AttributeValueMemberBOOL{Value: true},
+			},
+			`{"admin":true,"dob":1984,"user":"jane"}`,
+		},
+		{
+			map[string]ddbtypes.AttributeValue{
+				"id":    &ddbtypes.AttributeValueMemberN{Value: "12345678901234567890"},
+				"price": &ddbtypes.AttributeValueMemberN{Value: "1.50"},
+				"gone":  &ddbtypes.AttributeValueMemberNULL{Value: true},
+			},
+			`{"gone":null,"id":12345678901234567890,"price":1.50}`,
+		},
+		{
+			map[string]ddbtypes.AttributeValue{
+				"tags": &ddbtypes.AttributeValueMemberL{Value: []ddbtypes.AttributeValue{
+					&ddbtypes.AttributeValueMemberS{Value: "a"},
+					&ddbtypes.AttributeValueMemberN{Value: "1"},
+				}},
+				"address": &ddbtypes.AttributeValueMemberM{Value: map[string]ddbtypes.AttributeValue{
+					"zip":  &ddbtypes.AttributeValueMemberS{Value: "02134"},
+					"city": &ddbtypes.AttributeValueMemberS{Value: "boston"},
+				}},
+			},
+			`{"address":{"city":"boston","zip":"02134"},"tags":["a",1]}`,
+		},
+		{
+			map[string]ddbtypes.AttributeValue{
+				"ss":   &ddbtypes.AttributeValueMemberSS{Value: []string{"a", "b"}},
+				"ns":   &ddbtypes.AttributeValueMemberNS{Value: []string{"1", "2.5"}},
+				"b":    &ddbtypes.AttributeValueMemberB{Value: []byte("data")},
+				"bs":   &ddbtypes.AttributeValueMemberBS{Value: [][]byte{[]byte("a"), []byte("b")}},
+				"list": &ddbtypes.AttributeValueMemberL{Value: []ddbtypes.AttributeValue{&ddbtypes.AttributeValueMemberSS{Value: []string{"x"}}}},
+			},
+			`{"b":{"B":"ZGF0YQ=="},"bs":{"BS":["YQ==","Yg=="]},"list":[{"SS":["x"]}],"ns":{"NS":[1,2.5]},"ss":{"SS":["a","b"]}}`,
+		},
+		{
+			map[string]ddbtypes.AttributeValue{
+				"SS": &ddbtypes.AttributeValueMemberS{Value: "top level keys are never types"},
+				"m": &ddbtypes.AttributeValueMemberM{Value: map[string]ddbtypes.AttributeValue{
+					"SS": &ddbtypes.AttributeValueMemberL{Value: []ddbtypes.AttributeValue{&ddbtypes.AttributeValueMemberS{Value: "a"}}},
+				}},
+				"mm": &ddbtypes.AttributeValueMemberM{Value: map[string]ddbtypes.AttributeValue{
+					"M": &ddbtypes.AttributeValueMemberM{Value: map[string]ddbtypes.AttributeValue{}},
+				}},
+			},
+			`{"SS":"top level keys are never types","m":{"M":{"SS":["a"]}},"mm":{"M":{"M":{}}}}`,
+		},
+	}
+	for _, test := range tests {
+		data, err := DynamoDBItemToJSON(test.item)
+		if err != nil {
+			t.Errorf("\nerror: %s", err)
+			continue
+		}
+		if string(data) != test.json {
+			t.Errorf("\ngot:\n%s\nwant:\n%s\n", string(data), test.json)
+		}
+		item, err := DynamoDBItemFromJSON(data)
+		if err != nil {
+			t.Errorf("\nerror: %s", err)
+			continue
+		}
+		if !reflect.DeepEqual(item, test.item) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", item, test.item)
+		}
+	}
+	_, err := DynamoDBItemFromJSON([]byte(`[1, 2]`))
+	if err == nil {
+		t.Errorf("\nexpected error for json list")
+	}
+	for _, data := range []string{`{"a":{"SS":"x"}}`, `{"a":{"NS":["1"]}}`, `{"a":{"B":"!"}}`, `{"a":{"M":[1]}}`} {
+		_, err := DynamoDBItemFromJSON([]byte(data))
+		if err == nil {
+			t.Errorf("\nexpected error for: %s", data)
+		}
+	}
+}
+
+type dynamoDBFakeWriteClient struct {
+	lock        sync.Mutex
+	calls       [][]string
+	unprocessed func(call int, id string) bool
+	err         error
+}
+
+func (c *dynamoDBFakeWriteClient) BatchWriteItem(ctx context.Context, input *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	out := &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]ddbtypes.WriteRequest{}}
+	for tableName, reqs := range input.RequestItems {
+		var ids []string
+		for _, req := range reqs {
+			id := req.PutRequest.Item["id"].(*ddbtypes.AttributeValueMemberN).Value
+			ids = append(ids, id)
+			if c.unprocessed != nil && c.unprocessed(len(c.calls), id) {
+				out.UnprocessedItems[tableName] = append(out.UnprocessedItems[tableName], req)
+			}
+		}
+		c.calls = append(c.calls, ids)
+	}
+	return out, nil
+}
+
+type dynamoDBFakeScanClient struct {
+	items []map[string]ddbtypes.AttributeValue
+}
+
+// Scan returns pages of two items, splitting items across segments by index
+func (c *dynamoDBFakeScanClient) Scan(ctx context.Context, input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	segment, segments := 0, 1
+	if input.TotalSegments != nil {
+		segment, segments = int(*input.Segment), int(*input.TotalSegments)
+	}
+	var items []map[string]ddbtypes.AttributeValue
+	for i, item := range c.items {
+		if i%segments == segment {
+			items = append(items, item)
+		}
+	}
+	offset := 0
+	if input.ExclusiveStartKey != nil {
+		offset, _ = strconv.Atoi(input.ExclusiveStartKey["offset"].(*ddbtypes.AttributeValueMemberN).Value)
+	}
+	end := min(offset+2, len(items))
+	out := &dynamodb.ScanOutput{Items: items[offset:end]}
+	if end < len(items) {
+		out.LastEvaluatedKey = map[string]ddbtypes.AttributeValue{
+			"offset": &ddbtypes.AttributeValueMemberN{Value: fmt.Sprint(end)},
+		}
+	}
+	return out, nil
+}
+
+func dynamoDBFakeItems(n int) []map[string]ddbtypes.AttributeValue {
+	var items []map[string]ddbtypes.AttributeValue
+	for i := range n {
+		items = append(items, map[string]ddbtypes.AttributeValue{
+			"id": &ddbtypes.AttributeValueMemberN{Value: fmt.Sprint(i)},
+		})
+	}
+	return items
+}
+
+func TestDynamoDBBatchPut(t *testing.T) {
+	client := &dynamoDBFakeWriteClient{
+		unprocessed: func(call int, id string) bool {
+			return call == 0 && (id == "3" || id == "7")
+		},
+	}
+	err := dynamoDBBatchPut(context.Background(), client, "table", dynamoDBFakeItems(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	for _, ids := range client.calls {
+		sizes = append(sizes, len(ids))
+	}
+	if !reflect.DeepEqual(sizes, []int{25, 2, 5}) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", sizes, []int{25, 2, 5})
+	}
+	if !reflect.DeepEqual(client.calls[1], []string{"3", "7"}) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", client.calls[1], []string{"3", "7"})
+	}
+	client = &dynamoDBFakeWriteClient{err: fmt.Errorf("ValidationException")}
+	err = dynamoDBBatchPut(context.Background(), client, "table", dynamoDBFakeItems(3))
+	if err == nil {
+		t.Errorf("\nexpected error")
+	}
+}
+
+func TestDynamoDBCopy(t *testing.T) {
+	for _, segments := range []int{1, 3} {
+		source := &dynamoDBFakeScanClient{items: dynamoDBFakeItems(60)}
+		retried := false
+		destination := &dynamoDBFakeWriteClient{
+			unprocessed: func(call int, id string) bool {
+				if id == "0" && !retried {
+					retried = true
+					return true
+				}
+				return false
+			},
+		}
+		count, err := dynamoDBCopy(context.Background(), source, destination, &DynamoDBCopyInput{
+			Source:      "source",
+			Destination: "destination",
+			Segments:    segments,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 60 {
+			t.Errorf("\ngot:\n%d\nwant:\n%d\n", count, 60)
+		}
+		written := map[string]int{}
+		for _, ids := range destination.calls {
+			if len(ids) > dynamoDBBatchWriteSize {
+				t.Errorf("\nbatch too large: %d", len(ids))
+			}
+			for _, id := range ids {
+				written[id]++
+			}
+		}
+		for _, item := range source.items {
+			id := item["id"].(*ddbtypes.AttributeValueMemberN).Value
+			expected := 1
+			if id == "0" {
+				expected = 2 // unprocessed once, then retried
+			}
+			if written[id] != expected {
+				t.Errorf("\nitem %s written %d times", id, written[id])
+			}
+		}
+	}
+	destination := &dynamoDBFakeWriteClient{err: fmt.Errorf("ValidationException")}
+	_, err := dynamoDBCopy(context.Background(), &dynamoDBFakeScanClient{items: dynamoDBFakeItems(60)}, destination, &DynamoDBCopyInput{
+		Source:      "source",
+		Destination: "destination",
+		Segments:    2,
+	})
+	if err == nil {
+		t.Errorf("\nexpected error")
+	}
+	_, err = DynamoDBCopy(context.Background(), &DynamoDBCopyInput{
+		Source:      "table",
+		Destination: "table",
+	})
+	if err == nil {
+		t.Errorf("\nexpected error for same source and destination")
+	}
+}
+
+func TestDynamoDBUpdateItemInput(t *testing.T) {
+	type test struct {
+		input     *DynamoDBUpdateInput
+		update    string
+		condition string
+		names     map[string]string
+		values    map[string]ddbtypes.AttributeValue
+		err       bool
+	}
+	tests := []test{
+		{
+			&DynamoDBUpdateInput{Keys: []string{"user:s:john"}, Set: []string{"name:s:jane", "admin:b:true"}},
+			"SET #s0 = :s0, #s1 = :s1",
+			"",
+			map[string]string{"#s0": "name", "#s1": "admin"},
+			map[string]ddbtypes.AttributeValue{
+				":s0": &ddbtypes.AttributeValueMemberS{Value: "jane"},
+				":s1": &ddbtypes.AttributeValueMemberBOOL{Value: true},
+			},
+			false,
+		},
+		{
+			&DynamoDBUpdateInput{Keys: []string{"user:s:john"}, Remove: []string{"nickname"}, Exists: []string{"user"}},
+			"REMOVE #r0",
+			"attribute_exists(#e0)",
+			map[string]string{"#r0": "nickname", "#e0": "user"},
+			nil,
+			false,
+		},
+		{
+			&DynamoDBUpdateInput{
+				Keys:       []string{"user:s:john"},
+				Set:        []string{"email:s:j@example.com"},
+				Add:        []string{"version:n:1"},
+				Conditions: []string{"version:n:3"},
+				NotExists:  []string{"deleted"},
+			},
+			"SET #s0 = :s0 ADD #a0 :a0",
+			"#c0 = :c00 AND attribute_not_exists(#x0)",
+			map[string]string{"#s0": "email", "#a0": "version", "#c0": "version", "#x0": "deleted"},
+			map[string]ddbtypes.AttributeValue{
+				":s0":  &ddbtypes.AttributeValueMemberS{Value: "j@example.com"},
+				":a0":  &ddbtypes.AttributeValueMemberN{Value: "1"},
+				":c00": &ddbtypes.AttributeValueMemberN{Value: "3"},
+			},
+			false,
+		},
+		{&DynamoDBUpdateInput{Keys: []string{"user:s:john"}}, "", "", nil, nil, true},
+		{&DynamoDBUpdateInput{Keys: []string{"user:s:john"}, Add: []string{"name:s:jane"}}, "", "", nil, nil, true},
+		{&DynamoDBUpdateInput{Keys: []string{"user:s:john"}, Set: []string{"admin:b:yes"}}, "", "", nil, nil, true},
+		{&DynamoDBUpdateInput{Keys: []string{"user:b:true"}, Set: []string{"name:s:jane"}}, "", "", nil, nil, true},
+	}
+	for _, test := range tests {
+		input, err := DynamoDBUpdateItemInput(test.input)
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error for: %+v", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nerror: %s", err)
+			continue
+		}
+		update := aws.ToString(input.UpdateExpression)
+		condition := aws.ToString(input.ConditionExpression)
+		if update != test.update || condition != test.condition || !reflect.DeepEqual(input.ExpressionAttributeNames, test.names) || !reflect.DeepEqual(input.ExpressionAttributeValues, test.values) {
+			t.Errorf("\ngot:\n%s | %s %v %v\nwant:\n%s | %s %v %v\n", update, condition, input.ExpressionAttributeNames, input.ExpressionAttributeValues, test.update, test.condition, test.names, test.values)
+		}
+	}
+}
+
+func TestDynamoDBPutVersionedInput(t *testing.T) {
+	type test struct {
+		item      map[string]ddbtypes.AttributeValue
+		condition string
+		values    map[string]ddbtypes.AttributeValue
+		version   string
+		err       bool
+	}
+	tests := []test{
+		{
+			map[string]ddbtypes.AttributeValue{
+				"id": &ddbtypes.AttributeValueMemberS{Value: "a"},
+			},
+			"attribute_not_exists(#v)",
+			nil,
+			"1",
+			false,
+		},
+		{
+			map[string]ddbtypes.AttributeValue{
+				"id":      &ddbtypes.AttributeValueMemberS{Value: "a"},
+				"version": &ddbtypes.AttributeValueMemberN{Value: "3"},
+			},
+			"#v = :v",
+			map[string]ddbtypes.AttributeValue{":v": &ddbtypes.AttributeValueMemberN{Value: "3"}},
+			"4",
+			false,
+		},
+		{
+			map[string]ddbtypes.AttributeValue{
+				"id":      &ddbtypes.AttributeValueMemberS{Value: "a"},
+				"version": &ddbtypes.AttributeValueMemberS{Value: "3"},
+			},
+			"",
+			nil,
+			"",
+			true,
+		},
+		{
+			map[string]ddbtypes.AttributeValue{
+				"id":      &ddbtypes.AttributeValueMemberS{Value: "a"},
+				"version": &ddbtypes.AttributeValueMemberN{Value: "1.5"},
+			},
+			"",
+			nil,
+			"",
+			true,
+		},
+	}
+	for _, test := range tests {
+		original := maps.Clone(test.item)
+		input, err := dynamoDBPutVersionedInput("table", "version", test.item)
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nerror: %s", err)
+			continue
+		}
+		if !reflect.DeepEqual(test.item, original) {
+			t.Errorf("\nitem should not be modified:\n%v", test.item)
+		}
+		if *input.ConditionExpression != test.condition || input.ExpressionAttributeNames["#v"] != "version" || !reflect.DeepEqual(input.ExpressionAttributeValues, test.values) {
+			t.Errorf("\ngot:\n%s %v %v\nwant:\n%s %v\n", *input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, test.condition, test.values)
+		}
+		version := input.Item["version"].(*ddbtypes.AttributeValueMemberN).Value
+		if version != test.version {
+			t.Errorf("\ngot:\n%s\nwant:\n%s\n", version, test.version)
+		}
+		if !reflect.DeepEqual(input.Item["id"], test.item["id"]) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", input.Item["id"], test.item["id"])
+		}
+	}
+}
@@ -2,6 +2,9 @@ package lib
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -102,3 +105,523 @@ func EventsListRuleTargets(ctx context.Context, ruleName string, busName *string
 	}
 	return targets, nil
 }
+
+const (
+	eventsBusAttrArchive              = "archive"
+	eventsBusAttrArchiveRetentionDays = "archive-retention-days"
+
+	eventsBusDefault    = "default"
+	eventsPutBatchSize  = 10
+	eventsPutMaxBytes   = 256 * 1024 // total size of a put events request
+	eventsArchiveMaxLen = 48
+)
+
+type eventsEnsureBusInput struct {
+	infraSetName         string
+	name                 string
+	archive              bool
+	archiveRetentionDays int32 // 0 retains events indefinitely
+}
+
+func EventsEnsureBusInput(infraSetName, busName string, attrs []string) (*eventsEnsureBusInput, error) {
+	input := &eventsEnsureBusInput{
+		infraSetName: infraSetName,
+		name:         busName,
+	}
+	for _, attr := range attrs {
+		k, v, err := SplitOnce(attr, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case eventsBusAttrArchive:
+			if v != "true" && v != "false" {
+				err := fmt.Errorf("event bus attr %s should be true or false, got: %s", k, v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.archive = v == "true"
+		case eventsBusAttrArchiveRetentionDays:
+			if !IsDigit(v) || Atoi(v) < 0 {
+				err := fmt.Errorf("event bus attr %s should be a number of days, got: %s", k, v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.archiveRetentionDays = int32(Atoi(v))
+		default:
+			err := fmt.Errorf("unknown event bus attr: %s", attr)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	if input.archiveRetentionDays != 0 && !input.archive {
+		err := fmt.Errorf("event bus attr %s requires %s=true: %s", eventsBusAttrArchiveRetentionDays, eventsBusAttrArchive, busName)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if input.archive && len(EventsArchiveName(busName)) > eventsArchiveMaxLen {
+		err := fmt.Errorf("event bus name must be at most %d characters to be archived: %s", eventsArchiveMaxLen, busName)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return input, nil
+}
+
+// EventsArchiveName is the name of the archive managed for a bus
+func EventsArchiveName(busName string) string {
+	return busName
+}
+
+func EventsBusArn(ctx context.Context, busName string) (string, error) {
+	out, err := EventsClient().DescribeEventBus(ctx, &eventbridge.DescribeEventBusInput{
+		Name: aws.String(busName),
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	return *out.Arn, nil
+}
+
+func EventsEnsureBus(ctx context.Context, input *eventsEnsureBusInput, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "EventsEnsureBus"}
+		d.Start()
+		defer d.End()
+	}
+	var busArn string
+	out, err := EventsClient().DescribeEventBus(ctx, &eventbridge.DescribeEventBusInput{
+		Name: aws.String(input.name),
+	})
+	if err != nil {
+		var rnfe *eventbridgetypes.ResourceNotFoundException
+		if !errors.As(err, &rnfe) {
+			Logger.Println("error:", err)
+			return err
+		}
+		if !preview {
+			out, err := EventsClient().CreateEventBus(ctx, &eventbridge.CreateEventBusInput{
+				Name: aws.String(input.name),
+				Tags: []eventbridgetypes.Tag{{
+					Key:   aws.String(infraSetTagName),
+					Value: aws.String(input.infraSetName),
+				}},
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			busArn = *out.EventBusArn
+		}
+		Logger.Println(PreviewString(preview)+"created event bus:", input.name)
+	} else {
+		busArn = *out.Arn
+	}
+	archiveName := EventsArchiveName(input.name)
+	archive, err := EventsClient().DescribeArchive(ctx, &eventbridge.DescribeArchiveInput{
+		ArchiveName: aws.String(archiveName),
+	})
+	if err != nil {
+		var rnfe *eventbridgetypes.ResourceNotFoundException
+		if !errors.As(err, &rnfe) {
+			Logger.Println("error:", err)
+			return err
+		}
+		archive = nil
+	}
+	if archive != nil && busArn != "" && *archive.EventSourceArn != busArn {
+		err := fmt.Errorf("archive exists for another event source: %s %s", archiveName, *archive.EventSourceArn)
+		Logger.Println("error:", err)
+		return err
+	}
+	switch {
+	case input.archive && archive == nil:
+		if !preview {
+			_, err := EventsClient().CreateArchive(ctx, &eventbridge.CreateArchiveInput{
+				ArchiveName:    aws.String(archiveName),
+				EventSourceArn: aws.String(busArn),
+				RetentionDays:  aws.Int32(input.archiveRetentionDays),
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"created event archive:", archiveName, fmt.Sprintf("%s=%d", eventsBusAttrArchiveRetentionDays, input.archiveRetentionDays))
+	case input.archive && aws.ToInt32(archive.RetentionDays) != input.archiveRetentionDays:
+		if !preview {
+			_, err := EventsClient().UpdateArchive(ctx, &eventbridge.UpdateArchiveInput{
+				ArchiveName:   aws.String(archiveName),
+				RetentionDays: aws.Int32(input.archiveRetentionDays),
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"updated event archive:", archiveName, fmt.Sprintf("%s: %d => %d", eventsBusAttrArchiveRetentionDays, aws.ToInt32(archive.RetentionDays), input.archiveRetentionDays))
+	case !input.archive && archive != nil:
+		err := EventsDeleteArchive(ctx, archiveName, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func EventsDeleteArchive(ctx context.Context, archiveName string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "EventsDeleteArchive"}
+		d.Start()
+		defer d.End()
+	}
+	if !preview {
+		_, err := EventsClient().DeleteArchive(ctx, &eventbridge.DeleteArchiveInput{
+			ArchiveName: aws.String(archiveName),
+		})
+		if err != nil {
+			var rnfe *eventbridgetypes.ResourceNotFoundException
+			if errors.As(err, &rnfe) {
+				return nil
+			}
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	Logger.Println(PreviewString(preview)+"deleted event archive:", archiveName)
+	return nil
+}
+
+// EventsDeleteBus deletes a bus with its archive and rules, the default bus and its archive are never deleted
+func EventsDeleteBus(ctx context.Context, busName string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "EventsDeleteBus"}
+		d.Start()
+		defer d.End()
+	}
+	if busName == eventsBusDefault {
+		return nil // the default bus and its archive may be shared with other infra sets
+	}
+	busArn, err := EventsBusArn(ctx, busName)
+	if err != nil {
+		var rnfe *eventbridgetypes.ResourceNotFoundException
+		if errors.As(err, &rnfe) {
+			return nil
+		}
+		Logger.Println("error:", err)
+		return err
+	}
+	archiveName := EventsArchiveName(busName)
+	archive, err := EventsClient().DescribeArchive(ctx, &eventbridge.DescribeArchiveInput{
+		ArchiveName: aws.String(archiveName),
+	})
+	if err == nil && *archive.EventSourceArn == busArn {
+		err := EventsDeleteArchive(ctx, archiveName, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	rules, err := EventsListRules(ctx, aws.String(busName))
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, rule := range rules {
+		err := EventsDeleteRule(ctx, busName, *rule.Name, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	if !preview {
+		_, err := EventsClient().DeleteEventBus(ctx, &eventbridge.DeleteEventBusInput{
+			Name: aws.String(busName),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	Logger.Println(PreviewString(preview)+"deleted event bus:", busName)
+	return nil
+}
+
+// EventsEnsureRule ensures a rule on a bus with an event pattern and a single target,
+// returning the rule arn. the target must already allow events.amazonaws.com.
+func EventsEnsureRule(ctx context.Context, infraSetName, busName, ruleName, pattern, targetArn string, preview bool) (string, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "EventsEnsureRule"}
+		d.Start()
+		defer d.End()
+	}
+	var ruleArn string
+	out, err := EventsClient().DescribeRule(ctx, &eventbridge.DescribeRuleInput{
+		Name:         aws.String(ruleName),
+		EventBusName: aws.String(busName),
+	})
+	if err != nil {
+		var rnfe *eventbridgetypes.ResourceNotFoundException
+		if !errors.As(err, &rnfe) {
+			Logger.Println("error:", err)
+			return "", err
+		}
+		if !preview {
+			out, err := EventsClient().PutRule(ctx, &eventbridge.PutRuleInput{
+				Name:         aws.String(ruleName),
+				EventBusName: aws.String(busName),
+				EventPattern: aws.String(pattern),
+				Tags: []eventbridgetypes.Tag{{
+					Key:   aws.String(infraSetTagName),
+					Value: aws.String(infraSetName),
+				}},
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return "", err
+			}
+			ruleArn = *out.RuleArn
+		}
+		Logger.Println(PreviewString(preview)+"created event rule:", busName, ruleName, pattern)
+	} else {
+		if out.EventPattern == nil || *out.EventPattern != pattern {
+			err := fmt.Errorf("event rule misconfigured: %s %s != %s", ruleName, pattern, aws.ToString(out.EventPattern))
+			Logger.Println("error:", err)
+			return "", err
+		}
+		ruleArn = *out.Arn
+	}
+	var targets []eventbridgetypes.Target
+	err = Retry(ctx, func() error {
+		var err error
+		targets, err = EventsListRuleTargets(ctx, ruleName, aws.String(busName))
+		var rnfe *eventbridgetypes.ResourceNotFoundException
+		if errors.As(err, &rnfe) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	switch len(targets) {
+	case 0:
+		if !preview {
+			_, err := EventsClient().PutTargets(ctx, &eventbridge.PutTargetsInput{
+				Rule:         aws.String(ruleName),
+				EventBusName: aws.String(busName),
+				Targets: []eventbridgetypes.Target{{
+					Id:  aws.String("1"),
+					Arn: aws.String(targetArn),
+				}},
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return "", err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"created event rule target:", busName, ruleName, targetArn)
+	case 1:
+		if *targets[0].Arn != targetArn {
+			err := fmt.Errorf("event rule is misconfigured with unknown target: %s %s", targetArn, *targets[0].Arn)
+			Logger.Println("error:", err)
+			return "", err
+		}
+	default:
+		var targetArns []string
+		for _, target := range targets {
+			targetArns = append(targetArns, *target.Arn)
+		}
+		err := fmt.Errorf("event rule is misconfigured with unknown targets: %s %v", targetArn, targetArns)
+		Logger.Println("error:", err)
+		return "", err
+	}
+	return ruleArn, nil
+}
+
+func EventsDeleteRule(ctx context.Context, busName, ruleName string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "EventsDeleteRule"}
+		d.Start()
+		defer d.End()
+	}
+	targets, err := EventsListRuleTargets(ctx, ruleName, aws.String(busName))
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	if !preview {
+		ids := []string{}
+		for _, target := range targets {
+			ids = append(ids, *target.Id)
+		}
+		if len(ids) > 0 {
+			_, err := EventsClient().RemoveTargets(ctx, &eventbridge.RemoveTargetsInput{
+				Rule:         aws.String(ruleName),
+				EventBusName: aws.String(busName),
+				Ids:          ids,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		_, err = EventsClient().DeleteRule(ctx, &eventbridge.DeleteRuleInput{
+			Name:         aws.String(ruleName),
+			EventBusName: aws.String(busName),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	Logger.Println(PreviewString(preview)+"deleted event rule:", busName, ruleName)
+	return nil
+}
+
+type EventsPutEntry struct {
+	Source     string
+	DetailType string
+	Detail     string // json object
+}
+
+// eventsEntrySize is the size of an entry as counted by eventbridge
+func eventsEntrySize(entry EventsPutEntry) int {
+	return len(entry.Source) + len(entry.DetailType) + len(entry.Detail)
+}
+
+// eventsPutChunks splits entries into batches of at most ten entries and
+// eventsPutMaxBytes, failing on any entry larger than that.
+func eventsPutChunks(entries []EventsPutEntry) ([][]EventsPutEntry, error) {
+	var chunks [][]EventsPutEntry
+	var chunk []EventsPutEntry
+	chunkSize := 0
+	for _, entry := range entries {
+		size := eventsEntrySize(entry)
+		if size > eventsPutMaxBytes {
+			err := fmt.Errorf("event is %d bytes, which is over the limit of %d bytes: %s %s", size, eventsPutMaxBytes, entry.Source, entry.DetailType)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if len(chunk) == eventsPutBatchSize || (len(chunk) > 0 && chunkSize+size > eventsPutMaxBytes) {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			chunkSize = 0
+		}
+		chunk = append(chunk, entry)
+		chunkSize += size
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// EventsPut publishes events to a bus in batches of up to ten entries and
+// 256KiB, failing if any entry is rejected
+func EventsPut(ctx context.Context, busName string, entries []EventsPutEntry) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "EventsPut"}
+		d.Start()
+		defer d.End()
+	}
+	chunks, err := eventsPutChunks(entries)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, batch := range chunks {
+		var requestEntries []eventbridgetypes.PutEventsRequestEntry
+		for _, entry := range batch {
+			requestEntries = append(requestEntries, eventbridgetypes.PutEventsRequestEntry{
+				EventBusName: aws.String(busName),
+				Source:       aws.String(entry.Source),
+				DetailType:   aws.String(entry.DetailType),
+				Detail:       aws.String(entry.Detail),
+			})
+		}
+		out, err := EventsClient().PutEvents(ctx, &eventbridge.PutEventsInput{
+			Entries: requestEntries,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if out.FailedEntryCount > 0 {
+			for _, entry := range out.Entries {
+				if entry.ErrorCode != nil {
+					err := fmt.Errorf("failed to put event: %s %s", *entry.ErrorCode, aws.ToString(entry.ErrorMessage))
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// EventsReplay replays archived events from a time window into a bus and waits for completion.
+// eventbridge only replays into the bus the archive was created for.
+func EventsReplay(ctx context.Context, archiveName, busName string, start, end time.Time) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "EventsReplay"}
+		d.Start()
+		defer d.End()
+	}
+	archive, err := EventsClient().DescribeArchive(ctx, &eventbridge.DescribeArchiveInput{
+		ArchiveName: aws.String(archiveName),
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	busArn, err := EventsBusArn(ctx, busName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	replayName := fmt.Sprintf("%s-%d", archiveName, time.Now().Unix())
+	_, err = EventsClient().StartReplay(ctx, &eventbridge.StartReplayInput{
+		ReplayName:     aws.String(replayName),
+		EventSourceArn: archive.ArchiveArn,
+		EventStartTime: aws.Time(start),
+		EventEndTime:   aws.Time(end),
+		Destination: &eventbridgetypes.ReplayDestination{
+			Arn: aws.String(busArn),
+		},
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	Logger.Println("started replay:", replayName, start.Format(time.RFC3339), end.Format(time.RFC3339), "=>", busName)
+	for {
+		out, err := EventsClient().DescribeReplay(ctx, &eventbridge.DescribeReplayInput{
+			ReplayName: aws.String(replayName),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		switch out.State {
+		case eventbridgetypes.ReplayStateCompleted:
+			Logger.Println("completed replay:", replayName)
+			return nil
+		case eventbridgetypes.ReplayStateFailed, eventbridgetypes.ReplayStateCancelled:
+			err := fmt.Errorf("replay %s: %s %s", strings.ToLower(string(out.State)), replayName, aws.ToString(out.StateReason))
+			Logger.Println("error:", err)
+			return err
+		}
+		if out.EventLastReplayedTime != nil {
+			Logger.Println("replaying:", replayName, out.EventLastReplayedTime.Format(time.RFC3339))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
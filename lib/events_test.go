@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEventsEnsureBusInput(t *testing.T) {
+	type test struct {
+		name     string
+		attrs    []string
+		expected *eventsEnsureBusInput
+		err      bool
+	}
+	tests := []test{
+		{"test-bus", nil, &eventsEnsureBusInput{name: "test-bus"}, false},
+		{"test-bus", []string{"archive=true"}, &eventsEnsureBusInput{name: "test-bus", archive: true}, false},
+		{"test-bus", []string{"archive=true", "archive-retention-days=7"}, &eventsEnsureBusInput{name: "test-bus", archive: true, archiveRetentionDays: 7}, false},
+		{"test-bus", []string{"archive=false"}, &eventsEnsureBusInput{name: "test-bus"}, false},
+		{"test-bus", []string{"archive=yes"}, nil, true},
+		{"test-bus", []string{"archive-retention-days=-1"}, nil, true},
+		{"test-bus", []string{"archive-retention-days=7"}, nil, true},
+		{"test-bus", []string{"archive=false", "archive-retention-days=7"}, nil, true},
+		{"test-bus", []string{"unknown=true"}, nil, true},
+		{"test-bus", []string{"archive"}, nil, true},
+		{strings.Repeat("a", eventsArchiveMaxLen), []string{"archive=true"}, &eventsEnsureBusInput{name: strings.Repeat("a", eventsArchiveMaxLen), archive: true}, false},
+		{strings.Repeat("a", eventsArchiveMaxLen+1), []string{"archive=true"}, nil, true},
+		{strings.Repeat("a", eventsArchiveMaxLen+1), nil, &eventsEnsureBusInput{name: strings.Repeat("a", eventsArchiveMaxLen+1)}, false},
+	}
+	for _, test := range tests {
+		input, err := EventsEnsureBusInput("", test.name, test.attrs)
+		if (err != nil) != test.err {
+			t.Errorf("\nattrs: %v\nexpected err: %v\ngot: %v\n", test.attrs, test.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(input, test.expected) {
+			t.Errorf("\nattrs: %v\ngot:\n%+v\nwant:\n%+v\n", test.attrs, input, test.expected)
+		}
+	}
+}
+
+func TestEventsPutChunks(t *testing.T) {
+	type test struct {
+		sizes    []int
+		expected []int
+		err      bool
+	}
+	tests := []test{
+		{[]int{1, 1, 1}, []int{3}, false},
+		{[]int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}, []int{10, 2}, false},
+		{[]int{100 * 1024, 100 * 1024, 100 * 1024}, []int{2, 1}, false},
+		{[]int{eventsPutMaxBytes, 1}, []int{1, 1}, false},
+		{[]int{1, eventsPutMaxBytes + 1}, nil, true},
+	}
+	for _, test := range tests {
+		var entries []EventsPutEntry
+		for _, size := range test.sizes {
+			entries = append(entries, EventsPutEntry{Detail: strings.Repeat("x", size)})
+		}
+		chunks, err := eventsPutChunks(entries)
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error for sizes: %v", test.sizes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nerror: %s", err)
+			continue
+		}
+		var sizes []int
+		for _, chunk := range chunks {
+			sizes = append(sizes, len(chunk))
+		}
+		if !reflect.DeepEqual(sizes, test.expected) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", sizes, test.expected)
+		}
+	}
+}
@@ -744,6 +744,78 @@ func iamPolicyEqual(a, b string) (bool, error) {
 	return reflect.DeepEqual(aData, bData), nil
 }
 
+// iamServicePolicySid is the resource policy statement id allowing a service to act for a source arn
+func iamServicePolicySid(service, sourceArn string) string {
+	return strings.Split(service, ".")[0] + "-" + sha256Hex([]byte(sourceArn))[:16]
+}
+
+// iamPolicyWithStatement sets or, if statement is nil, removes the statement with
+// sid in a resource policy, returning the new policy, which is empty when no
+// statements remain, and whether it changed
+func iamPolicyWithStatement(policyJSON, sid string, statement map[string]any) (string, bool, error) {
+	policy := map[string]any{
+		"Version":   "2012-10-17",
+		"Statement": []any{},
+	}
+	if policyJSON != "" {
+		err := json.Unmarshal([]byte(policyJSON), &policy)
+		if err != nil {
+			Logger.Println("error:", err)
+			return "", false, err
+		}
+	}
+	statements, ok := policy["Statement"].([]any)
+	if !ok {
+		statements = []any{policy["Statement"]} // a single statement may be an object
+	}
+	var newStatements []any
+	found := false
+	for _, s := range statements {
+		m, ok := s.(map[string]any)
+		if ok && m["Sid"] == sid {
+			if statement != nil {
+				data, err := json.Marshal(statement)
+				if err != nil {
+					Logger.Println("error:", err)
+					return "", false, err
+				}
+				existing, err := json.Marshal(m)
+				if err != nil {
+					Logger.Println("error:", err)
+					return "", false, err
+				}
+				equal, err := iamPolicyEqual(string(existing), string(data))
+				if err != nil {
+					Logger.Println("error:", err)
+					return "", false, err
+				}
+				if equal {
+					return policyJSON, false, nil
+				}
+			}
+			found = true
+			continue
+		}
+		newStatements = append(newStatements, s)
+	}
+	if statement == nil && !found {
+		return policyJSON, false, nil
+	}
+	if statement != nil {
+		newStatements = append(newStatements, statement)
+	}
+	if len(newStatements) == 0 {
+		return "", true, nil
+	}
+	policy["Statement"] = newStatements
+	data, err := json.Marshal(policy)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
 func IamEnsureUserPolicies(ctx context.Context, username string, policyNames []string, preview bool) error {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "IamEnsureUserPolicies"}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/apigatewayv2"
+	apitypes "github.com/aws/aws-sdk-go-v2/service/apigatewayv2/types"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	logstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -27,10 +28,14 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	events "github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
 	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"gopkg.in/yaml.v3"
@@ -56,6 +61,10 @@ const (
 	infraKeyKeypair         = "keypair"
 	infraKeyVpc             = "vpc"
 	infraKeyInstanceProfile = "instance-profile"
+	infraKeyApi             = "api"
+	infraKeyEventBus        = "event-bus"
+	infraKeySns             = "sns"
+	infraKeyKinesis         = "kinesis"
 )
 
 type InfraSet struct {
@@ -70,6 +79,9 @@ type InfraSet struct {
 	DynamoDB map[string]*InfraDynamoDB `yaml:"dynamodb,omitempty"`
 	SQS      map[string]*InfraSQS      `yaml:"sqs,omitempty"`
 	S3       map[string]*InfraS3       `yaml:"s3,omitempty"`
+	Kinesis  map[string]*InfraKinesis  `yaml:"kinesis,omitempty"`
+	SNS      map[string]*InfraSNS      `yaml:"sns,omitempty"`
+	EventBus map[string]*InfraEventBus `yaml:"event-bus,omitempty"`
 
 	// ec2 infra
 	Keypair         map[string]*InfraKeypair         `yaml:"keypair,omitempty"`
@@ -83,12 +95,21 @@ type InfraSet struct {
 	Event map[string]*InfraEvent `yaml:"event,omitempty"` // any event  not associated with an infraset shows up here
 }
 
+const (
+	infraKeyApiDns    = "dns"
+	infraKeyApiDomain = "domain"
+	infraKeyApiRoute  = "route"
+	infraKeyApiAttr   = "attr"
+)
+
 type InfraApi struct {
 	apiID        string
 	infraSetName string
-	Dns          string `json:"dns,omitempty"    yaml:"dns,omitempty"`
-	Domain       string `json:"domain,omitempty" yaml:"domain,omitempty"`
-	ReadOnlyUrl  string `json:"url,omitempty"    yaml:"url,omitempty"`
+	Dns          string   `json:"dns,omitempty"    yaml:"dns,omitempty"`
+	Domain       string   `json:"domain,omitempty" yaml:"domain,omitempty"`
+	Route        []string `json:"route,omitempty"  yaml:"route,omitempty"`
+	Attr         []string `json:"attr,omitempty"   yaml:"attr,omitempty"`
+	ReadOnlyUrl  string   `json:"url,omitempty"    yaml:"url,omitempty"`
 }
 
 type InfraUser struct {
@@ -186,13 +207,17 @@ const (
 	infraKeyLambdaRequire    = "require"
 	infraKeyLambdaEnv        = "env"
 	infraKeyLambdaInclude    = "include"
+	infraKeyLambdaBuild      = "build"
 )
 
 type InfraLambda struct {
-	dir          string // parent dir of infra.yaml file
-	runtime      string // provided (container) or python (zip) or go (zip)
-	handler      string // "main" (go), "filename.main" (python), or "" (container)
-	infraSetName string
+	dir           string   // parent dir of infra.yaml file
+	runtime       string   // provided (container) or python (zip) or go (zip)
+	arch          string   // x86_64 or arm64
+	authorizerFor []string // names of apis using this lambda as their authorizer
+	sharedApis    []string // names of shared apis with routes to this lambda
+	handler       string   // "main" (go), "filename.main" (python), or "" (container)
+	infraSetName  string
 
 	Name       string          `json:"name,omitempty"       yaml:"name,omitempty"`
 	Arn        string          `json:"arn,omitempty"        yaml:"arn,omitempty"`
@@ -203,6 +228,7 @@ type InfraLambda struct {
 	Require    []string        `json:"require,omitempty"    yaml:"require,omitempty"`
 	Env        []string        `json:"env,omitempty"        yaml:"env,omitempty"`
 	Include    []string        `json:"include,omitempty"    yaml:"include,omitempty"`
+	Build      []string        `json:"build,omitempty"      yaml:"build,omitempty"`
 	Trigger    []*InfraTrigger `json:"trigger,omitempty"    yaml:"trigger,omitempty"`
 }
 
@@ -224,6 +250,35 @@ type InfraS3 struct {
 	Attr         []string `json:"attr,omitempty" yaml:"attr,omitempty"`
 }
 
+const (
+	infraKeyKinesisAttr = "attr"
+)
+
+type InfraKinesis struct {
+	infraSetName string
+	Attr         []string `json:"attr,omitempty" yaml:"attr,omitempty"`
+}
+
+const (
+	infraKeySNSAttr         = "attr"
+	infraKeySNSSubscription = "subscription"
+)
+
+type InfraSNS struct {
+	infraSetName string
+	Attr         []string `json:"attr,omitempty"         yaml:"attr,omitempty"`
+	Subscription []string `json:"subscription,omitempty" yaml:"subscription,omitempty"`
+}
+
+const (
+	infraKeyEventBusAttr = "attr"
+)
+
+type InfraEventBus struct {
+	infraSetName string
+	Attr         []string `json:"attr,omitempty" yaml:"attr,omitempty"`
+}
+
 type InfraEvent struct {
 	infraSetName string
 	Target       string   `json:"target,omitempty" yaml:"target,omitempty"`
@@ -432,6 +487,108 @@ func InfraList(ctx context.Context, filter string, showEnvVarValues bool) (*Infr
 		errs <- nil
 	}()
 
+	// list kinesis
+	count++
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logRecover(r)
+			}
+		}()
+		streams, err := InfraListKinesis(ctx)
+		if err != nil {
+			errs <- err
+			return
+		}
+		for name, stream := range streams {
+			infraSetName := stream.infraSetName
+			if infraSetName == "" {
+				infraSetName = infraSetNameNone
+			}
+			if filter != "" && !(strings.Contains(infraSetName, filter) || strings.Contains(name, filter)) {
+				continue
+			}
+			lock.Lock()
+			if infra.InfraSet[infraSetName] == nil {
+				infra.InfraSet[infraSetName] = &InfraSet{}
+			}
+			if infra.InfraSet[infraSetName].Kinesis == nil {
+				infra.InfraSet[infraSetName].Kinesis = map[string]*InfraKinesis{}
+			}
+			infra.InfraSet[infraSetName].Kinesis[name] = stream
+			lock.Unlock()
+		}
+		errs <- nil
+	}()
+
+	// list sns
+	count++
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logRecover(r)
+			}
+		}()
+		topics, err := InfraListSNS(ctx, triggersChan)
+		if err != nil {
+			errs <- err
+			return
+		}
+		for name, topic := range topics {
+			infraSetName := topic.infraSetName
+			if infraSetName == "" {
+				infraSetName = infraSetNameNone
+			}
+			if filter != "" && !(strings.Contains(infraSetName, filter) || strings.Contains(name, filter)) {
+				continue
+			}
+			lock.Lock()
+			if infra.InfraSet[infraSetName] == nil {
+				infra.InfraSet[infraSetName] = &InfraSet{}
+			}
+			if infra.InfraSet[infraSetName].SNS == nil {
+				infra.InfraSet[infraSetName].SNS = map[string]*InfraSNS{}
+			}
+			infra.InfraSet[infraSetName].SNS[name] = topic
+			lock.Unlock()
+		}
+		errs <- nil
+	}()
+
+	// list event bus
+	count++
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logRecover(r)
+			}
+		}()
+		buses, err := InfraListEventBus(ctx)
+		if err != nil {
+			errs <- err
+			return
+		}
+		for name, bus := range buses {
+			infraSetName := bus.infraSetName
+			if infraSetName == "" {
+				infraSetName = infraSetNameNone
+			}
+			if filter != "" && !(strings.Contains(infraSetName, filter) || strings.Contains(name, filter)) {
+				continue
+			}
+			lock.Lock()
+			if infra.InfraSet[infraSetName] == nil {
+				infra.InfraSet[infraSetName] = &InfraSet{}
+			}
+			if infra.InfraSet[infraSetName].EventBus == nil {
+				infra.InfraSet[infraSetName].EventBus = map[string]*InfraEventBus{}
+			}
+			infra.InfraSet[infraSetName].EventBus[name] = bus
+			lock.Unlock()
+		}
+		errs <- nil
+	}()
+
 	// list s3
 	count++
 	go func() {
@@ -500,6 +657,17 @@ func InfraList(ctx context.Context, filter string, showEnvVarValues bool) (*Infr
 		errs <- nil
 	}()
 
+	// list schedule triggers
+	count++
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logRecover(r)
+			}
+		}()
+		errs <- InfraListSchedule(ctx, triggersChan)
+	}()
+
 	// list user
 	count++
 	go func() {
@@ -686,6 +854,9 @@ func InfraList(ctx context.Context, filter string, showEnvVarValues bool) (*Infr
 			if slices.Contains(lambdaNames, name) {
 				delete(infraSet.Role, name) // shown as allows/policies of the lambda
 			}
+			if slices.ContainsFunc(lambdaNames, func(lambdaName string) bool { return lambdaScheduleRoleName(lambdaName) == name }) {
+				delete(infraSet.Role, name) // shown as schedule triggers of the lambda
+			}
 			if slices.Contains(instanceProfileNames, name) {
 				delete(infraSet.Role, name) // shown as instanceProfile
 			}
@@ -735,11 +906,20 @@ func InfraListEvent(ctx context.Context, triggersChan chan<- *InfraTrigger) (map
 	}
 	results := map[string]*InfraEvent{}
 	lock := sync.Mutex{}
-	rules, err := EventsListRules(ctx, nil)
+	buses, err := EventsListBuses(ctx)
 	if err != nil {
 		Logger.Println("error:", err)
 		return nil, err
 	}
+	var rules []eventbridgetypes.Rule
+	for _, bus := range buses {
+		busRules, err := EventsListRules(ctx, bus.Name)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		rules = append(rules, busRules...)
+	}
 	errChan := make(chan error)
 	for _, rule := range rules {
 		rule := rule
@@ -749,7 +929,11 @@ func InfraListEvent(ctx context.Context, triggersChan chan<- *InfraTrigger) (map
 					logRecover(r)
 				}
 			}()
-			targets, err := EventsListRuleTargets(ctx, *rule.Name, nil)
+			busName := aws.ToString(rule.EventBusName)
+			if busName == "" {
+				busName = lambdaTriggerEventBusDefault
+			}
+			targets, err := EventsListRuleTargets(ctx, *rule.Name, aws.String(busName))
 			if err != nil {
 				Logger.Println("error:", err)
 				errChan <- err
@@ -783,6 +967,21 @@ func InfraListEvent(ctx context.Context, triggersChan chan<- *InfraTrigger) (map
 							lambdaName: Last(strings.Split(*target.Arn, ":")),
 							Type:       lambdaTriggerEcr,
 						}
+					} else if rule.EventPattern != nil && strings.HasPrefix(*rule.Name, lambdaEventRulePrefix(Last(strings.Split(*target.Arn, ":")))) {
+						attrs, err := lambdaEventPatternAttrs(busName, *rule.EventPattern)
+						if err != nil {
+							Logger.Println("error:", err)
+							errChan <- err
+							return
+						}
+						triggersChan <- &InfraTrigger{
+							lambdaName: Last(strings.Split(*target.Arn, ":")),
+							Type:       lambdaTriggerEvent,
+							Attr:       attrs,
+						}
+					}
+					if busName != lambdaTriggerEventBusDefault {
+						continue // only rules on the default bus are listed as events
 					}
 					if rule.Name == nil {
 						rule.Name = aws.String("-")
@@ -816,143 +1015,452 @@ func InfraListEvent(ctx context.Context, triggersChan chan<- *InfraTrigger) (map
 	return results, nil
 }
 
-func InfraListLambda(ctx context.Context, triggersChan <-chan *InfraTrigger, filter string) (map[string]*InfraLambda, error) {
+func InfraListKinesis(ctx context.Context) (map[string]*InfraKinesis, error) {
 	if doDebug {
-		d := &Debug{start: time.Now(), name: "InfraListLambda"}
+		d := &Debug{start: time.Now(), name: "InfraListKinesis"}
 		d.Start()
 		defer d.End()
 	}
-	allFns, err := LambdaListFunctions(ctx)
+	names, err := KinesisListStreams(ctx)
 	if err != nil {
 		Logger.Println("error:", err)
 		return nil, err
 	}
-	var fns []lambdatypes.FunctionConfiguration
-	for _, fn := range allFns {
-		if filter != "" && !strings.Contains(*fn.FunctionName, filter) {
-			continue
-		}
-		fns = append(fns, fn)
-	}
+	res := map[string]*InfraKinesis{}
+	lock := &sync.Mutex{}
 	errChan := make(chan error)
-	triggers := map[string][]*InfraTrigger{}
-	res := map[string]*InfraLambda{}
-	for _, fn := range fns {
-		fn := fn
+	for _, name := range names {
 		go func() {
 			defer func() {
 				if r := recover(); r != nil {
 					logRecover(r)
 				}
 			}()
-			infraLambda := &InfraLambda{
-				Name: *fn.FunctionName,
-			}
-			if fn.Environment != nil {
-				for k, v := range fn.Environment.Variables {
-					infraLambda.Env = append(infraLambda.Env, k+"="+v)
-				}
-			}
-			sort.Strings(infraLambda.Env)
-			tagsOut, err := LambdaClient().ListTags(ctx, &lambda.ListTagsInput{
-				Resource: fn.FunctionArn,
-			})
+			summary, err := KinesisDescribeStream(ctx, name)
 			if err != nil {
 				Logger.Println("error:", err)
 				errChan <- err
 				return
 			}
-			for k, v := range tagsOut.Tags {
-				if k == infraSetTagName {
-					infraLambda.infraSetName = v
-					break
-				}
-			}
-			res[infraLambda.Name] = infraLambda
-			if fn.MemorySize != nil && *fn.MemorySize != lambdaAttrMemoryDefault {
-				infraLambda.Attr = append(infraLambda.Attr, fmt.Sprintf("memory=%d", *fn.MemorySize))
-			}
-			if fn.Timeout != nil && *fn.Timeout != lambdaAttrTimeoutDefault {
-				infraLambda.Attr = append(infraLambda.Attr, fmt.Sprintf("timeout=%d", *fn.Timeout))
+			infraKinesis := &InfraKinesis{
+				Attr: KinesisAttrs(summary),
 			}
-			out, err := LambdaClient().GetFunctionConcurrency(ctx, &lambda.GetFunctionConcurrencyInput{
-				FunctionName: aws.String(*fn.FunctionName),
+			out, err := KinesisClient().ListTagsForResource(ctx, &kinesis.ListTagsForResourceInput{
+				ResourceARN: summary.StreamARN,
 			})
 			if err != nil {
 				Logger.Println("error:", err)
 				errChan <- err
 				return
 			}
-			if out.ReservedConcurrentExecutions != nil {
-				infraLambda.Attr = append(infraLambda.Attr, fmt.Sprintf("concurrency=%d", *out.ReservedConcurrentExecutions))
-			}
-			outUrl, err := LambdaClient().GetFunctionUrlConfig(ctx, &lambda.GetFunctionUrlConfigInput{
-				FunctionName: aws.String(*fn.FunctionName),
-			})
-			if err == nil && outUrl.FunctionUrl != nil {
-				triggers[*fn.FunctionName] = append(triggers[*fn.FunctionName], &InfraTrigger{
-					lambdaName: *fn.FunctionName,
-					Type:       lambdaTriggerUrl,
-					Attr:       []string{"url=" + strings.Trim(*outUrl.FunctionUrl, "/")},
-				})
-			} else {
-				var notFound *lambdatypes.ResourceNotFoundException
-				if !errors.As(err, &notFound) {
-					Logger.Println("error:", err)
-					errChan <- err
-					return
+			for _, tag := range out.Tags {
+				if *tag.Key == infraSetTagName {
+					infraKinesis.infraSetName = aws.ToString(tag.Value)
+					break
 				}
 			}
-			logGroupName := "/aws/lambda/" + *fn.FunctionName
-			outGroups, err := LogsClient().DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
-				LogGroupNamePrefix: aws.String(logGroupName),
+			lock.Lock()
+			res[name] = infraKinesis
+			lock.Unlock()
+			errChan <- nil
+		}()
+	}
+	for range names {
+		err := <-errChan
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func InfraListSNS(ctx context.Context, triggersChan chan<- *InfraTrigger) (map[string]*InfraSNS, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "InfraListSNS"}
+		d.Start()
+		defer d.End()
+	}
+	topicArns, err := SNSListTopics(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	res := map[string]*InfraSNS{}
+	lock := &sync.Mutex{}
+	errChan := make(chan error)
+	for _, topicArn := range topicArns {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logRecover(r)
+				}
+			}()
+			topicName := SNSArnToName(topicArn)
+			infraSNS := &InfraSNS{}
+			tags, err := SNSClient().ListTagsForResource(ctx, &sns.ListTagsForResourceInput{
+				ResourceArn: aws.String(topicArn),
 			})
 			if err != nil {
 				Logger.Println("error:", err)
 				errChan <- err
 				return
 			}
-			var logGroup *logstypes.LogGroup
-			for _, lg := range outGroups.LogGroups {
-				if logGroupName == *lg.LogGroupName {
-					logGroup = &lg
+			for _, tag := range tags.Tags {
+				if *tag.Key == infraSetTagName {
+					infraSNS.infraSetName = *tag.Value
 					break
 				}
 			}
-			if logGroup != nil {
-				if logGroup.RetentionInDays == nil {
-					infraLambda.Attr = append(infraLambda.Attr, "logs-ttl-days=0")
-				} else if *logGroup.RetentionInDays != lambdaAttrLogsTTLDaysDefault {
-					infraLambda.Attr = append(infraLambda.Attr, fmt.Sprintf("logs-ttl-days=%d", *logGroup.RetentionInDays))
-				}
-			}
-			roleName := Last(strings.Split(*fn.Role, "/"))
-			policies, err := IamListRolePolicies(ctx, roleName)
+			out, err := SNSClient().GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+				TopicArn: aws.String(topicArn),
+			})
 			if err != nil {
+				Logger.Println("error:", err)
 				errChan <- err
 				return
 			}
-			for _, policy := range policies {
-				infraLambda.Policy = append(infraLambda.Policy, *policy.PolicyName)
-			}
-			allows, err := IamListRoleAllows(ctx, roleName)
-			if err != nil {
-				errChan <- err
-				return
+			switch kmsKey := out.Attributes["KmsMasterKeyId"]; kmsKey {
+			case snsAttrKmsKeyDefault:
+			case "":
+				infraSNS.Attr = append(infraSNS.Attr, snsAttrKmsKey+"="+snsAttrKmsKeyNone)
+			default:
+				infraSNS.Attr = append(infraSNS.Attr, snsAttrKmsKey+"="+kmsKey)
 			}
-			for _, allow := range allows {
-				infraLambda.Allow = append(infraLambda.Allow, allow.String())
+			if out.Attributes["ContentBasedDeduplication"] == "true" {
+				infraSNS.Attr = append(infraSNS.Attr, snsAttrContentDedup+"=true")
 			}
-			rules, err := SesListReceiptRulesets(ctx)
+			subscriptions, err := SNSListSubscriptions(ctx, topicArn)
 			if err != nil {
+				Logger.Println("error:", err)
 				errChan <- err
 				return
 			}
-			for _, rule := range rules {
-				out, err := SesClient().DescribeReceiptRule(ctx, &ses.DescribeReceiptRuleInput{
-					RuleName:    rule.Name,
-					RuleSetName: rule.Name,
-				})
+			for _, subscription := range subscriptions {
+				attrs := map[string]string{}
+				if *subscription.SubscriptionArn != snsPendingConfirmation {
+					out, err := SNSClient().GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{
+						SubscriptionArn: subscription.SubscriptionArn,
+					})
+					if err != nil {
+						Logger.Println("error:", err)
+						errChan <- err
+						return
+					}
+					attrs = out.Attributes
+				}
+				switch *subscription.Protocol {
+				case snsProtocolLambda:
+					triggersChan <- &InfraTrigger{
+						lambdaName: Last(strings.Split(*subscription.Endpoint, ":")),
+						Type:       lambdaTriggerSNS,
+						Attr:       lambdaSNSTriggerAttrs(topicName, attrs["FilterPolicy"], attrs["FilterPolicyScope"]),
+					}
+				case snsProtocolSQS, snsProtocolEmail, snsProtocolHttp, snsProtocolHttps:
+					sub := &snsSubscription{
+						protocol: *subscription.Protocol,
+						endpoint: *subscription.Endpoint,
+						raw:      attrs["RawMessageDelivery"] == "true",
+					}
+					if sub.protocol == snsProtocolSQS {
+						sub.endpoint = Last(strings.Split(sub.endpoint, ":"))
+					}
+					infraSNS.Subscription = append(infraSNS.Subscription, sub.String())
+				}
+			}
+			lock.Lock()
+			res[topicName] = infraSNS
+			lock.Unlock()
+			errChan <- nil
+		}()
+	}
+	for range topicArns {
+		err := <-errChan
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func InfraListEventBus(ctx context.Context) (map[string]*InfraEventBus, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "InfraListEventBus"}
+		d.Start()
+		defer d.End()
+	}
+	buses, err := EventsListBuses(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	archives := map[string]eventbridgetypes.Archive{}
+	var token *string
+	for {
+		out, err := EventsClient().ListArchives(ctx, &events.ListArchivesInput{
+			NextToken: token,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, archive := range out.Archives {
+			archives[*archive.EventSourceArn] = archive
+		}
+		if out.NextToken == nil {
+			break
+		}
+		token = out.NextToken
+	}
+	res := map[string]*InfraEventBus{}
+	for _, bus := range buses {
+		infraEventBus := &InfraEventBus{}
+		archive, ok := archives[*bus.Arn]
+		if ok && *archive.ArchiveName == EventsArchiveName(*bus.Name) {
+			infraEventBus.Attr = append(infraEventBus.Attr, eventsBusAttrArchive+"=true")
+			if aws.ToInt32(archive.RetentionDays) != 0 {
+				infraEventBus.Attr = append(infraEventBus.Attr, fmt.Sprintf("%s=%d", eventsBusAttrArchiveRetentionDays, *archive.RetentionDays))
+			}
+		}
+		if *bus.Name == eventsBusDefault {
+			if len(infraEventBus.Attr) > 0 {
+				res[*bus.Name] = infraEventBus // the default bus is only interesting when archived
+			}
+			continue
+		}
+		out, err := EventsClient().ListTagsForResource(ctx, &events.ListTagsForResourceInput{
+			ResourceARN: bus.Arn,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, tag := range out.Tags {
+			if *tag.Key == infraSetTagName {
+				infraEventBus.infraSetName = *tag.Value
+				break
+			}
+		}
+		res[*bus.Name] = infraEventBus
+	}
+	return res, nil
+}
+
+func InfraListSchedule(ctx context.Context, triggersChan chan<- *InfraTrigger) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "InfraListSchedule"}
+		d.Start()
+		defer d.End()
+	}
+	groups, err := SchedulerListGroups(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	errChan := make(chan error)
+	for _, group := range groups {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logRecover(r)
+				}
+			}()
+			summaries, err := SchedulerListSchedules(ctx, *group.Name)
+			if err != nil {
+				Logger.Println("error:", err)
+				errChan <- err
+				return
+			}
+			for _, summary := range summaries {
+				if summary.Target == nil || summary.Target.Arn == nil || !strings.HasPrefix(*summary.Target.Arn, "arn:aws:lambda:") {
+					continue
+				}
+				lambdaName := Last(strings.Split(*summary.Target.Arn, ":"))
+				if lambdaName != *group.Name {
+					continue // only schedules managed by a schedule trigger
+				}
+				out, err := SchedulerClient().GetSchedule(ctx, &scheduler.GetScheduleInput{
+					Name:      summary.Name,
+					GroupName: group.Name,
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					errChan <- err
+					return
+				}
+				schedule := &lambdaSchedule{
+					expression: *out.ScheduleExpression,
+					timezone:   aws.ToString(out.ScheduleExpressionTimezone),
+					payload:    aws.ToString(out.Target.Input),
+					start:      out.StartDate,
+					end:        out.EndDate,
+				}
+				if out.FlexibleTimeWindow != nil && out.FlexibleTimeWindow.MaximumWindowInMinutes != nil {
+					schedule.window = *out.FlexibleTimeWindow.MaximumWindowInMinutes
+				}
+				triggersChan <- &InfraTrigger{
+					lambdaName: lambdaName,
+					Type:       lambdaTriggerSchedule,
+					Attr:       lambdaScheduleAttrs(schedule),
+				}
+			}
+			errChan <- nil
+		}()
+	}
+	for range groups {
+		err := <-errChan
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func InfraListLambda(ctx context.Context, triggersChan <-chan *InfraTrigger, filter string) (map[string]*InfraLambda, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "InfraListLambda"}
+		d.Start()
+		defer d.End()
+	}
+	allFns, err := LambdaListFunctions(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	var fns []lambdatypes.FunctionConfiguration
+	for _, fn := range allFns {
+		if filter != "" && !strings.Contains(*fn.FunctionName, filter) {
+			continue
+		}
+		fns = append(fns, fn)
+	}
+	errChan := make(chan error)
+	triggers := map[string][]*InfraTrigger{}
+	res := map[string]*InfraLambda{}
+	for _, fn := range fns {
+		fn := fn
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logRecover(r)
+				}
+			}()
+			infraLambda := &InfraLambda{
+				Name: *fn.FunctionName,
+			}
+			if fn.Environment != nil {
+				for k, v := range fn.Environment.Variables {
+					infraLambda.Env = append(infraLambda.Env, k+"="+v)
+				}
+			}
+			sort.Strings(infraLambda.Env)
+			tagsOut, err := LambdaClient().ListTags(ctx, &lambda.ListTagsInput{
+				Resource: fn.FunctionArn,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				errChan <- err
+				return
+			}
+			for k, v := range tagsOut.Tags {
+				if k == infraSetTagName {
+					infraLambda.infraSetName = v
+					break
+				}
+			}
+			res[infraLambda.Name] = infraLambda
+			if fn.MemorySize != nil && *fn.MemorySize != lambdaAttrMemoryDefault {
+				infraLambda.Attr = append(infraLambda.Attr, fmt.Sprintf("memory=%d", *fn.MemorySize))
+			}
+			if fn.Timeout != nil && *fn.Timeout != lambdaAttrTimeoutDefault {
+				infraLambda.Attr = append(infraLambda.Attr, fmt.Sprintf("timeout=%d", *fn.Timeout))
+			}
+			if len(fn.Architectures) > 0 && string(fn.Architectures[0]) != lambdaAttrArchDefault {
+				infraLambda.Attr = append(infraLambda.Attr, "arch="+string(fn.Architectures[0]))
+			}
+			if strings.HasPrefix(string(fn.Runtime), "python") && string(fn.Runtime) != lambdaRuntimePython {
+				infraLambda.Attr = append(infraLambda.Attr, "python="+strings.TrimPrefix(string(fn.Runtime), "python"))
+			}
+			out, err := LambdaClient().GetFunctionConcurrency(ctx, &lambda.GetFunctionConcurrencyInput{
+				FunctionName: aws.String(*fn.FunctionName),
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				errChan <- err
+				return
+			}
+			if out.ReservedConcurrentExecutions != nil {
+				infraLambda.Attr = append(infraLambda.Attr, fmt.Sprintf("concurrency=%d", *out.ReservedConcurrentExecutions))
+			}
+			outUrl, err := LambdaClient().GetFunctionUrlConfig(ctx, &lambda.GetFunctionUrlConfigInput{
+				FunctionName: aws.String(*fn.FunctionName),
+			})
+			if err == nil && outUrl.FunctionUrl != nil {
+				triggers[*fn.FunctionName] = append(triggers[*fn.FunctionName], &InfraTrigger{
+					lambdaName: *fn.FunctionName,
+					Type:       lambdaTriggerUrl,
+					Attr:       append([]string{"url=" + strings.Trim(*outUrl.FunctionUrl, "/")}, lambdaUrlConfigAttrs(outUrl.AuthType, outUrl.InvokeMode, outUrl.Cors)...),
+				})
+			} else {
+				var notFound *lambdatypes.ResourceNotFoundException
+				if !errors.As(err, &notFound) {
+					Logger.Println("error:", err)
+					errChan <- err
+					return
+				}
+			}
+			logGroupName := "/aws/lambda/" + *fn.FunctionName
+			outGroups, err := LogsClient().DescribeLogGroups(ctx, &cloudwatchlogs.DescribeLogGroupsInput{
+				LogGroupNamePrefix: aws.String(logGroupName),
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				errChan <- err
+				return
+			}
+			var logGroup *logstypes.LogGroup
+			for _, lg := range outGroups.LogGroups {
+				if logGroupName == *lg.LogGroupName {
+					logGroup = &lg
+					break
+				}
+			}
+			if logGroup != nil {
+				if logGroup.RetentionInDays == nil {
+					infraLambda.Attr = append(infraLambda.Attr, "logs-ttl-days=0")
+				} else if *logGroup.RetentionInDays != lambdaAttrLogsTTLDaysDefault {
+					infraLambda.Attr = append(infraLambda.Attr, fmt.Sprintf("logs-ttl-days=%d", *logGroup.RetentionInDays))
+				}
+			}
+			roleName := Last(strings.Split(*fn.Role, "/"))
+			policies, err := IamListRolePolicies(ctx, roleName)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			for _, policy := range policies {
+				infraLambda.Policy = append(infraLambda.Policy, *policy.PolicyName)
+			}
+			allows, err := IamListRoleAllows(ctx, roleName)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			for _, allow := range allows {
+				infraLambda.Allow = append(infraLambda.Allow, allow.String())
+			}
+			rules, err := SesListReceiptRulesets(ctx)
+			if err != nil {
+				errChan <- err
+				return
+			}
+			for _, rule := range rules {
+				out, err := SesClient().DescribeReceiptRule(ctx, &ses.DescribeReceiptRuleInput{
+					RuleName:    rule.Name,
+					RuleSetName: rule.Name,
+				})
 				if err == nil {
 					bucket := ""
 					prefix := ""
@@ -1021,15 +1529,44 @@ func InfraListLambda(ctx context.Context, triggersChan <-chan *InfraTrigger, fil
 								fmt.Sprintf("window=%d", *mapping.MaximumBatchingWindowInSeconds),
 							},
 						})
+					case lambdaTriggerKinesis:
+						attrs := []string{
+							KinesisArnToName(*mapping.EventSourceArn),
+							fmt.Sprintf("batch=%d", *mapping.BatchSize),
+							fmt.Sprintf("parallel=%d", *mapping.ParallelizationFactor),
+							fmt.Sprintf("retry=%d", *mapping.MaximumRetryAttempts),
+							fmt.Sprintf("start=%s", strings.ToLower(string(mapping.StartingPosition))),
+							fmt.Sprintf("window=%d", *mapping.MaximumBatchingWindowInSeconds),
+						}
+						if aws.ToBool(mapping.BisectBatchOnFunctionError) {
+							attrs = append(attrs, "bisect=true")
+						}
+						triggers[*fn.FunctionName] = append(triggers[*fn.FunctionName], &InfraTrigger{
+							lambdaName: *fn.FunctionName,
+							Type:       infra,
+							Attr:       attrs,
+						})
 					case lambdaTriggerSQS:
+						attrs := []string{
+							SQSArnToName(*mapping.EventSourceArn),
+							fmt.Sprintf("batch=%d", *mapping.BatchSize),
+							fmt.Sprintf("window=%d", *mapping.MaximumBatchingWindowInSeconds),
+						}
+						if slices.Contains(mapping.FunctionResponseTypes, lambdatypes.FunctionResponseTypeReportBatchItemFailures) {
+							attrs = append(attrs, "partial=true")
+						}
+						if mapping.ScalingConfig != nil && mapping.ScalingConfig.MaximumConcurrency != nil {
+							attrs = append(attrs, fmt.Sprintf("concurrency=%d", *mapping.ScalingConfig.MaximumConcurrency))
+						}
+						if mapping.FilterCriteria != nil {
+							for _, filter := range mapping.FilterCriteria.Filters {
+								attrs = append(attrs, "filter="+aws.ToString(filter.Pattern))
+							}
+						}
 						triggers[*fn.FunctionName] = append(triggers[*fn.FunctionName], &InfraTrigger{
 							lambdaName: *fn.FunctionName,
 							Type:       infra,
-							Attr: []string{
-								SQSArnToName(*mapping.EventSourceArn),
-								fmt.Sprintf("batch=%d", *mapping.BatchSize),
-								fmt.Sprintf("window=%d", *mapping.MaximumBatchingWindowInSeconds),
-							},
+							Attr:       attrs,
 						})
 					default:
 						Logger.Println("ignoring event source mapping:", *mapping.FunctionArn, *mapping.EventSourceArn)
@@ -1218,7 +1755,55 @@ func InfraListApi(ctx context.Context, triggersChan chan<- *InfraTrigger) (map[s
 				errChan <- err
 				return
 			}
-			if len(out.Items) != 1 {
+			lambdaName := strings.TrimSuffix(*api.Name, LambdaWebsocketSuffix)
+			if len(out.Items) != 1 || LambdaArnToLambdaName(aws.ToString(out.Items[0].IntegrationUri)) != lambdaName { // shared api with routes to many lambdas
+				routes, err := lambdaApiGetRoutes(ctx, *api.Name, &api)
+				if err != nil {
+					Logger.Println("error:", err)
+					errChan <- err
+					return
+				}
+				integrationLambdas := map[string]string{}
+				for _, integration := range out.Items {
+					integrationLambdas["integrations/"+*integration.IntegrationId] = LambdaArnToLambdaName(aws.ToString(integration.IntegrationUri))
+				}
+				for _, route := range routes {
+					infraApi.Route = append(infraApi.Route, *route.RouteKey+"="+integrationLambdas[aws.ToString(route.Target)])
+				}
+				sort.Strings(infraApi.Route)
+				authorizers, err := lambdaApiGetAuthorizers(ctx, *api.ApiId)
+				if err != nil {
+					Logger.Println("error:", err)
+					errChan <- err
+					return
+				}
+				for _, authorizer := range authorizers {
+					infraApi.Attr = append(infraApi.Attr, lambdaApiAuthAttrs(authorizer)...)
+				}
+				stage, err := ApiClient().GetStage(ctx, &apigatewayv2.GetStageInput{
+					ApiId:     api.ApiId,
+					StageName: aws.String(lambdaDollarDefault),
+				})
+				if err == nil {
+					infraApi.Attr = append(infraApi.Attr, lambdaApiStageAttrs(stage.DefaultRouteSettings, stage.AccessLogSettings)...)
+				} else {
+					var nfe *apitypes.NotFoundException
+					if !errors.As(err, &nfe) {
+						Logger.Println("error:", err)
+						errChan <- err
+						return
+					}
+				}
+				infraApi.Dns = apiToDns[*api.ApiId]
+				if infraApi.Dns == "" {
+					infraApi.Domain = apiToDomain[*api.ApiId]
+				}
+				if infraApi.Dns == "" && infraApi.Domain == "" {
+					infraApi.ReadOnlyUrl = fmt.Sprintf("https://%s.execute-api.%s.amazonaws.com", infraApi.apiID, Region())
+				}
+				lock.Lock()
+				result[*api.Name] = infraApi
+				lock.Unlock()
 				errChan <- nil
 				return
 			}
@@ -1235,7 +1820,7 @@ func InfraListApi(ctx context.Context, triggersChan chan<- *InfraTrigger) (map[s
 				}
 			}
 			triggerType := lambdaTriggerApi
-			lambdaName := *api.Name
+			lambdaName = *api.Name
 			if api.RouteSelectionExpression != nil && *api.RouteSelectionExpression == lambdaRouteSelection { // websocket uses a suffix in addition to the lambda name
 				if !strings.HasSuffix(lambdaName, LambdaWebsocketSuffix) {
 					Logger.Println(*api.RouteSelectionExpression)
@@ -1254,9 +1839,32 @@ func InfraListApi(ctx context.Context, triggersChan chan<- *InfraTrigger) (map[s
 					}
 				}
 			}
-			if infraApi.ReadOnlyUrl != "" {
-				attrs = append(attrs, fmt.Sprintf("url=https://%s", infraApi.ReadOnlyUrl))
-			}
+			if infraApi.ReadOnlyUrl != "" {
+				attrs = append(attrs, fmt.Sprintf("url=https://%s", infraApi.ReadOnlyUrl))
+			}
+			authorizers, err := lambdaApiGetAuthorizers(ctx, *api.ApiId)
+			if err != nil {
+				Logger.Println("error:", err)
+				errChan <- err
+				return
+			}
+			for _, authorizer := range authorizers {
+				attrs = append(attrs, lambdaApiAuthAttrs(authorizer)...)
+			}
+			stage, err := ApiClient().GetStage(ctx, &apigatewayv2.GetStageInput{
+				ApiId:     api.ApiId,
+				StageName: aws.String(lambdaDollarDefault),
+			})
+			if err == nil {
+				attrs = append(attrs, lambdaApiStageAttrs(stage.DefaultRouteSettings, stage.AccessLogSettings)...)
+			} else {
+				var nfe *apitypes.NotFoundException
+				if !errors.As(err, &nfe) {
+					Logger.Println("error:", err)
+					errChan <- err
+					return
+				}
+			}
 			triggersChan <- &InfraTrigger{
 				lambdaName: lambdaName,
 				Type:       triggerType,
@@ -1415,6 +2023,13 @@ func InfraListDynamoDB(ctx context.Context) (map[string]*InfraDynamoDB, error) {
 			if ttlOut.TimeToLiveDescription.TimeToLiveStatus == dynamodbtypes.TimeToLiveStatusEnabled {
 				infraDynamoDB.Attr = append(infraDynamoDB.Attr, "ttl="+*ttlOut.TimeToLiveDescription.AttributeName)
 			}
+			settings, err := DynamoDBTableDescriptionSettings(ctx, out.Table, false)
+			if err != nil {
+				Logger.Println("error:", err)
+				errChan <- err
+				return
+			}
+			infraDynamoDB.Attr = append(infraDynamoDB.Attr, settings.Attrs(false)...)
 			lock.Lock()
 			result[tableName] = infraDynamoDB
 			lock.Unlock()
@@ -1781,12 +2396,34 @@ func InfraListS3(ctx context.Context, triggersChan chan<- *InfraTrigger) (map[st
 				infraS3.Attr = append(infraS3.Attr, fmt.Sprintf("ttldays=%d", *ttl[0].Expiration.Days))
 			}
 			if descr.Notifications != nil {
+				for _, conf := range descr.Notifications.QueueConfigurations {
+					target := s3NotifySQS + ":" + SQSArnToName(aws.ToString(conf.QueueArn))
+					notification := s3NotificationFromConfig(target, conf.Events, conf.Filter)
+					if notification != nil {
+						infraS3.Attr = append(infraS3.Attr, "notify="+notification.String())
+					}
+				}
+				for _, conf := range descr.Notifications.TopicConfigurations {
+					target := s3NotifySNS + ":" + SNSArnToName(aws.ToString(conf.TopicArn))
+					notification := s3NotificationFromConfig(target, conf.Events, conf.Filter)
+					if notification != nil {
+						infraS3.Attr = append(infraS3.Attr, "notify="+notification.String())
+					}
+				}
+				if descr.Notifications.EventBridgeConfiguration != nil {
+					infraS3.Attr = append(infraS3.Attr, "eventbridge=true")
+				}
 				for _, conf := range descr.Notifications.LambdaFunctionConfigurations {
 					if conf.LambdaFunctionArn != nil {
+						attrs := []string{*bucket.Name}
+						notification := s3NotificationFromConfig(*conf.LambdaFunctionArn, conf.Events, conf.Filter)
+						if notification != nil {
+							attrs = append(attrs, notification.Attrs()...)
+						}
 						triggersChan <- &InfraTrigger{
 							lambdaName: LambdaArnToLambdaName(*conf.LambdaFunctionArn),
 							Type:       lambdaTrigerS3,
-							Attr:       []string{*bucket.Name},
+							Attr:       attrs,
 						}
 					}
 				}
@@ -1838,6 +2475,11 @@ func InfraListSQS(ctx context.Context) (map[string]*InfraSQS, error) {
 					sqstypes.QueueAttributeNameReceiveMessageWaitTimeSeconds,
 					sqstypes.QueueAttributeNameVisibilityTimeout,
 					sqstypes.QueueAttributeNameKmsDataKeyReusePeriodSeconds,
+					sqstypes.QueueAttributeNameKmsMasterKeyId,
+					sqstypes.QueueAttributeNameContentBasedDeduplication,
+					sqstypes.QueueAttributeNameDeduplicationScope,
+					sqstypes.QueueAttributeNameFifoThroughputLimit,
+					sqstypes.QueueAttributeNameRedrivePolicy,
 				},
 			})
 			if err != nil {
@@ -1879,9 +2521,31 @@ func InfraListSQS(ctx context.Context) (map[string]*InfraSQS, error) {
 			if out.Attributes["VisibilityTimeout"] != "30" { // default
 				infraSQS.Attr = append(infraSQS.Attr, "VisibilityTimeout="+out.Attributes["VisibilityTimeout"])
 			}
-			if out.Attributes["KmsDataKeyReusePeriodSeconds"] != "300" { // default
+			if out.Attributes["KmsDataKeyReusePeriodSeconds"] != "" && out.Attributes["KmsDataKeyReusePeriodSeconds"] != "300" { // default
 				infraSQS.Attr = append(infraSQS.Attr, "KmsDataKeyReusePeriodSeconds="+out.Attributes["KmsDataKeyReusePeriodSeconds"])
 			}
+			switch kmsKey := out.Attributes["KmsMasterKeyId"]; kmsKey {
+			case sqsKmsKeyDefault:
+			case "":
+				infraSQS.Attr = append(infraSQS.Attr, "KmsMasterKeyId="+sqsKmsKeyNone)
+			default:
+				infraSQS.Attr = append(infraSQS.Attr, "KmsMasterKeyId="+kmsKey)
+			}
+			if out.Attributes["ContentBasedDeduplication"] == "true" {
+				infraSQS.Attr = append(infraSQS.Attr, "ContentBasedDeduplication=true")
+			}
+			if out.Attributes["DeduplicationScope"] == sqsDeduplicationScopeGroup && out.Attributes["FifoThroughputLimit"] == sqsFifoThroughputLimitGroup {
+				infraSQS.Attr = append(infraSQS.Attr, "highthroughput=true")
+			}
+			dlq, maxReceiveCount, err := sqsParseRedrivePolicy(out.Attributes["RedrivePolicy"])
+			if err != nil {
+				Logger.Println("error:", err)
+				errChan <- err
+				return
+			}
+			if dlq != "" {
+				infraSQS.Attr = append(infraSQS.Attr, "dlq="+dlq, fmt.Sprintf("maxReceiveCount=%d", maxReceiveCount))
+			}
 			lock.Lock()
 			res[SQSUrlToName(url)] = infraSQS
 			lock.Unlock()
@@ -2105,12 +2769,12 @@ func InfraEnsureDynamoDB(ctx context.Context, infraSet *InfraSet, preview bool)
 			Logger.Println("error:", err)
 			return err
 		}
-		input, ttl, err := DynamoDBEnsureInput(infraSet.Name, tableName, infraDynamoDB.Key, infraDynamoDB.Attr)
+		input, options, err := DynamoDBEnsureInput(infraSet.Name, tableName, infraDynamoDB.Key, infraDynamoDB.Attr)
 		if err != nil {
 			Logger.Println("error:", err)
 			return err
 		}
-		err = DynamoDBEnsure(ctx, input, ttl, preview)
+		err = DynamoDBEnsure(ctx, input, options, preview)
 		if err != nil {
 			Logger.Println("error:", err)
 			return err
@@ -2125,13 +2789,85 @@ func InfraEnsureSQS(ctx context.Context, infraSet *InfraSet, preview bool) error
 		d.Start()
 		defer d.End()
 	}
+	inputs := map[string]*sqsEnsureInput{}
 	for queueName, infraSQS := range infraSet.SQS {
 		input, err := SQSEnsureInput(infraSet.Name, queueName, infraSQS.Attr)
 		if err != nil {
 			Logger.Println("error:", err)
 			return err
 		}
-		err = SQSEnsure(ctx, input, preview)
+		inputs[queueName] = input
+	}
+	order, err := sqsEnsureOrder(inputs) // dead-letter queues must exist before queues that redrive to them
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, queueName := range order {
+		err := SQSEnsure(ctx, inputs[queueName], preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func InfraEnsureKinesis(ctx context.Context, infraSet *InfraSet, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "InfraEnsureKinesis"}
+		d.Start()
+		defer d.End()
+	}
+	for streamName, infraKinesis := range infraSet.Kinesis {
+		input, err := KinesisEnsureInput(infraSet.Name, streamName, infraKinesis.Attr)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		err = KinesisEnsure(ctx, input, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func InfraEnsureSNS(ctx context.Context, infraSet *InfraSet, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "InfraEnsureSNS"}
+		d.Start()
+		defer d.End()
+	}
+	for topicName, infraSNS := range infraSet.SNS {
+		input, err := SNSEnsureInput(infraSet.Name, topicName, infraSNS.Attr, infraSNS.Subscription)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		err = SNSEnsureTopic(ctx, input, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func InfraEnsureEventBus(ctx context.Context, infraSet *InfraSet, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "InfraEnsureEventBus"}
+		d.Start()
+		defer d.End()
+	}
+	for busName, infraEventBus := range infraSet.EventBus {
+		input, err := EventsEnsureBusInput(infraSet.Name, busName, infraEventBus.Attr)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		err = EventsEnsureBus(ctx, input, preview)
 		if err != nil {
 			Logger.Println("error:", err)
 			return err
@@ -2159,7 +2895,15 @@ func InfraEnsureLambda(ctx context.Context, infraSet *InfraSet, quick string, pr
 			return err
 		}
 	}
-	for lambdaName, infraLambda := range infraSet.Lambda {
+	var lambdaNames []string
+	for lambdaName := range infraSet.Lambda {
+		lambdaNames = append(lambdaNames, lambdaName)
+	}
+	sort.SliceStable(lambdaNames, func(i, j int) bool { // ensure authorizers first so api triggers can grant them permissions
+		return len(infraSet.Lambda[lambdaNames[i]].authorizerFor) > 0 && len(infraSet.Lambda[lambdaNames[j]].authorizerFor) == 0
+	})
+	for _, lambdaName := range lambdaNames {
+		infraLambda := infraSet.Lambda[lambdaName]
 		if quick != "" && quick != lambdaName {
 			continue
 		}
@@ -2245,17 +2989,32 @@ func InfraEnsure(ctx context.Context, infraSet *InfraSet, quick string, preview,
 			Logger.Println("error:", err)
 			return err
 		}
-		err = InfraEnsureS3(ctx, infraSet, preview)
+		err = InfraEnsureDynamoDB(ctx, infraSet, preview)
 		if err != nil {
 			Logger.Println("error:", err)
 			return err
 		}
-		err = InfraEnsureDynamoDB(ctx, infraSet, preview)
+		err = InfraEnsureSQS(ctx, infraSet, preview)
 		if err != nil {
 			Logger.Println("error:", err)
 			return err
 		}
-		err = InfraEnsureSQS(ctx, infraSet, preview)
+		err = InfraEnsureKinesis(ctx, infraSet, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		err = InfraEnsureSNS(ctx, infraSet, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		err = InfraEnsureS3(ctx, infraSet, preview) // after sqs and sns, which bucket notifications may target
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		err = InfraEnsureEventBus(ctx, infraSet, preview)
 		if err != nil {
 			Logger.Println("error:", err)
 			return err
@@ -2266,6 +3025,29 @@ func InfraEnsure(ctx context.Context, infraSet *InfraSet, quick string, preview,
 		Logger.Println("error:", err)
 		return err
 	}
+	if quick == "" {
+		err = InfraEnsureApi(ctx, infraSet, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func InfraEnsureApi(ctx context.Context, infraSet *InfraSet, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "InfraEnsureApi"}
+		d.Start()
+		defer d.End()
+	}
+	for apiName, infraApi := range infraSet.Api {
+		err := ApiEnsure(ctx, infraSet.Name, apiName, infraApi, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -2398,6 +3180,54 @@ func infraParseValidateS3(val any) error {
 	return nil
 }
 
+func infraParseValidateApi(val any) error {
+	_, ok := val.(map[string]any)
+	if !ok {
+		err := fmt.Errorf("infraApi should be type: map[string]any, got: %#v", val)
+		Logger.Println("error:", err)
+		return err
+	}
+	for name, apiVal := range val.(map[string]any) {
+		_, ok := apiVal.(map[string]any)
+		if !ok {
+			err := fmt.Errorf("infraApi should be type: map[string]any, got: %s %#v", name, apiVal)
+			Logger.Println("error:", err)
+			return err
+		}
+		for k, v := range apiVal.(map[string]any) {
+			switch k {
+			case infraKeyApiDns, infraKeyApiDomain:
+				_, ok := v.(string)
+				if !ok {
+					err := fmt.Errorf("infraApi key %s should be type: string, got: %#v", k, v)
+					Logger.Println("error:", err)
+					return err
+				}
+			case infraKeyApiRoute, infraKeyApiAttr:
+				xs, ok := v.([]any)
+				if !ok {
+					err := fmt.Errorf("infraApi key %s should be type: []string, got: %#v", k, v)
+					Logger.Println("error:", err)
+					return err
+				}
+				for _, x := range xs {
+					_, ok := x.(string)
+					if !ok {
+						err := fmt.Errorf("infraApi key %s should be type: []string, got: %#v", k, v)
+						Logger.Println("error:", err)
+						return err
+					}
+				}
+			default:
+				err := fmt.Errorf("unknown infraApi key: %s: %v", k, v)
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func infraParseValidateTrigger(val any) error {
 	_, ok := val.([]any)
 	if !ok {
@@ -2482,36 +3312,168 @@ func infraParseValidateKeypair(val any) error {
 func infraParseValidateSQS(val any) error {
 	_, ok := val.(map[string]any)
 	if !ok {
-		err := fmt.Errorf("infraSQS should be type: map[string]any, got: %#v", val)
+		err := fmt.Errorf("infraSQS should be type: map[string]any, got: %#v", val)
+		Logger.Println("error:", err)
+		return err
+	}
+	for name, sqsVal := range val.(map[string]any) {
+		_, ok := sqsVal.(map[string]any)
+		if !ok {
+			err := fmt.Errorf("infraSQS should be type: map[string]any, got: %s %#v", name, sqsVal)
+			Logger.Println("error:", err)
+			return err
+		}
+		for k, v := range sqsVal.(map[string]any) {
+			switch k {
+			case infraKeySQSAttr:
+				xs, ok := v.([]any)
+				if !ok {
+					err := fmt.Errorf("infraSQS key %s should be type: []string, got: %#v", k, v)
+					Logger.Println("error:", err)
+					return err
+				}
+				for _, x := range xs {
+					_, ok := x.(string)
+					if !ok {
+						err := fmt.Errorf("infraSQS key %s should be type: []string, got: %#v", k, v)
+						Logger.Println("error:", err)
+						return err
+					}
+				}
+			default:
+				err := fmt.Errorf("unknown infraSQS key: %s: %v", k, v)
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func infraParseValidateSNS(val any) error {
+	_, ok := val.(map[string]any)
+	if !ok {
+		err := fmt.Errorf("infraSNS should be type: map[string]any, got: %#v", val)
+		Logger.Println("error:", err)
+		return err
+	}
+	for name, topicVal := range val.(map[string]any) {
+		if topicVal == nil {
+			continue
+		}
+		_, ok := topicVal.(map[string]any)
+		if !ok {
+			err := fmt.Errorf("infraSNS should be type: map[string]any, got: %s %#v", name, topicVal)
+			Logger.Println("error:", err)
+			return err
+		}
+		for k, v := range topicVal.(map[string]any) {
+			switch k {
+			case infraKeySNSAttr, infraKeySNSSubscription:
+				xs, ok := v.([]any)
+				if !ok {
+					err := fmt.Errorf("infraSNS key %s should be type: []string, got: %#v", k, v)
+					Logger.Println("error:", err)
+					return err
+				}
+				for _, x := range xs {
+					_, ok := x.(string)
+					if !ok {
+						err := fmt.Errorf("infraSNS key %s should be type: []string, got: %#v", k, v)
+						Logger.Println("error:", err)
+						return err
+					}
+				}
+			default:
+				err := fmt.Errorf("unknown infraSNS key: %s: %v", k, v)
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func infraParseValidateKinesis(val any) error {
+	_, ok := val.(map[string]any)
+	if !ok {
+		err := fmt.Errorf("infraKinesis should be type: map[string]any, got: %#v", val)
+		Logger.Println("error:", err)
+		return err
+	}
+	for name, streamVal := range val.(map[string]any) {
+		if streamVal == nil {
+			continue
+		}
+		_, ok := streamVal.(map[string]any)
+		if !ok {
+			err := fmt.Errorf("infraKinesis should be type: map[string]any, got: %s %#v", name, streamVal)
+			Logger.Println("error:", err)
+			return err
+		}
+		for k, v := range streamVal.(map[string]any) {
+			switch k {
+			case infraKeyKinesisAttr:
+				xs, ok := v.([]any)
+				if !ok {
+					err := fmt.Errorf("infraKinesis key %s should be type: []string, got: %#v", k, v)
+					Logger.Println("error:", err)
+					return err
+				}
+				for _, x := range xs {
+					_, ok := x.(string)
+					if !ok {
+						err := fmt.Errorf("infraKinesis key %s should be type: []string, got: %#v", k, v)
+						Logger.Println("error:", err)
+						return err
+					}
+				}
+			default:
+				err := fmt.Errorf("unknown infraKinesis key: %s: %v", k, v)
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func infraParseValidateEventBus(val any) error {
+	_, ok := val.(map[string]any)
+	if !ok {
+		err := fmt.Errorf("infraEventBus should be type: map[string]any, got: %#v", val)
 		Logger.Println("error:", err)
 		return err
 	}
-	for name, sqsVal := range val.(map[string]any) {
-		_, ok := sqsVal.(map[string]any)
+	for name, busVal := range val.(map[string]any) {
+		if busVal == nil {
+			continue
+		}
+		_, ok := busVal.(map[string]any)
 		if !ok {
-			err := fmt.Errorf("infraSQS should be type: map[string]any, got: %s %#v", name, sqsVal)
+			err := fmt.Errorf("infraEventBus should be type: map[string]any, got: %s %#v", name, busVal)
 			Logger.Println("error:", err)
 			return err
 		}
-		for k, v := range sqsVal.(map[string]any) {
+		for k, v := range busVal.(map[string]any) {
 			switch k {
-			case infraKeySQSAttr:
+			case infraKeyEventBusAttr:
 				xs, ok := v.([]any)
 				if !ok {
-					err := fmt.Errorf("infraSQS key %s should be type: []string, got: %#v", k, v)
+					err := fmt.Errorf("infraEventBus key %s should be type: []string, got: %#v", k, v)
 					Logger.Println("error:", err)
 					return err
 				}
 				for _, x := range xs {
 					_, ok := x.(string)
 					if !ok {
-						err := fmt.Errorf("infraSQS key %s should be type: []string, got: %#v", k, v)
+						err := fmt.Errorf("infraEventBus key %s should be type: []string, got: %#v", k, v)
 						Logger.Println("error:", err)
 						return err
 					}
 				}
 			default:
-				err := fmt.Errorf("unknown infraSQS key: %s: %v", k, v)
+				err := fmt.Errorf("unknown infraEventBus key: %s: %v", k, v)
 				Logger.Println("error:", err)
 				return err
 			}
@@ -2683,7 +3645,7 @@ func infraParseValidateLambda(val any) error {
 					Logger.Println("error:", err)
 					return err
 				}
-			case infraKeyLambdaPolicy, infraKeyLambdaAllow, infraKeyLambdaInclude, infraKeyLambdaRequire, infraKeyLambdaEnv, infraKeyLambdaAttr:
+			case infraKeyLambdaPolicy, infraKeyLambdaAllow, infraKeyLambdaInclude, infraKeyLambdaRequire, infraKeyLambdaEnv, infraKeyLambdaAttr, infraKeyLambdaBuild:
 				xs, ok := v.([]any)
 				if !ok {
 					err := fmt.Errorf("infraLambda key %s should be type: []string, got: %#v", k, v)
@@ -2776,6 +3738,30 @@ func InfraParse(yamlPath string) (*InfraSet, error) {
 				Logger.Println("error:", err)
 				return nil, err
 			}
+		case infraKeyApi:
+			err := infraParseValidateApi(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		case infraKeyKinesis:
+			err := infraParseValidateKinesis(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		case infraKeySns:
+			err := infraParseValidateSNS(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		case infraKeyEventBus:
+			err := infraParseValidateEventBus(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
 		default:
 			err := fmt.Errorf("unknown infra key: %s: %v", k, v)
 			Logger.Println("error:", err)
@@ -2798,7 +3784,128 @@ func InfraParse(yamlPath string) (*InfraSet, error) {
 		Logger.Println("error:", err)
 		return nil, err
 	}
-	for _, infraLambda := range infraSet.Lambda {
+	sqsInputs := map[string]*sqsEnsureInput{}
+	for queueName, infraSQS := range infraSet.SQS {
+		if infraSQS == nil {
+			infraSQS = &InfraSQS{}
+			infraSet.SQS[queueName] = infraSQS
+		}
+		input, err := SQSEnsureInput(infraSet.Name, queueName, infraSQS.Attr)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		sqsInputs[queueName] = input
+	}
+	_, err = sqsEnsureOrder(sqsInputs)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	for streamName, infraKinesis := range infraSet.Kinesis {
+		if infraKinesis == nil {
+			infraKinesis = &InfraKinesis{}
+			infraSet.Kinesis[streamName] = infraKinesis
+		}
+		_, err := KinesisEnsureInput(infraSet.Name, streamName, infraKinesis.Attr)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	for topicName, infraSNS := range infraSet.SNS {
+		if infraSNS == nil {
+			infraSNS = &InfraSNS{}
+			infraSet.SNS[topicName] = infraSNS
+		}
+		topic, err := SNSEnsureInput(infraSet.Name, topicName, infraSNS.Attr, infraSNS.Subscription)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, sub := range topic.subscriptions {
+			if sub.protocol != snsProtocolSQS {
+				continue
+			}
+			infraSQS, ok := infraSet.SQS[sub.endpoint]
+			if !ok {
+				continue // queues outside the infraset are not validated
+			}
+			queue, err := SQSEnsureInput(infraSet.Name, sub.endpoint, infraSQS.Attr)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			err = sqsValidatePublisher(queue, "sns topic "+topicName)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		}
+	}
+	for busName, infraEventBus := range infraSet.EventBus {
+		if infraEventBus == nil {
+			infraEventBus = &InfraEventBus{}
+			infraSet.EventBus[busName] = infraEventBus
+		}
+		_, err := EventsEnsureBusInput(infraSet.Name, busName, infraEventBus.Attr)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	s3Notifications := map[string][]*s3Notification{}
+	for bucketName, infraS3 := range infraSet.S3 {
+		if infraS3 == nil {
+			continue
+		}
+		input, err := S3EnsureInput(infraSet.Name, bucketName, infraS3.Attr)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, notification := range input.notifications {
+			protocol, name, _ := SplitOnce(notification.target, ":")
+			if protocol == s3NotifySQS {
+				infraSQS, ok := infraSet.SQS[name]
+				if !ok {
+					err := fmt.Errorf("s3 bucket %s notifies unknown sqs queue: %s", bucketName, name)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+				queue, err := SQSEnsureInput(infraSet.Name, name, infraSQS.Attr)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+				err = sqsValidatePublisher(queue, "s3")
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			if protocol == s3NotifySNS {
+				infraSNS, ok := infraSet.SNS[name]
+				if !ok {
+					err := fmt.Errorf("s3 bucket %s notifies unknown sns topic: %s", bucketName, name)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+				topic, err := SNSEnsureInput(infraSet.Name, name, infraSNS.Attr, infraSNS.Subscription)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+				if topic.kmsKey == snsAttrKmsKeyDefault {
+					err := fmt.Errorf("s3 cannot publish to sns topic %s encrypted with %s, use kms-key=none or a customer managed key", name, snsAttrKmsKeyDefault)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			s3Notifications[bucketName] = append(s3Notifications[bucketName], notification)
+		}
+	}
+	for lambdaName, infraLambda := range infraSet.Lambda {
 		infraLambda.infraSetName = infraSet.Name
 		infraLambda.dir = path.Dir(yamlPath)
 		if infraLambda.Entrypoint == "" {
@@ -2815,25 +3922,215 @@ func InfraParse(yamlPath string) (*InfraSet, error) {
 				Logger.Println("error:", err)
 				return nil, err
 			}
-			validAttrs := []string{lambdaAttrConcurrency, lambdaAttrMemory, lambdaAttrTimeout, lambdaAttrLogsTTLDays}
+			validAttrs := []string{lambdaAttrConcurrency, lambdaAttrMemory, lambdaAttrTimeout, lambdaAttrLogsTTLDays, lambdaAttrArch, lambdaAttrPython}
 			if !slices.Contains(validAttrs, k) {
 				err := fmt.Errorf("unknown attr: %s", k)
 				Logger.Println("error:", err)
 				return nil, err
 			}
-			if !IsDigit(v) {
-				err := fmt.Errorf("conf value should be digits: %s %s", k, v)
+			switch k {
+			case lambdaAttrArch:
+				if !slices.Contains([]string{string(lambdatypes.ArchitectureX8664), string(lambdatypes.ArchitectureArm64)}, v) {
+					err := fmt.Errorf("arch should be x86_64 or arm64: %s", v)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			case lambdaAttrPython:
+				if !strings.HasSuffix(infraLambda.Entrypoint, ".py") {
+					err := fmt.Errorf("python is only valid for python entrypoints: %s", infraLambda.Entrypoint)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+				if !regexp.MustCompile(`^3\.[0-9]+$`).MatchString(v) {
+					err := fmt.Errorf("python should be a version like %s: %s", lambdaAttrPythonDefault, v)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			default:
+				if !IsDigit(v) {
+					err := fmt.Errorf("conf value should be digits: %s %s", k, v)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+		}
+		if len(infraLambda.Build) > 0 {
+			if !strings.HasSuffix(infraLambda.Entrypoint, ".go") {
+				err := fmt.Errorf("build is only valid for go entrypoints: %s", infraLambda.Entrypoint)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			_, err := lambdaGoBuildInput(infraLambda)
+			if err != nil {
 				Logger.Println("error:", err)
 				return nil, err
 			}
 		}
 		for _, trigger := range infraLambda.Trigger {
-			validTriggers := []string{lambdaTriggerSQS, lambdaTrigerS3, lambdaTriggerDynamoDB, lambdaTriggerApi, lambdaTriggerEcr, lambdaTriggerSchedule, lambdaTriggerWebsocket, lambdaTriggerSes, lambdaTriggerUrl}
+			validTriggers := []string{lambdaTriggerSQS, lambdaTrigerS3, lambdaTriggerDynamoDB, lambdaTriggerKinesis, lambdaTriggerApi, lambdaTriggerEcr, lambdaTriggerEvent, lambdaTriggerSNS, lambdaTriggerSchedule, lambdaTriggerWebsocket, lambdaTriggerSes, lambdaTriggerUrl}
 			if !slices.Contains(validTriggers, trigger.Type) {
 				err := fmt.Errorf("unknown trigger: %#v", trigger)
 				Logger.Println("error:", err)
 				return nil, err
 			}
+			if trigger.Type == lambdaTriggerEvent {
+				_, _, err := lambdaEventPatternInput(trigger)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			if trigger.Type == lambdaTrigerS3 {
+				bucket, notification, err := lambdaS3TriggerInput(lambdaName, trigger)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+				s3Notifications[bucket] = append(s3Notifications[bucket], notification)
+			}
+			if trigger.Type == lambdaTriggerKinesis {
+				if len(trigger.Attr) == 0 || strings.Contains(trigger.Attr[0], "=") {
+					err := fmt.Errorf("kinesis trigger first attr should be the stream name: %v", trigger.Attr)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+				_, err := lambdaKinesisTriggerInput(infraLambda.Name, trigger.Attr[1:])
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			if trigger.Type == lambdaTriggerSQS {
+				if len(trigger.Attr) == 0 || strings.Contains(trigger.Attr[0], "=") {
+					err := fmt.Errorf("sqs trigger first attr should be the queue name: %v", trigger.Attr)
+					Logger.Println("error:", err)
+					return nil, err
+				}
+				_, err := lambdaSQSTriggerInput(infraLambda.Name, trigger.Attr[1:])
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			if trigger.Type == lambdaTriggerSNS {
+				_, err := lambdaSNSTriggerInput(trigger)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			if trigger.Type == lambdaTriggerSchedule {
+				_, err := lambdaScheduleInput(trigger)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			if trigger.Type == lambdaTriggerUrl {
+				_, err := lambdaUrlConfigInput(trigger)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+		}
+	}
+	for bucket, notifications := range s3Notifications {
+		err := s3ValidateNotifications(bucket, notifications)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	for lambdaName, infraLambda := range infraSet.Lambda {
+		for _, trigger := range infraLambda.Trigger {
+			if trigger.Type != lambdaTriggerApi && trigger.Type != lambdaTriggerWebsocket {
+				continue
+			}
+			_, err := lambdaApiStageInput(trigger)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			auth, err := lambdaApiAuthInput(trigger)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if auth == nil || auth.lambdaName == "" {
+				continue
+			}
+			authorizer, ok := infraSet.Lambda[auth.lambdaName]
+			if !ok {
+				err := fmt.Errorf("authorizer must be a lambda in this infraset: %s", auth.lambdaName)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if auth.lambdaName == lambdaName {
+				err := fmt.Errorf("lambda cannot be its own authorizer: %s", lambdaName)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			apiName := lambdaName
+			if trigger.Type == lambdaTriggerWebsocket {
+				apiName += LambdaWebsocketSuffix
+			}
+			authorizer.authorizerFor = append(authorizer.authorizerFor, apiName)
+		}
+	}
+	for apiName, infraApi := range infraSet.Api {
+		infraApi.infraSetName = infraSet.Name
+		if _, ok := infraSet.Lambda[apiName]; ok {
+			err := fmt.Errorf("api name cannot be the same as a lambda name: %s", apiName)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if infraApi.Dns != "" && infraApi.Domain != "" {
+			err := fmt.Errorf("api cannot have both dns and domain: %s", apiName)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if len(infraApi.Route) == 0 {
+			err := fmt.Errorf("api must have at least one route: %s", apiName)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		auth, _, err := apiAttrInput(infraApi)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if auth != nil && auth.lambdaName != "" {
+			authorizer, ok := infraSet.Lambda[auth.lambdaName]
+			if !ok {
+				err := fmt.Errorf("authorizer must be a lambda in this infraset: %s", auth.lambdaName)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			authorizer.authorizerFor = append(authorizer.authorizerFor, apiName)
+		}
+		routeKeys := map[string]bool{}
+		for _, route := range infraApi.Route {
+			routeKey, lambdaName, err := apiRouteInput(route)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if routeKeys[routeKey] {
+				err := fmt.Errorf("duplicate api route: %s %s", apiName, routeKey)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			routeKeys[routeKey] = true
+			infraLambda, ok := infraSet.Lambda[lambdaName]
+			if !ok {
+				err := fmt.Errorf("api route must target a lambda in this infraset: %s", route)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if !slices.Contains(infraLambda.sharedApis, apiName) {
+				infraLambda.sharedApis = append(infraLambda.sharedApis, apiName)
+			}
 		}
 	}
 	return infraSet, nil
@@ -2866,6 +4163,13 @@ func InfraDelete(ctx context.Context, infraSet *InfraSet, preview bool) error {
 			return err
 		}
 	}
+	for apiName := range infraSet.Api {
+		err := ApiDelete(ctx, apiName, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
 	for lambdaName, infraLambda := range infraSet.Lambda {
 		infraLambda.Name = lambdaName
 		infraLambda.Arn, _ = LambdaArn(ctx, lambdaName)
@@ -2896,6 +4200,16 @@ func InfraDelete(ctx context.Context, infraSet *InfraSet, preview bool) error {
 				Logger.Println("error:", err)
 				return err
 			}
+			_, err = LambdaEnsureTriggerEvent(ctx, infraLambda, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			_, err = LambdaEnsureTriggerSNS(ctx, infraLambda, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
 			_, err = LambdaEnsureTriggerSchedule(ctx, infraLambda, preview)
 			if err != nil {
 				Logger.Println("error:", err)
@@ -2906,6 +4220,11 @@ func InfraDelete(ctx context.Context, infraSet *InfraSet, preview bool) error {
 				Logger.Println("error:", err)
 				return err
 			}
+			err = LambdaEnsureTriggerKinesis(ctx, infraLambda, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
 			err = LambdaEnsureTriggerSQS(ctx, infraLambda, preview)
 			if err != nil {
 				Logger.Println("error:", err)
@@ -2949,5 +4268,26 @@ func InfraDelete(ctx context.Context, infraSet *InfraSet, preview bool) error {
 			return err
 		}
 	}
+	for streamName := range infraSet.Kinesis {
+		err := KinesisDeleteStream(ctx, streamName, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	for topicName := range infraSet.SNS {
+		err := SNSDeleteTopic(ctx, topicName, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	for busName := range infraSet.EventBus {
+		err := EventsDeleteBus(ctx, busName, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
 	return nil
 }
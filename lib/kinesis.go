@@ -0,0 +1,596 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kinesis"
+	kinesistypes "github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+var kinesisClient *kinesis.Client
+var kinesisClientLock sync.Mutex
+
+func KinesisClient() *kinesis.Client {
+	kinesisClientLock.Lock()
+	defer kinesisClientLock.Unlock()
+	if kinesisClient == nil {
+		kinesisClient = kinesis.NewFromConfig(*Session())
+	}
+	return kinesisClient
+}
+
+func KinesisClientExplicit(accessKeyID, accessKeySecret, region string) *kinesis.Client {
+	return kinesis.NewFromConfig(*SessionExplicit(accessKeyID, accessKeySecret, region))
+}
+
+const (
+	kinesisAttrShards    = "shards"
+	kinesisAttrRetention = "retention"
+	kinesisAttrKmsKey    = "kms-key"
+
+	kinesisRetentionDefault = 24
+	kinesisKmsKeyDefault    = "alias/aws/kinesis"
+	kinesisKmsKeyNone       = "none"
+
+	kinesisPutBatchSize = 500
+	kinesisPutAttempts  = 8
+)
+
+type kinesisEnsureInput struct {
+	infraSetName   string
+	name           string
+	shards         int // 0 is on-demand
+	retentionHours int
+	kmsKey         string
+}
+
+func (input *kinesisEnsureInput) mode() kinesistypes.StreamMode {
+	if input.shards == 0 {
+		return kinesistypes.StreamModeOnDemand
+	}
+	return kinesistypes.StreamModeProvisioned
+}
+
+func KinesisEnsureInput(infraSetName, streamName string, attrs []string) (*kinesisEnsureInput, error) {
+	input := &kinesisEnsureInput{
+		infraSetName:   infraSetName,
+		name:           streamName,
+		retentionHours: kinesisRetentionDefault,
+		kmsKey:         kinesisKmsKeyDefault,
+	}
+	for _, attr := range attrs {
+		k, v, err := SplitOnce(attr, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case kinesisAttrShards:
+			num, err := strconv.Atoi(v)
+			if err != nil || num < 1 {
+				err := fmt.Errorf("kinesis attr %s should be a positive integer, got: %s", k, v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.shards = num
+		case kinesisAttrRetention:
+			num, err := strconv.Atoi(v)
+			if err != nil || num < 24 || num > 8760 {
+				err := fmt.Errorf("kinesis attr %s should be hours between 24 and 8760, got: %s", k, v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.retentionHours = num
+		case kinesisAttrKmsKey:
+			input.kmsKey = v
+		default:
+			err := fmt.Errorf("unknown kinesis attr: %s", attr)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	return input, nil
+}
+
+// KinesisAttrs is the inverse of KinesisEnsureInput, omitting defaults
+func KinesisAttrs(summary *kinesistypes.StreamDescriptionSummary) []string {
+	var attrs []string
+	if summary.StreamModeDetails != nil && summary.StreamModeDetails.StreamMode == kinesistypes.StreamModeProvisioned {
+		attrs = append(attrs, fmt.Sprintf("%s=%d", kinesisAttrShards, aws.ToInt32(summary.OpenShardCount)))
+	}
+	if aws.ToInt32(summary.RetentionPeriodHours) != kinesisRetentionDefault {
+		attrs = append(attrs, fmt.Sprintf("%s=%d", kinesisAttrRetention, aws.ToInt32(summary.RetentionPeriodHours)))
+	}
+	switch {
+	case summary.EncryptionType != kinesistypes.EncryptionTypeKms:
+		attrs = append(attrs, kinesisAttrKmsKey+"="+kinesisKmsKeyNone)
+	case aws.ToString(summary.KeyId) != kinesisKmsKeyDefault:
+		attrs = append(attrs, kinesisAttrKmsKey+"="+aws.ToString(summary.KeyId))
+	}
+	return attrs
+}
+
+func KinesisStreamArn(ctx context.Context, name string) (string, error) {
+	account, err := StsAccount(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	return fmt.Sprintf("arn:aws:kinesis:%s:%s:stream/%s", Region(), account, name), nil
+}
+
+func KinesisArnToName(arn string) string {
+	// arn:aws:kinesis:region:account:stream/name
+	return strings.Split(Last(strings.SplitN(arn, ":", 6)), "/")[1]
+}
+
+func KinesisListStreams(ctx context.Context) ([]string, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "KinesisListStreams"}
+		d.Start()
+		defer d.End()
+	}
+	var token *string
+	var names []string
+	for {
+		out, err := KinesisClient().ListStreams(ctx, &kinesis.ListStreamsInput{
+			NextToken: token,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		names = append(names, out.StreamNames...)
+		if out.NextToken == nil {
+			break
+		}
+		token = out.NextToken
+	}
+	return names, nil
+}
+
+func KinesisDescribeStream(ctx context.Context, name string) (*kinesistypes.StreamDescriptionSummary, error) {
+	out, err := KinesisClient().DescribeStreamSummary(ctx, &kinesis.DescribeStreamSummaryInput{
+		StreamName: aws.String(name),
+	})
+	if err != nil {
+		var rnfe *kinesistypes.ResourceNotFoundException
+		if !errors.As(err, &rnfe) {
+			Logger.Println("error:", err)
+		}
+		return nil, err
+	}
+	return out.StreamDescriptionSummary, nil
+}
+
+// KinesisWaitForActive waits up to 10 minutes for a stream to be active
+func KinesisWaitForActive(ctx context.Context, name string) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "KinesisWaitForActive"}
+		d.Start()
+		defer d.End()
+	}
+	for range 300 {
+		summary, err := KinesisDescribeStream(ctx, name)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if summary.StreamStatus == kinesistypes.StreamStatusActive {
+			return nil
+		}
+		Logger.Println("waiting for stream active:", name)
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			Logger.Println("error:", ctx.Err())
+			return ctx.Err()
+		}
+	}
+	err := fmt.Errorf("timed out waiting for stream active: %s", name)
+	Logger.Println("error:", err)
+	return err
+}
+
+func KinesisEnsure(ctx context.Context, input *kinesisEnsureInput, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "KinesisEnsure"}
+		d.Start()
+		defer d.End()
+	}
+	summary, err := KinesisDescribeStream(ctx, input.name)
+	if err != nil {
+		var rnfe *kinesistypes.ResourceNotFoundException
+		if !errors.As(err, &rnfe) {
+			Logger.Println("error:", err)
+			return err
+		}
+		if !preview {
+			createInput := &kinesis.CreateStreamInput{
+				StreamName:        aws.String(input.name),
+				StreamModeDetails: &kinesistypes.StreamModeDetails{StreamMode: input.mode()},
+				Tags:              map[string]string{infraSetTagName: input.infraSetName},
+			}
+			if input.shards > 0 {
+				createInput.ShardCount = aws.Int32(int32(input.shards))
+			}
+			_, err := KinesisClient().CreateStream(ctx, createInput)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			err = KinesisWaitForActive(ctx, input.name)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			summary, err = KinesisDescribeStream(ctx, input.name)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		} else {
+			summary = &kinesistypes.StreamDescriptionSummary{
+				StreamModeDetails:    &kinesistypes.StreamModeDetails{StreamMode: input.mode()},
+				OpenShardCount:       aws.Int32(int32(input.shards)),
+				RetentionPeriodHours: aws.Int32(kinesisRetentionDefault),
+				EncryptionType:       kinesistypes.EncryptionTypeNone,
+			}
+		}
+		Logger.Println(PreviewString(preview)+"created kinesis stream:", input.name, input.mode())
+	}
+	// updates require an active stream, so wait before each one
+	update := func(desc string, fn func() error) error {
+		if !preview {
+			err := KinesisWaitForActive(ctx, input.name)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			err = fn()
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"updated kinesis stream:", input.name, desc)
+		return nil
+	}
+	if summary.StreamModeDetails == nil || summary.StreamModeDetails.StreamMode != input.mode() {
+		err := update(fmt.Sprintf("mode=%s", input.mode()), func() error {
+			arn, err := KinesisStreamArn(ctx, input.name)
+			if err != nil {
+				return err
+			}
+			_, err = KinesisClient().UpdateStreamMode(ctx, &kinesis.UpdateStreamModeInput{
+				StreamARN:         aws.String(arn),
+				StreamModeDetails: &kinesistypes.StreamModeDetails{StreamMode: input.mode()},
+			})
+			return err
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	if input.shards > 0 && int(aws.ToInt32(summary.OpenShardCount)) != input.shards {
+		err := update(fmt.Sprintf("%s: %d => %d", kinesisAttrShards, aws.ToInt32(summary.OpenShardCount), input.shards), func() error {
+			_, err := KinesisClient().UpdateShardCount(ctx, &kinesis.UpdateShardCountInput{
+				StreamName:       aws.String(input.name),
+				TargetShardCount: aws.Int32(int32(input.shards)),
+				ScalingType:      kinesistypes.ScalingTypeUniformScaling,
+			})
+			return err
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	retention := int(aws.ToInt32(summary.RetentionPeriodHours))
+	if retention != input.retentionHours {
+		err := update(fmt.Sprintf("%s: %d => %d", kinesisAttrRetention, retention, input.retentionHours), func() error {
+			if input.retentionHours > retention {
+				_, err := KinesisClient().IncreaseStreamRetentionPeriod(ctx, &kinesis.IncreaseStreamRetentionPeriodInput{
+					StreamName:           aws.String(input.name),
+					RetentionPeriodHours: aws.Int32(int32(input.retentionHours)),
+				})
+				return err
+			}
+			_, err := KinesisClient().DecreaseStreamRetentionPeriod(ctx, &kinesis.DecreaseStreamRetentionPeriodInput{
+				StreamName:           aws.String(input.name),
+				RetentionPeriodHours: aws.Int32(int32(input.retentionHours)),
+			})
+			return err
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	encrypted := summary.EncryptionType == kinesistypes.EncryptionTypeKms
+	keyID := aws.ToString(summary.KeyId)
+	startEncryption := func() error {
+		_, err := KinesisClient().StartStreamEncryption(ctx, &kinesis.StartStreamEncryptionInput{
+			StreamName:     aws.String(input.name),
+			EncryptionType: kinesistypes.EncryptionTypeKms,
+			KeyId:          aws.String(input.kmsKey),
+		})
+		return err
+	}
+	if encrypted && input.kmsKey == kinesisKmsKeyNone {
+		err := update(fmt.Sprintf("%s: %s => %s", kinesisAttrKmsKey, keyID, input.kmsKey), func() error {
+			_, err := KinesisClient().StopStreamEncryption(ctx, &kinesis.StopStreamEncryptionInput{
+				StreamName:     aws.String(input.name),
+				EncryptionType: kinesistypes.EncryptionTypeKms,
+				KeyId:          aws.String(keyID),
+			})
+			return err
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	} else if encrypted && input.kmsKey != keyID {
+		// starting encryption on an encrypted stream switches to the new key
+		// without leaving the stream unencrypted in between
+		err := update(fmt.Sprintf("%s: %s => %s", kinesisAttrKmsKey, keyID, input.kmsKey), startEncryption)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	} else if !encrypted && input.kmsKey != kinesisKmsKeyNone {
+		err := update(fmt.Sprintf("%s: %s => %s", kinesisAttrKmsKey, kinesisKmsKeyNone, input.kmsKey), startEncryption)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func KinesisDeleteStream(ctx context.Context, name string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "KinesisDeleteStream"}
+		d.Start()
+		defer d.End()
+	}
+	_, err := KinesisDescribeStream(ctx, name)
+	if err != nil {
+		var rnfe *kinesistypes.ResourceNotFoundException
+		if errors.As(err, &rnfe) {
+			return nil
+		}
+		Logger.Println("error:", err)
+		return err
+	}
+	if !preview {
+		_, err := KinesisClient().DeleteStream(ctx, &kinesis.DeleteStreamInput{
+			StreamName:              aws.String(name),
+			EnforceConsumerDeletion: aws.Bool(true),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	Logger.Println(PreviewString(preview)+"deleted kinesis stream:", name)
+	return nil
+}
+
+type KinesisPutEntry struct {
+	PartitionKey string
+	Data         []byte
+}
+
+// KinesisPut writes records in batches, retrying throttled records with backoff
+func KinesisPut(ctx context.Context, streamName string, entries []KinesisPutEntry) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "KinesisPut"}
+		d.Start()
+		defer d.End()
+	}
+	for batch := range slices.Chunk(entries, kinesisPutBatchSize) {
+		var records []kinesistypes.PutRecordsRequestEntry
+		for _, entry := range batch {
+			records = append(records, kinesistypes.PutRecordsRequestEntry{
+				PartitionKey: aws.String(entry.PartitionKey),
+				Data:         entry.Data,
+			})
+		}
+		for attempt := 0; len(records) > 0; attempt++ {
+			out, err := KinesisClient().PutRecords(ctx, &kinesis.PutRecordsInput{
+				StreamName: aws.String(streamName),
+				Records:    records,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			if aws.ToInt32(out.FailedRecordCount) == 0 {
+				break
+			}
+			var failed []kinesistypes.PutRecordsRequestEntry
+			var lastErr error
+			for i, result := range out.Records {
+				if result.ErrorCode != nil {
+					failed = append(failed, records[i])
+					lastErr = fmt.Errorf("failed to put record: %s %s", *result.ErrorCode, aws.ToString(result.ErrorMessage))
+				}
+			}
+			if attempt+1 == kinesisPutAttempts {
+				Logger.Println("error:", lastErr)
+				return lastErr
+			}
+			records = failed
+			time.Sleep(time.Duration(100<<attempt) * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+func KinesisListShards(ctx context.Context, streamName string) ([]kinesistypes.Shard, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "KinesisListShards"}
+		d.Start()
+		defer d.End()
+	}
+	var shards []kinesistypes.Shard
+	input := &kinesis.ListShardsInput{
+		StreamName: aws.String(streamName),
+	}
+	for {
+		out, err := KinesisClient().ListShards(ctx, input)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		shards = append(shards, out.Shards...)
+		if out.NextToken == nil {
+			break
+		}
+		input = &kinesis.ListShardsInput{NextToken: out.NextToken} // stream name must be omitted with a token
+	}
+	return shards, nil
+}
+
+// KinesisTail reads every shard of a stream starting at iteratorType, following
+// resharding into child shards. timestamp is used with AT_TIMESTAMP. callback
+// is never called concurrently. runs until ctx is cancelled or an error occurs.
+func KinesisTail(ctx context.Context, streamName string, iteratorType kinesistypes.ShardIteratorType, timestamp *time.Time, callback func(shardID string, record kinesistypes.Record)) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "KinesisTail"}
+		d.Start()
+		defer d.End()
+	}
+	shards, err := KinesisListShards(ctx, streamName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	lock := &sync.Mutex{}
+	started := map[string]bool{}
+	errChan := make(chan error, 1)
+	var tail func(shardID string, iteratorType kinesistypes.ShardIteratorType)
+	tail = func(shardID string, iteratorType kinesistypes.ShardIteratorType) {
+		lock.Lock()
+		if started[shardID] {
+			lock.Unlock()
+			return
+		}
+		started[shardID] = true
+		lock.Unlock()
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logRecover(r)
+				}
+			}()
+			fail := func(err error) {
+				select {
+				case errChan <- err:
+				default:
+				}
+			}
+			var lastSequenceNumber *string
+			// iterators expire after 5 minutes, so they are reacquired after the last record seen
+			getIterator := func() (*string, error) {
+				input := &kinesis.GetShardIteratorInput{
+					StreamName:        aws.String(streamName),
+					ShardId:           aws.String(shardID),
+					ShardIteratorType: iteratorType,
+				}
+				if lastSequenceNumber != nil {
+					input.ShardIteratorType = kinesistypes.ShardIteratorTypeAfterSequenceNumber
+					input.StartingSequenceNumber = lastSequenceNumber
+				} else if iteratorType == kinesistypes.ShardIteratorTypeAtTimestamp {
+					input.Timestamp = timestamp
+				}
+				out, err := KinesisClient().GetShardIterator(ctx, input)
+				if err != nil {
+					return nil, err
+				}
+				return out.ShardIterator, nil
+			}
+			iterator, err := getIterator()
+			if err != nil {
+				fail(err)
+				return
+			}
+			for iterator != nil {
+				out, err := KinesisClient().GetRecords(ctx, &kinesis.GetRecordsInput{
+					ShardIterator: iterator,
+				})
+				if err != nil {
+					var throttled *kinesistypes.ProvisionedThroughputExceededException
+					if errors.As(err, &throttled) {
+						time.Sleep(time.Second)
+						continue
+					}
+					var expired *kinesistypes.ExpiredIteratorException
+					if errors.As(err, &expired) {
+						iterator, err = getIterator()
+						if err != nil {
+							fail(err)
+							return
+						}
+						continue
+					}
+					fail(err)
+					return
+				}
+				lock.Lock()
+				for _, record := range out.Records {
+					callback(shardID, record)
+				}
+				lock.Unlock()
+				if len(out.Records) > 0 {
+					lastSequenceNumber = Last(out.Records).SequenceNumber
+				}
+				iterator = out.NextShardIterator
+				if len(out.Records) == 0 {
+					time.Sleep(time.Second)
+				} else {
+					time.Sleep(200 * time.Millisecond) // shards allow 5 reads per second
+				}
+			}
+			// the shard was closed by resharding, continue with its children
+			shards, err := KinesisListShards(ctx, streamName)
+			if err != nil {
+				fail(err)
+				return
+			}
+			for _, shard := range shards {
+				if aws.ToString(shard.ParentShardId) == shardID || aws.ToString(shard.AdjacentParentShardId) == shardID {
+					tail(*shard.ShardId, kinesistypes.ShardIteratorTypeTrimHorizon)
+				}
+			}
+		}()
+	}
+	shardIDs := map[string]bool{}
+	for _, shard := range shards {
+		shardIDs[*shard.ShardId] = true
+	}
+	for _, shard := range shards {
+		open := shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber == nil
+		root := !shardIDs[aws.ToString(shard.ParentShardId)] && !shardIDs[aws.ToString(shard.AdjacentParentShardId)]
+		if (iteratorType == kinesistypes.ShardIteratorTypeLatest && open) || (iteratorType != kinesistypes.ShardIteratorTypeLatest && root) {
+			tail(*shard.ShardId, iteratorType)
+		}
+	}
+	select {
+	case err := <-errChan:
+		Logger.Println("error:", err)
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	kinesistypes "github.com/aws/aws-sdk-go-v2/service/kinesis/types"
+)
+
+func TestKinesisEnsureInput(t *testing.T) {
+	type test struct {
+		attrs    []string
+		expected *kinesisEnsureInput
+		err      bool
+	}
+	tests := []test{
+		{nil, &kinesisEnsureInput{name: "test-stream", retentionHours: 24, kmsKey: kinesisKmsKeyDefault}, false},
+		{[]string{"shards=4"}, &kinesisEnsureInput{name: "test-stream", shards: 4, retentionHours: 24, kmsKey: kinesisKmsKeyDefault}, false},
+		{[]string{"retention=168"}, &kinesisEnsureInput{name: "test-stream", retentionHours: 168, kmsKey: kinesisKmsKeyDefault}, false},
+		{[]string{"kms-key=none"}, &kinesisEnsureInput{name: "test-stream", retentionHours: 24, kmsKey: kinesisKmsKeyNone}, false},
+		{[]string{"kms-key=alias/my-key", "shards=1"}, &kinesisEnsureInput{name: "test-stream", shards: 1, retentionHours: 24, kmsKey: "alias/my-key"}, false},
+		{[]string{"shards=0"}, nil, true},
+		{[]string{"shards=abc"}, nil, true},
+		{[]string{"retention=23"}, nil, true},
+		{[]string{"retention=8761"}, nil, true},
+		{[]string{"unknown=1"}, nil, true},
+		{[]string{"shards"}, nil, true},
+	}
+	for _, test := range tests {
+		input, err := KinesisEnsureInput("", "test-stream", test.attrs)
+		if (err != nil) != test.err {
+			t.Errorf("\nattrs: %v\nexpected err: %v\ngot: %v\n", test.attrs, test.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(input, test.expected) {
+			t.Errorf("\nattrs: %v\ngot:\n%+v\nwant:\n%+v\n", test.attrs, input, test.expected)
+		}
+	}
+}
+
+func TestKinesisAttrs(t *testing.T) {
+	type test struct {
+		summary  *kinesistypes.StreamDescriptionSummary
+		expected []string
+	}
+	tests := []test{
+		{
+			&kinesistypes.StreamDescriptionSummary{
+				StreamModeDetails:    &kinesistypes.StreamModeDetails{StreamMode: kinesistypes.StreamModeOnDemand},
+				RetentionPeriodHours: aws.Int32(24),
+				EncryptionType:       kinesistypes.EncryptionTypeKms,
+				KeyId:                aws.String(kinesisKmsKeyDefault),
+			},
+			nil,
+		},
+		{
+			&kinesistypes.StreamDescriptionSummary{
+				StreamModeDetails:    &kinesistypes.StreamModeDetails{StreamMode: kinesistypes.StreamModeProvisioned},
+				OpenShardCount:       aws.Int32(2),
+				RetentionPeriodHours: aws.Int32(48),
+				EncryptionType:       kinesistypes.EncryptionTypeNone,
+			},
+			[]string{"shards=2", "retention=48", "kms-key=none"},
+		},
+		{
+			&kinesistypes.StreamDescriptionSummary{
+				StreamModeDetails:    &kinesistypes.StreamModeDetails{StreamMode: kinesistypes.StreamModeOnDemand},
+				RetentionPeriodHours: aws.Int32(24),
+				EncryptionType:       kinesistypes.EncryptionTypeKms,
+				KeyId:                aws.String("alias/my-key"),
+			},
+			[]string{"kms-key=alias/my-key"},
+		},
+	}
+	for _, test := range tests {
+		attrs := KinesisAttrs(test.summary)
+		if !reflect.DeepEqual(attrs, test.expected) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", attrs, test.expected)
+			continue
+		}
+		_, err := KinesisEnsureInput("", "test-stream", attrs)
+		if err != nil {
+			t.Errorf("\nattrs do not parse: %v: %v", attrs, err)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/acm"
 	"github.com/aws/aws-sdk-go-v2/service/apigatewayv2"
 	apitypes "github.com/aws/aws-sdk-go-v2/service/apigatewayv2/types"
@@ -31,7 +32,11 @@ import (
 	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schedulertypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
 	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
 )
 
 const (
@@ -39,11 +44,15 @@ const (
 	lambdaAttrMemory      = "memory"
 	lambdaAttrTimeout     = "timeout"
 	lambdaAttrLogsTTLDays = "logs-ttl-days"
+	lambdaAttrArch        = "arch"
+	lambdaAttrPython      = "python"
 
 	lambdaAttrConcurrencyDefault = 0
 	lambdaAttrMemoryDefault      = 128
 	lambdaAttrTimeoutDefault     = 300
 	lambdaAttrLogsTTLDaysDefault = 7
+	lambdaAttrArchDefault        = string(lambdatypes.ArchitectureX8664)
+	lambdaAttrPythonDefault      = "3.13"
 
 	lambdaTriggerSes           = "ses"
 	lambdaTriggerSesAttrDns    = "dns"
@@ -53,14 +62,46 @@ const (
 	lambdaTriggerSQS       = "sqs"
 	lambdaTrigerS3         = "s3"
 	lambdaTriggerDynamoDB  = "dynamodb"
+	lambdaTriggerKinesis   = "kinesis"
 	lambdaTriggerSchedule  = "schedule"
 	lambdaTriggerEcr       = "ecr"
+	lambdaTriggerEvent     = "event"
+	lambdaTriggerSNS       = "sns"
 	lambdaTriggerApi       = "api"
 	lambdaTriggerWebsocket = "websocket"
 	lambdaTriggerUrl       = "url"
 
-	lambdaTriggerApiAttrDns    = "dns"
-	lambdaTriggerApiAttrDomain = "domain"
+	lambdaTriggerUrlAttrAuth               = "auth"
+	lambdaTriggerUrlAttrInvokeMode         = "invoke-mode"
+	lambdaTriggerUrlAttrCorsOrigin         = "cors-origin"
+	lambdaTriggerUrlAttrCorsMethod         = "cors-method"
+	lambdaTriggerUrlAttrCorsHeader         = "cors-header"
+	lambdaTriggerUrlAttrCorsExposeHeader   = "cors-expose-header"
+	lambdaTriggerUrlAttrCorsMaxAge         = "cors-max-age"
+	lambdaTriggerUrlAttrCorsCredentials    = "cors-credentials"
+	lambdaTriggerUrlAttrAuthNone           = "none"
+	lambdaTriggerUrlAttrAuthIam            = "iam"
+	lambdaTriggerUrlAttrInvokeModeStream   = "stream"
+	lambdaTriggerUrlAttrInvokeModeBuffered = "buffered"
+
+	lambdaTriggerApiAttrDns                = "dns"
+	lambdaTriggerApiAttrDomain             = "domain"
+	lambdaTriggerApiAttrJwtIssuer          = "jwt-issuer"
+	lambdaTriggerApiAttrJwtAudience        = "jwt-audience"
+	lambdaTriggerApiAttrAuthorizer         = "authorizer"
+	lambdaTriggerApiAttrAuthorizerIdentity = "authorizer-identity"
+	lambdaTriggerApiAttrAuthorizerTTL      = "authorizer-ttl"
+
+	lambdaTriggerApiAttrThrottleRate      = "throttle-rate"
+	lambdaTriggerApiAttrThrottleBurst     = "throttle-burst"
+	lambdaTriggerApiAttrAccessLogs        = "access-logs"
+	lambdaTriggerApiAttrAccessLogsTTLDays = "access-logs-ttl-days"
+	lambdaTriggerApiAttrDetailedMetrics   = "detailed-metrics"
+
+	lambdaApiAccessLogsTTLDaysDefault = 7
+
+	lambdaApiAuthorizerName          = "authorizer"
+	lambdaApiAuthorizerPayloadFormat = "2.0"
 
 	lambdaDollarDefault     = "$default"
 	lambdaDollarConnect     = "$connect"
@@ -76,11 +117,19 @@ const (
 	lambdaEventRuleNameSeparator = "___"
 	LambdaWebsocketSuffix        = lambdaEventRuleNameSeparator + "websocket"
 
-	lambdaRuntimePython    = "python3.13"
+	lambdaRuntimePython    = "python" + lambdaAttrPythonDefault
 	lambdaRuntimeGo        = "provided.al2023"
 	lambdaRuntimeContainer = "container"
 
 	lambdaUrlFuncSid = "FunctionUrlInvoke"
+
+	lambdaBuildAttrTags     = "tags"
+	lambdaBuildAttrLdflags  = "ldflags"
+	lambdaBuildAttrTrimpath = "trimpath"
+	lambdaBuildAttrCgo      = "cgo"
+	lambdaBuildAttrDir      = "dir"
+	lambdaBuildAttrCmd      = "cmd"
+	lambdaBuildAttrBinary   = "binary"
 )
 
 var lambdaClient *lambda.Client
@@ -144,6 +193,153 @@ func LambdaSetConcurrency(ctx context.Context, lambdaName string, concurrency in
 	return nil
 }
 
+type lambdaUrlConfig struct {
+	authType   lambdatypes.FunctionUrlAuthType
+	invokeMode lambdatypes.InvokeMode
+	cors       *lambdatypes.Cors
+}
+
+func lambdaUrlConfigInput(trigger *InfraTrigger) (*lambdaUrlConfig, error) {
+	conf := &lambdaUrlConfig{
+		authType:   lambdatypes.FunctionUrlAuthTypeNone,
+		invokeMode: lambdatypes.InvokeModeResponseStream,
+	}
+	cors := &lambdatypes.Cors{}
+	hasCors := false
+	for _, line := range trigger.Attr {
+		k, v, err := SplitOnce(line, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case lambdaTriggerUrl: // listed by infra-ls, not configurable
+		case lambdaTriggerUrlAttrAuth:
+			switch v {
+			case lambdaTriggerUrlAttrAuthNone:
+				conf.authType = lambdatypes.FunctionUrlAuthTypeNone
+			case lambdaTriggerUrlAttrAuthIam:
+				conf.authType = lambdatypes.FunctionUrlAuthTypeAwsIam
+			default:
+				err := fmt.Errorf("url auth should be none or iam: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		case lambdaTriggerUrlAttrInvokeMode:
+			switch v {
+			case lambdaTriggerUrlAttrInvokeModeStream:
+				conf.invokeMode = lambdatypes.InvokeModeResponseStream
+			case lambdaTriggerUrlAttrInvokeModeBuffered:
+				conf.invokeMode = lambdatypes.InvokeModeBuffered
+			default:
+				err := fmt.Errorf("url invoke-mode should be stream or buffered: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		case lambdaTriggerUrlAttrCorsOrigin:
+			hasCors = true
+			cors.AllowOrigins = append(cors.AllowOrigins, v)
+		case lambdaTriggerUrlAttrCorsMethod:
+			hasCors = true
+			cors.AllowMethods = append(cors.AllowMethods, strings.ToUpper(v))
+		case lambdaTriggerUrlAttrCorsHeader:
+			hasCors = true
+			cors.AllowHeaders = append(cors.AllowHeaders, strings.ToLower(v))
+		case lambdaTriggerUrlAttrCorsExposeHeader:
+			hasCors = true
+			cors.ExposeHeaders = append(cors.ExposeHeaders, strings.ToLower(v))
+		case lambdaTriggerUrlAttrCorsMaxAge:
+			if !IsDigit(v) {
+				err := fmt.Errorf("url cors-max-age should be digits: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			hasCors = true
+			cors.MaxAge = aws.Int32(int32(Atoi(v)))
+		case lambdaTriggerUrlAttrCorsCredentials:
+			val, err := strconv.ParseBool(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			hasCors = true
+			cors.AllowCredentials = aws.Bool(val)
+		default:
+			err := fmt.Errorf("unknown url attr: %s", line)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	if hasCors {
+		conf.cors = cors
+	}
+	return conf, nil
+}
+
+// lambdaUrlConfigMap flattens a url config for diffing and listing, omitting defaults
+func lambdaUrlConfigMap(authType lambdatypes.FunctionUrlAuthType, invokeMode lambdatypes.InvokeMode, cors *lambdatypes.Cors) map[string]string {
+	m := map[string]string{}
+	if authType == lambdatypes.FunctionUrlAuthTypeAwsIam {
+		m[lambdaTriggerUrlAttrAuth] = lambdaTriggerUrlAttrAuthIam
+	}
+	if invokeMode == lambdatypes.InvokeModeBuffered {
+		m[lambdaTriggerUrlAttrInvokeMode] = lambdaTriggerUrlAttrInvokeModeBuffered
+	}
+	if cors != nil {
+		join := func(vals []string) string {
+			vals = slices.Clone(vals)
+			slices.Sort(vals)
+			return strings.Join(vals, ",")
+		}
+		m[lambdaTriggerUrlAttrCorsOrigin] = join(cors.AllowOrigins)
+		m[lambdaTriggerUrlAttrCorsMethod] = join(cors.AllowMethods)
+		m[lambdaTriggerUrlAttrCorsHeader] = strings.ToLower(join(cors.AllowHeaders))
+		m[lambdaTriggerUrlAttrCorsExposeHeader] = strings.ToLower(join(cors.ExposeHeaders))
+		if cors.MaxAge != nil && *cors.MaxAge != 0 {
+			m[lambdaTriggerUrlAttrCorsMaxAge] = fmt.Sprint(*cors.MaxAge)
+		}
+		if cors.AllowCredentials != nil && *cors.AllowCredentials {
+			m[lambdaTriggerUrlAttrCorsCredentials] = "true"
+		}
+	}
+	for k, v := range m {
+		if v == "" {
+			delete(m, k)
+		}
+	}
+	return m
+}
+
+// lambdaUrlConfigAttrs is the inverse of lambdaUrlConfigInput, used by infra-ls
+func lambdaUrlConfigAttrs(authType lambdatypes.FunctionUrlAuthType, invokeMode lambdatypes.InvokeMode, cors *lambdatypes.Cors) []string {
+	var attrs []string
+	m := lambdaUrlConfigMap(authType, invokeMode, cors)
+	for _, k := range []string{
+		lambdaTriggerUrlAttrAuth,
+		lambdaTriggerUrlAttrInvokeMode,
+		lambdaTriggerUrlAttrCorsOrigin,
+		lambdaTriggerUrlAttrCorsMethod,
+		lambdaTriggerUrlAttrCorsHeader,
+		lambdaTriggerUrlAttrCorsExposeHeader,
+		lambdaTriggerUrlAttrCorsMaxAge,
+		lambdaTriggerUrlAttrCorsCredentials,
+	} {
+		v, ok := m[k]
+		if !ok {
+			continue
+		}
+		switch k {
+		case lambdaTriggerUrlAttrCorsOrigin, lambdaTriggerUrlAttrCorsMethod, lambdaTriggerUrlAttrCorsHeader, lambdaTriggerUrlAttrCorsExposeHeader:
+			for val := range strings.SplitSeq(v, ",") {
+				attrs = append(attrs, k+"="+val)
+			}
+		default:
+			attrs = append(attrs, k+"="+v)
+		}
+	}
+	return attrs
+}
+
 func LambdaEnsureTriggerURL(ctx context.Context, infraLambda *InfraLambda, preview bool) ([]string, error) {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "LambdaEnsureTriggerURL"}
@@ -151,14 +347,23 @@ func LambdaEnsureTriggerURL(ctx context.Context, infraLambda *InfraLambda, previ
 		defer d.End()
 	}
 	var sids []string
-	hasURL := false
+	var urlTrigger *InfraTrigger
 	for _, trigger := range infraLambda.Trigger {
 		if trigger.Type == lambdaTriggerUrl {
-			hasURL = true
-			break
+			if urlTrigger != nil {
+				err := fmt.Errorf("only one url trigger is allowed per lambda: %s", infraLambda.Name)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			urlTrigger = trigger
 		}
 	}
-	if hasURL {
+	if urlTrigger != nil {
+		conf, err := lambdaUrlConfigInput(urlTrigger)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
 		outCfg, err := LambdaClient().GetFunctionUrlConfig(ctx, &lambda.GetFunctionUrlConfigInput{
 			FunctionName: aws.String(infraLambda.Name),
 		})
@@ -171,23 +376,35 @@ func LambdaEnsureTriggerURL(ctx context.Context, infraLambda *InfraLambda, previ
 			if !preview {
 				_, err := LambdaClient().CreateFunctionUrlConfig(ctx, &lambda.CreateFunctionUrlConfigInput{
 					FunctionName: aws.String(infraLambda.Name),
-					AuthType:     lambdatypes.FunctionUrlAuthTypeNone,
-					InvokeMode:   lambdatypes.InvokeModeResponseStream,
+					AuthType:     conf.authType,
+					InvokeMode:   conf.invokeMode,
+					Cors:         conf.cors,
 				})
 				if err != nil {
 					Logger.Println("error:", err)
 					return nil, err
 				}
 			}
-			Logger.Println(PreviewString(preview)+"created function url:", infraLambda.Name)
+			Logger.Println(PreviewString(preview)+"created function url:", infraLambda.Name, strings.Join(lambdaUrlConfigAttrs(conf.authType, conf.invokeMode, conf.cors), " "))
 		} else {
-			if outCfg.AuthType != lambdatypes.FunctionUrlAuthTypeNone ||
-				outCfg.InvokeMode != lambdatypes.InvokeModeResponseStream {
+			existing := lambdaUrlConfigMap(outCfg.AuthType, outCfg.InvokeMode, outCfg.Cors)
+			new := lambdaUrlConfigMap(conf.authType, conf.invokeMode, conf.cors)
+			diff, err := diffMapStringString(new, existing, PreviewString(preview)+"function url "+infraLambda.Name+":", true)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if diff {
+				cors := conf.cors
+				if cors == nil {
+					cors = &lambdatypes.Cors{} // an empty cors removes any existing cors config
+				}
 				if !preview {
 					_, err = LambdaClient().UpdateFunctionUrlConfig(ctx, &lambda.UpdateFunctionUrlConfigInput{
 						FunctionName: aws.String(infraLambda.Name),
-						AuthType:     lambdatypes.FunctionUrlAuthTypeNone,
-						InvokeMode:   lambdatypes.InvokeModeResponseStream,
+						AuthType:     conf.authType,
+						InvokeMode:   conf.invokeMode,
+						Cors:         cors,
 					})
 					if err != nil {
 						Logger.Println("error:", err)
@@ -197,6 +414,9 @@ func LambdaEnsureTriggerURL(ctx context.Context, infraLambda *InfraLambda, previ
 				Logger.Println(PreviewString(preview)+"updated function url config:", infraLambda.Name)
 			}
 		}
+		if conf.authType == lambdatypes.FunctionUrlAuthTypeAwsIam {
+			return sids, nil // iam urls need no resource policy, callers are authorized by their own iam policies, and the public permission is removed as unused
+		}
 		out, err := LambdaClient().GetPolicy(ctx, &lambda.GetPolicyInput{
 			FunctionName: aws.String(infraLambda.Name),
 		})
@@ -574,194 +794,757 @@ func LambdaEnsureTriggerEcr(ctx context.Context, infraLambda *InfraLambda, previ
 	return permissionSids, nil
 }
 
-func LambdaEnsureTriggerS3(ctx context.Context, infraLambda *InfraLambda, preview bool) ([]string, error) {
-	if doDebug {
-		d := &Debug{start: time.Now(), name: "LambdaEnsureTriggerS3"}
-		d.Start()
-		defer d.End()
-	}
-	var permissionSids []string
-	events := []s3types.Event{
-		"s3:ObjectCreated:*",
-		"s3:ObjectRemoved:*",
-	}
-	var triggers []string
-	for _, trigger := range infraLambda.Trigger {
-		if trigger.Type == lambdaTrigerS3 {
-			triggers = append(triggers, trigger.Attr[0])
-		}
-	}
-	if len(triggers) > 0 {
-		for _, bucket := range triggers {
-			sid, err := lambdaEnsurePermission(ctx, infraLambda.Name, "s3.amazonaws.com", "arn:aws:s3:::"+bucket, preview)
-			if err != nil {
-				Logger.Println("error:", err)
-				return nil, err
-			}
-			permissionSids = append(permissionSids, sid)
-			s3Client, err := S3ClientBucketRegion(bucket)
-			if err != nil {
-				var noBucket *s3types.NoSuchBucket
-				if !errors.As(err, &noBucket) && !preview {
+const (
+	lambdaTriggerEventAttrSource     = "source"
+	lambdaTriggerEventAttrDetailType = "detail-type"
+	lambdaTriggerEventAttrDetail     = "detail"
+	lambdaTriggerEventAttrBus        = "bus"
+
+	lambdaTriggerEventBusDefault = eventsBusDefault
+	lambdaTriggerEventRulePrefix = "event_"
+)
+
+// lambdaEventPatternInput parses an event trigger into its bus and event pattern
+//
+//	source=aws.ec2
+//	detail-type=EC2 Instance State-change Notification
+//	detail.state=running
+//	bus=VALUE
+func lambdaEventPatternInput(trigger *InfraTrigger) (string, string, error) {
+	bus := lambdaTriggerEventBusDefault
+	pattern := map[string]any{}
+	for _, attr := range trigger.Attr {
+		k, v, err := SplitOnce(attr, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return "", "", err
+		}
+		switch {
+		case k == lambdaTriggerEventAttrBus:
+			bus = v
+		case k == lambdaTriggerEventAttrSource || k == lambdaTriggerEventAttrDetailType:
+			values, _ := pattern[k].([]string)
+			pattern[k] = append(values, v)
+		case strings.HasPrefix(k, lambdaTriggerEventAttrDetail+"."):
+			parts := strings.Split(k, ".")
+			node := pattern
+			for _, part := range parts[:len(parts)-1] {
+				if part == "" {
+					err := fmt.Errorf("invalid event trigger attr: %s", attr)
 					Logger.Println("error:", err)
-					return nil, err
+					return "", "", err
 				}
-				s3Client = nil
-			}
-			var out *s3.GetBucketNotificationConfigurationOutput
-			if s3Client != nil {
-				getOut, err := s3Client.GetBucketNotificationConfiguration(ctx, &s3.GetBucketNotificationConfigurationInput{
-					Bucket: aws.String(bucket),
-				})
-				if err != nil {
-					var noBucket *s3types.NoSuchBucket
-					if !errors.As(err, &noBucket) {
-						Logger.Println("error:", err)
-						return nil, err
-					}
-				} else {
-					out = getOut
+				if node[part] == nil {
+					node[part] = map[string]any{}
 				}
-			}
-			if out == nil {
-				out = &s3.GetBucketNotificationConfigurationOutput{
-					LambdaFunctionConfigurations: []s3types.LambdaFunctionConfiguration{},
+				child, ok := node[part].(map[string]any)
+				if !ok {
+					err := fmt.Errorf("event trigger attr conflicts with another attr: %s", attr)
+					Logger.Println("error:", err)
+					return "", "", err
 				}
+				node = child
 			}
-			var existingEvents []s3types.Event
-			for _, conf := range out.LambdaFunctionConfigurations {
-				if *conf.LambdaFunctionArn == infraLambda.Arn {
-					existingEvents = conf.Events
-				}
+			key := Last(parts)
+			if key == "" {
+				err := fmt.Errorf("invalid event trigger attr: %s", attr)
+				Logger.Println("error:", err)
+				return "", "", err
 			}
-			if !reflect.DeepEqual(existingEvents, events) {
-				var confs []s3types.LambdaFunctionConfiguration
-				for _, conf := range out.LambdaFunctionConfigurations {
-					if *conf.LambdaFunctionArn != infraLambda.Arn {
-						confs = append(confs, conf)
-					}
-				}
-				confs = append(confs, s3types.LambdaFunctionConfiguration{
-					LambdaFunctionArn: aws.String(infraLambda.Arn),
-					Events:            events,
-				})
-				if !preview && s3Client != nil {
-					err := Retry(ctx, func() error {
-						_, err := s3Client.PutBucketNotificationConfiguration(ctx, &s3.PutBucketNotificationConfigurationInput{
-							Bucket: aws.String(bucket),
-							NotificationConfiguration: &s3types.NotificationConfiguration{
-								LambdaFunctionConfigurations: confs,
-								EventBridgeConfiguration:     out.EventBridgeConfiguration,
-								QueueConfigurations:          out.QueueConfigurations,
-								TopicConfigurations:          out.TopicConfigurations,
-							},
-						})
-						return err
-					})
-					if err != nil {
-						Logger.Println("error:", err)
-						return nil, err
-					}
-				}
-				Logger.Printf(PreviewString(preview)+"updated bucket notifications for %s %s: %s => %s\n",
-					bucket, infraLambda.Name, existingEvents, events)
+			if _, ok := node[key].(map[string]any); ok {
+				err := fmt.Errorf("event trigger attr conflicts with another attr: %s", attr)
+				Logger.Println("error:", err)
+				return "", "", err
 			}
+			values, _ := node[key].([]string)
+			node[key] = append(values, v)
+		default:
+			err := fmt.Errorf("unknown event trigger attr: %s", attr)
+			Logger.Println("error:", err)
+			return "", "", err
 		}
 	}
-	buckets, err := S3Client().ListBuckets(ctx, &s3.ListBucketsInput{})
+	if pattern[lambdaTriggerEventAttrSource] == nil && pattern[lambdaTriggerEventAttrDetailType] == nil {
+		err := fmt.Errorf("event trigger needs source=VALUE or detail-type=VALUE: %v", trigger.Attr)
+		Logger.Println("error:", err)
+		return "", "", err
+	}
+	data, err := json.Marshal(pattern) // map keys are sorted, so equal patterns marshal identically
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", "", err
+	}
+	return bus, string(data), nil
+}
+
+// lambdaEventPatternAttrs is the inverse of lambdaEventPatternInput
+func lambdaEventPatternAttrs(bus, pattern string) ([]string, error) {
+	var val map[string]any
+	err := json.Unmarshal([]byte(pattern), &val)
 	if err != nil {
 		Logger.Println("error:", err)
 		return nil, err
 	}
-	for _, bucket := range buckets.Buckets {
-		out, err := S3ClientBucketRegionMust(*bucket.Name).GetBucketNotificationConfiguration(ctx, &s3.GetBucketNotificationConfigurationInput{
-			Bucket: bucket.Name,
-		})
-		if err != nil {
-			var noBucket *s3types.NoSuchBucket
-			if errors.As(err, &noBucket) {
-				continue // recently delete buckets can still show up in listbuckets but fail with 404
+	var attrs []string
+	var walk func(prefix string, val any) error
+	walk = func(prefix string, val any) error {
+		switch val := val.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(val))
+			for k := range val {
+				keys = append(keys, k)
 			}
-			Logger.Println("error:", err)
-			return nil, err
-		}
-		var confs []s3types.LambdaFunctionConfiguration
-		for _, conf := range out.LambdaFunctionConfigurations {
-			if *conf.LambdaFunctionArn != infraLambda.Arn || slices.Contains(triggers, *bucket.Name) {
-				confs = append(confs, conf)
-			} else {
-				Logger.Println(PreviewString(preview)+"deleted bucket notification:", infraLambda.Name, *bucket.Name)
+			slices.Sort(keys)
+			for _, k := range keys {
+				key := k
+				if prefix != "" {
+					key = prefix + "." + k
+				}
+				err := walk(key, val[k])
+				if err != nil {
+					return err
+				}
 			}
-		}
-		if len(confs) != len(out.LambdaFunctionConfigurations) && !preview {
-			_, err := S3ClientBucketRegionMust(*bucket.Name).PutBucketNotificationConfiguration(ctx, &s3.PutBucketNotificationConfigurationInput{
-				Bucket: bucket.Name,
-				NotificationConfiguration: &s3types.NotificationConfiguration{
-					LambdaFunctionConfigurations: confs,
-					EventBridgeConfiguration:     out.EventBridgeConfiguration,
-					QueueConfigurations:          out.QueueConfigurations,
-					TopicConfigurations:          out.TopicConfigurations,
-				},
-			})
-			if err != nil {
-				Logger.Println("error:", err)
-				return nil, err
+		case []any:
+			for _, v := range val {
+				s, ok := v.(string)
+				if !ok {
+					return fmt.Errorf("unsupported event pattern value: %s %v", prefix, v)
+				}
+				attrs = append(attrs, prefix+"="+s)
 			}
+		default:
+			return fmt.Errorf("unsupported event pattern value: %s %v", prefix, val)
 		}
+		return nil
 	}
-	return permissionSids, nil
+	for _, k := range []string{lambdaTriggerEventAttrSource, lambdaTriggerEventAttrDetailType, lambdaTriggerEventAttrDetail} {
+		if val[k] == nil {
+			continue
+		}
+		err := walk(k, val[k])
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		delete(val, k)
+	}
+	if len(val) > 0 {
+		err := fmt.Errorf("unsupported event pattern: %s", pattern)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if bus != lambdaTriggerEventBusDefault {
+		attrs = append(attrs, lambdaTriggerEventAttrBus+"="+bus)
+	}
+	return attrs, nil
 }
 
-func lambdaRemoveUnusedPermissions(ctx context.Context, name string, permissionSids []string, preview bool) error {
+const lambdaEventRuleNameMaxLen = 64 // eventbridge rule name limit
+
+// lambdaEventRulePrefix starts the names of every event rule of a lambda. long
+// names are truncated and made unique with a hash so rule names fit the limit.
+func lambdaEventRulePrefix(name string) string {
+	suffix := lambdaEventRuleNameSeparator + lambdaTriggerEventRulePrefix
+	maxLen := lambdaEventRuleNameMaxLen - 16 - len(suffix)
+	if len(name) > maxLen {
+		hash := sha256Hex([]byte(name))[:8]
+		name = name[:maxLen-len(hash)-1] + "_" + hash
+	}
+	return name + suffix
+}
+
+func lambdaEventRuleName(name, pattern string) string {
+	return lambdaEventRulePrefix(name) + sha256Hex([]byte(pattern))[:16]
+}
+
+func LambdaEnsureTriggerEvent(ctx context.Context, infraLambda *InfraLambda, preview bool) ([]string, error) {
 	if doDebug {
-		d := &Debug{start: time.Now(), name: "lambdaRemoveUnusedPermissions"}
+		d := &Debug{start: time.Now(), name: "LambdaEnsureTriggerEvent"}
 		d.Start()
 		defer d.End()
 	}
-	out, err := LambdaClient().GetPolicy(ctx, &lambda.GetPolicyInput{
-		FunctionName: aws.String(name),
-	})
-	if err != nil {
-		var notFound *lambdatypes.ResourceNotFoundException
-		if !errors.As(err, &notFound) {
+	var permissionSids []string
+	ruleNames := map[string][]string{}
+	for _, trigger := range infraLambda.Trigger {
+		if trigger.Type != lambdaTriggerEvent {
+			continue
+		}
+		bus, pattern, err := lambdaEventPatternInput(trigger)
+		if err != nil {
 			Logger.Println("error:", err)
-			return err
+			return nil, err
 		}
-		return nil
+		ruleName := lambdaEventRuleName(infraLambda.Name, pattern)
+		ruleNames[bus] = append(ruleNames[bus], ruleName)
+		ruleArn, err := EventsEnsureRule(ctx, infraLambda.infraSetName, bus, ruleName, pattern, infraLambda.Arn, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		sid, err := lambdaEnsurePermission(ctx, infraLambda.Name, "events.amazonaws.com", ruleArn, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		permissionSids = append(permissionSids, sid)
 	}
-	policy := IamPolicyDocument{}
-	err = json.Unmarshal([]byte(*out.Policy), &policy)
+	buses, err := EventsListBuses(ctx)
 	if err != nil {
 		Logger.Println("error:", err)
-		return err
+		return nil, err
 	}
-	for _, statement := range policy.Statement {
-		if !slices.Contains(permissionSids, statement.Sid) {
-			if !preview {
-				_, err := LambdaClient().RemovePermission(ctx, &lambda.RemovePermissionInput{
-					FunctionName: aws.String(name),
-					StatementId:  aws.String(statement.Sid),
-				})
-				if err != nil {
-					Logger.Println("error:", err)
-					return err
-				}
+	for _, bus := range buses {
+		rules, err := EventsListRules(ctx, bus.Name)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, rule := range rules {
+			if !strings.HasPrefix(*rule.Name, lambdaEventRulePrefix(infraLambda.Name)) || slices.Contains(ruleNames[*bus.Name], *rule.Name) {
+				continue
+			}
+			err := EventsDeleteRule(ctx, *bus.Name, *rule.Name, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
 			}
-			Logger.Println(PreviewString(preview)+"deleted unused lambda permissions:", name, statement.Sid)
 		}
 	}
-	return nil
+	return permissionSids, nil
 }
 
-func lambdaAddPermission(ctx context.Context, sid, name, callerPrincipal, callerArn string) error {
-	if doDebug {
-		d := &Debug{start: time.Now(), name: "lambdaAddPermission"}
-		d.Start()
-		defer d.End()
-	}
-	region := Region()
-	account, err := StsAccount(ctx)
-	if err != nil {
+const (
+	lambdaTriggerSNSAttrFilter      = "filter"
+	lambdaTriggerSNSAttrFilterScope = "filter-scope"
+
+	lambdaTriggerSNSFilterScopeDefault = "MessageAttributes"
+)
+
+type lambdaSNSTrigger struct {
+	topic       string
+	filter      string // json filter policy
+	filterScope string // MessageAttributes or MessageBody
+}
+
+// lambdaSNSTriggerInput parses an sns trigger like:
+//
+//	TOPIC_NAME
+//	filter={"type": ["order"]}
+//	filter-scope=MessageBody
+func lambdaSNSTriggerInput(trigger *InfraTrigger) (*lambdaSNSTrigger, error) {
+	if len(trigger.Attr) == 0 || strings.Contains(trigger.Attr[0], "=") {
+		err := fmt.Errorf("sns trigger first attr should be the topic name: %v", trigger.Attr)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	input := &lambdaSNSTrigger{
+		topic:       trigger.Attr[0],
+		filterScope: lambdaTriggerSNSFilterScopeDefault,
+	}
+	if strings.HasSuffix(input.topic, snsFifoSuffix) {
+		err := fmt.Errorf("lambdas cannot subscribe to fifo sns topics, use sqs: %s", input.topic)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	for _, attr := range trigger.Attr[1:] {
+		k, v, err := SplitOnce(attr, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case lambdaTriggerSNSAttrFilter:
+			var val map[string]any
+			err := json.Unmarshal([]byte(v), &val)
+			if err != nil {
+				err := fmt.Errorf("sns trigger filter should be a json object, got: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.filter = v
+		case lambdaTriggerSNSAttrFilterScope:
+			if v != "MessageAttributes" && v != "MessageBody" {
+				err := fmt.Errorf("sns trigger filter-scope should be MessageAttributes or MessageBody, got: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.filterScope = v
+		default:
+			err := fmt.Errorf("unknown sns trigger attr: %s", attr)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	if input.filterScope != lambdaTriggerSNSFilterScopeDefault && input.filter == "" {
+		err := fmt.Errorf("sns trigger filter-scope requires filter: %v", trigger.Attr)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return input, nil
+}
+
+func lambdaSNSTriggerAttrs(topic, filter, filterScope string) []string {
+	attrs := []string{topic}
+	if filter != "" {
+		attrs = append(attrs, lambdaTriggerSNSAttrFilter+"="+filter)
+		if filterScope != "" && filterScope != lambdaTriggerSNSFilterScopeDefault {
+			attrs = append(attrs, lambdaTriggerSNSAttrFilterScope+"="+filterScope)
+		}
+	}
+	return attrs
+}
+
+func lambdaSNSFilterEqual(a, b string) (bool, error) {
+	if a == "{}" {
+		a = "" // an empty policy is how filtering is removed
+	}
+	if b == "{}" {
+		b = ""
+	}
+	if a == "" || b == "" {
+		return a == b, nil
+	}
+	return iamPolicyEqual(a, b)
+}
+
+// lambdaPermissionSourceArns returns the source arns of a lambda's resource
+// policy statements for a principal
+func lambdaPermissionSourceArns(ctx context.Context, name, principal string) ([]string, error) {
+	out, err := LambdaClient().GetPolicy(ctx, &lambda.GetPolicyInput{
+		FunctionName: aws.String(name),
+	})
+	if err != nil {
+		var notFound *lambdatypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return lambdaPolicySourceArns(*out.Policy, principal)
+}
+
+func lambdaPolicySourceArns(policy, principal string) ([]string, error) {
+	var doc struct {
+		Statement []struct {
+			Principal any // a string like * or a map like {"Service": ...}
+			Condition map[string]map[string]any
+		}
+	}
+	err := json.Unmarshal([]byte(policy), &doc)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	var arns []string
+	for _, statement := range doc.Statement {
+		service, ok := statement.Principal.(map[string]any)
+		if !ok || service["Service"] != principal {
+			continue
+		}
+		arn, ok := statement.Condition["ArnLike"]["AWS:SourceArn"].(string)
+		if ok && !slices.Contains(arns, arn) {
+			arns = append(arns, arn)
+		}
+	}
+	return arns, nil
+}
+
+func LambdaEnsureTriggerSNS(ctx context.Context, infraLambda *InfraLambda, preview bool) ([]string, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "LambdaEnsureTriggerSNS"}
+		d.Start()
+		defer d.End()
+	}
+	var permissionSids []string
+	var topicArns []string
+	for _, trigger := range infraLambda.Trigger {
+		if trigger.Type != lambdaTriggerSNS {
+			continue
+		}
+		input, err := lambdaSNSTriggerInput(trigger)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		topicArn, err := SNSArn(ctx, input.topic)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if slices.Contains(topicArns, topicArn) {
+			err := fmt.Errorf("lambda can only have one sns trigger per topic: %s %s", infraLambda.Name, input.topic)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		topicArns = append(topicArns, topicArn)
+		sid, err := lambdaEnsurePermission(ctx, infraLambda.Name, "sns.amazonaws.com", topicArn, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		permissionSids = append(permissionSids, sid)
+		subscriptions, err := SNSListSubscriptions(ctx, topicArn)
+		if err != nil {
+			var nfe *snstypes.NotFoundException
+			if !preview || !errors.As(err, &nfe) {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		}
+		var subscriptionArn string
+		for _, sub := range subscriptions {
+			if *sub.Protocol == snsProtocolLambda && *sub.Endpoint == infraLambda.Arn {
+				subscriptionArn = *sub.SubscriptionArn
+				break
+			}
+		}
+		if subscriptionArn == "" {
+			if !preview {
+				attrs := map[string]string{}
+				if input.filter != "" {
+					attrs["FilterPolicy"] = input.filter
+					attrs["FilterPolicyScope"] = input.filterScope
+				}
+				_, err := SNSClient().Subscribe(ctx, &sns.SubscribeInput{
+					TopicArn:   aws.String(topicArn),
+					Protocol:   aws.String(snsProtocolLambda),
+					Endpoint:   aws.String(infraLambda.Arn),
+					Attributes: attrs,
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"created sns trigger:", infraLambda.Name, strings.Join(lambdaSNSTriggerAttrs(input.topic, input.filter, input.filterScope), " "))
+			continue
+		}
+		out, err := SNSClient().GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{
+			SubscriptionArn: aws.String(subscriptionArn),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		equal, err := lambdaSNSFilterEqual(out.Attributes["FilterPolicy"], input.filter)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		var updates [][2]string
+		if !equal {
+			filter := input.filter
+			if filter == "" {
+				filter = "{}" // an empty policy removes filtering
+			}
+			updates = append(updates, [2]string{"FilterPolicy", filter})
+		}
+		if input.filter != "" && out.Attributes["FilterPolicyScope"] != input.filterScope {
+			updates = append(updates, [2]string{"FilterPolicyScope", input.filterScope})
+		}
+		for _, update := range updates {
+			if !preview {
+				_, err := SNSClient().SetSubscriptionAttributes(ctx, &sns.SetSubscriptionAttributesInput{
+					SubscriptionArn: aws.String(subscriptionArn),
+					AttributeName:   aws.String(update[0]),
+					AttributeValue:  aws.String(update[1]),
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"updated sns trigger:", infraLambda.Name, input.topic, update[0]+"="+update[1])
+		}
+	}
+	// topics this lambda was previously subscribed to still have a permission in its policy
+	sourceArns, err := lambdaPermissionSourceArns(ctx, infraLambda.Name, "sns.amazonaws.com")
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	for _, topicArn := range sourceArns {
+		if slices.Contains(topicArns, topicArn) {
+			continue
+		}
+		subscriptions, err := SNSListSubscriptions(ctx, topicArn)
+		if err != nil {
+			var nfe *snstypes.NotFoundException
+			if errors.As(err, &nfe) {
+				continue
+			}
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, sub := range subscriptions {
+			if *sub.Protocol != snsProtocolLambda || *sub.Endpoint != infraLambda.Arn || *sub.SubscriptionArn == snsPendingConfirmation {
+				continue
+			}
+			if !preview {
+				_, err := SNSClient().Unsubscribe(ctx, &sns.UnsubscribeInput{
+					SubscriptionArn: sub.SubscriptionArn,
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"deleted sns trigger:", infraLambda.Name, SNSArnToName(topicArn))
+		}
+	}
+	return permissionSids, nil
+}
+
+func lambdaS3TriggerInput(lambdaArn string, trigger *InfraTrigger) (string, *s3Notification, error) {
+	if len(trigger.Attr) == 0 || strings.Contains(trigger.Attr[0], "=") {
+		err := fmt.Errorf("s3 trigger first attr should be the bucket name: %v", trigger.Attr)
+		Logger.Println("error:", err)
+		return "", nil, err
+	}
+	notification, err := s3NotificationInput(lambdaArn, trigger.Attr[1:])
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", nil, err
+	}
+	return trigger.Attr[0], notification, nil
+}
+
+// s3BucketNotifications converts every notification on a bucket, skipping those
+// which cannot be expressed as attrs
+func s3BucketNotifications(conf *s3.GetBucketNotificationConfigurationOutput) []*s3Notification {
+	var notifications []*s3Notification
+	add := func(n *s3Notification) {
+		if n != nil {
+			notifications = append(notifications, n)
+		}
+	}
+	for _, c := range conf.LambdaFunctionConfigurations {
+		add(s3NotificationFromConfig(aws.ToString(c.LambdaFunctionArn), c.Events, c.Filter))
+	}
+	for _, c := range conf.QueueConfigurations {
+		add(s3NotificationFromConfig(aws.ToString(c.QueueArn), c.Events, c.Filter))
+	}
+	for _, c := range conf.TopicConfigurations {
+		add(s3NotificationFromConfig(aws.ToString(c.TopicArn), c.Events, c.Filter))
+	}
+	return notifications
+}
+
+func LambdaEnsureTriggerS3(ctx context.Context, infraLambda *InfraLambda, preview bool) ([]string, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "LambdaEnsureTriggerS3"}
+		d.Start()
+		defer d.End()
+	}
+	var permissionSids []string
+	var triggers []string
+	notifications := map[string][]*s3Notification{}
+	for _, trigger := range infraLambda.Trigger {
+		if trigger.Type == lambdaTrigerS3 {
+			bucket, notification, err := lambdaS3TriggerInput(infraLambda.Arn, trigger)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if !slices.Contains(triggers, bucket) {
+				triggers = append(triggers, bucket)
+			}
+			notifications[bucket] = append(notifications[bucket], notification)
+		}
+	}
+	for _, bucket := range triggers {
+		sid, err := lambdaEnsurePermission(ctx, infraLambda.Name, "s3.amazonaws.com", "arn:aws:s3:::"+bucket, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		permissionSids = append(permissionSids, sid)
+		s3Client, err := S3ClientBucketRegion(bucket)
+		if err != nil {
+			var noBucket *s3types.NoSuchBucket
+			if !errors.As(err, &noBucket) && !preview {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			s3Client = nil
+		}
+		var out *s3.GetBucketNotificationConfigurationOutput
+		if s3Client != nil {
+			getOut, err := s3Client.GetBucketNotificationConfiguration(ctx, &s3.GetBucketNotificationConfigurationInput{
+				Bucket: aws.String(bucket),
+			})
+			if err != nil {
+				var noBucket *s3types.NoSuchBucket
+				if !errors.As(err, &noBucket) {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			} else {
+				out = getOut
+			}
+		}
+		if out == nil {
+			out = &s3.GetBucketNotificationConfigurationOutput{
+				LambdaFunctionConfigurations: []s3types.LambdaFunctionConfiguration{},
+			}
+		}
+		var existing []string
+		var confs []s3types.LambdaFunctionConfiguration
+		for _, conf := range out.LambdaFunctionConfigurations {
+			if *conf.LambdaFunctionArn != infraLambda.Arn {
+				confs = append(confs, conf)
+				continue
+			}
+			notification := s3NotificationFromConfig(infraLambda.Arn, conf.Events, conf.Filter)
+			if notification == nil {
+				existing = append(existing, fmt.Sprint(conf.Events)) // never equal to a wanted notification
+			} else {
+				existing = append(existing, strings.Join(notification.Attrs(), " "))
+			}
+		}
+		var wanted []string
+		for _, notification := range notifications[bucket] {
+			wanted = append(wanted, strings.Join(notification.Attrs(), " "))
+		}
+		slices.Sort(existing)
+		slices.Sort(wanted)
+		if slices.Equal(existing, wanted) {
+			continue
+		}
+		var others []*s3Notification
+		for _, notification := range s3BucketNotifications(out) {
+			if notification.target != infraLambda.Arn {
+				others = append(others, notification)
+			}
+		}
+		err = s3ValidateNotifications(bucket, append(others, notifications[bucket]...))
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, notification := range notifications[bucket] {
+			confs = append(confs, s3types.LambdaFunctionConfiguration{
+				LambdaFunctionArn: aws.String(infraLambda.Arn),
+				Events:            notification.Events(),
+				Filter:            notification.Filter(),
+			})
+		}
+		if !preview && s3Client != nil {
+			err := Retry(ctx, func() error {
+				_, err := s3Client.PutBucketNotificationConfiguration(ctx, &s3.PutBucketNotificationConfigurationInput{
+					Bucket: aws.String(bucket),
+					NotificationConfiguration: &s3types.NotificationConfiguration{
+						LambdaFunctionConfigurations: confs,
+						EventBridgeConfiguration:     out.EventBridgeConfiguration,
+						QueueConfigurations:          out.QueueConfigurations,
+						TopicConfigurations:          out.TopicConfigurations,
+					},
+				})
+				return err
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		}
+		Logger.Printf(PreviewString(preview)+"updated bucket notifications for %s %s: %q => %q\n", bucket, infraLambda.Name, existing, wanted)
+	}
+	buckets, err := S3Client().ListBuckets(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	for _, bucket := range buckets.Buckets {
+		out, err := S3ClientBucketRegionMust(*bucket.Name).GetBucketNotificationConfiguration(ctx, &s3.GetBucketNotificationConfigurationInput{
+			Bucket: bucket.Name,
+		})
+		if err != nil {
+			var noBucket *s3types.NoSuchBucket
+			if errors.As(err, &noBucket) {
+				continue // recently delete buckets can still show up in listbuckets but fail with 404
+			}
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		var confs []s3types.LambdaFunctionConfiguration
+		for _, conf := range out.LambdaFunctionConfigurations {
+			if *conf.LambdaFunctionArn != infraLambda.Arn || slices.Contains(triggers, *bucket.Name) {
+				confs = append(confs, conf)
+			} else {
+				Logger.Println(PreviewString(preview)+"deleted bucket notification:", infraLambda.Name, *bucket.Name)
+			}
+		}
+		if len(confs) != len(out.LambdaFunctionConfigurations) && !preview {
+			_, err := S3ClientBucketRegionMust(*bucket.Name).PutBucketNotificationConfiguration(ctx, &s3.PutBucketNotificationConfigurationInput{
+				Bucket: bucket.Name,
+				NotificationConfiguration: &s3types.NotificationConfiguration{
+					LambdaFunctionConfigurations: confs,
+					EventBridgeConfiguration:     out.EventBridgeConfiguration,
+					QueueConfigurations:          out.QueueConfigurations,
+					TopicConfigurations:          out.TopicConfigurations,
+				},
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		}
+	}
+	return permissionSids, nil
+}
+
+func lambdaRemoveUnusedPermissions(ctx context.Context, name string, permissionSids []string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "lambdaRemoveUnusedPermissions"}
+		d.Start()
+		defer d.End()
+	}
+	out, err := LambdaClient().GetPolicy(ctx, &lambda.GetPolicyInput{
+		FunctionName: aws.String(name),
+	})
+	if err != nil {
+		var notFound *lambdatypes.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			Logger.Println("error:", err)
+			return err
+		}
+		return nil
+	}
+	policy := IamPolicyDocument{}
+	err = json.Unmarshal([]byte(*out.Policy), &policy)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, statement := range policy.Statement {
+		if !slices.Contains(permissionSids, statement.Sid) {
+			if !preview {
+				_, err := LambdaClient().RemovePermission(ctx, &lambda.RemovePermissionInput{
+					FunctionName: aws.String(name),
+					StatementId:  aws.String(statement.Sid),
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"deleted unused lambda permissions:", name, statement.Sid)
+		}
+	}
+	return nil
+}
+
+func lambdaAddPermission(ctx context.Context, sid, name, callerPrincipal, callerArn string) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "lambdaAddPermission"}
+		d.Start()
+		defer d.End()
+	}
+	region := Region()
+	account, err := StsAccount(ctx)
+	if err != nil {
 		Logger.Println("error:", err)
 		return err
 	}
@@ -825,90 +1608,470 @@ func lambdaEnsurePermission(ctx context.Context, name, callerPrincipal, callerAr
 		err := json.Unmarshal([]byte(policyString), &policy)
 		if err != nil {
 			Logger.Println("error:", err)
-			return "", err
+			return "", err
+		}
+		for _, statement := range policy.Statement {
+			if statement.Sid == sid {
+				needsUpdate = false
+				break
+			}
+		}
+	}
+	if needsUpdate {
+		if !preview {
+			err := lambdaAddPermission(ctx, sid, name, callerPrincipal, callerArn)
+			if err != nil {
+				Logger.Println("error:", err)
+				return "", err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"updated lambda permission:", name, callerPrincipal, callerArn)
+		return sid, nil
+	}
+	return sid, nil
+}
+
+func LambdaArnToLambdaName(arn string) string {
+	// "arn:aws:lambda:%s:%s:function:%s"
+	name := Last(strings.Split(arn, ":"))
+	return name
+}
+
+func lambdaEnsureTriggerApi(ctx context.Context, infraSetName, apiName, arnLambda string, protocolType apitypes.ProtocolType, preview bool) (*apitypes.Api, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "lambdaEnsureTriggerApi"}
+		d.Start()
+		defer d.End()
+	}
+	var pType apitypes.ProtocolType
+	if !slices.Contains(pType.Values(), protocolType) {
+		err := fmt.Errorf("invalid protocol type: %s", protocolType)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	api, err := Api(ctx, apiName)
+	if err != nil && err.Error() != ErrApiNotFound {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if api == nil {
+		if !preview {
+			input := &apigatewayv2.CreateApiInput{
+				Name:         aws.String(apiName),
+				ProtocolType: apitypes.ProtocolType(protocolType),
+				Tags: map[string]string{
+					infraSetTagName: infraSetName,
+				},
+			}
+			if protocolType == apitypes.ProtocolTypeWebsocket {
+				input.RouteKey = aws.String(lambdaDollarDefault)
+				input.Target = aws.String(arnLambda)
+				input.RouteSelectionExpression = aws.String(lambdaRouteSelection)
+			}
+			_, err := ApiClient().CreateApi(ctx, input)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			api, err := Api(ctx, apiName)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			return api, nil
+		}
+		Logger.Println(PreviewString(preview)+"created api:", apiName)
+		return nil, nil
+	}
+	if api.ProtocolType != apitypes.ProtocolType(protocolType) {
+		err := fmt.Errorf("api protocol type misconfigured for %s %s: %v != %v", apiName, *api.ApiId, api.ProtocolType, protocolType)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return api, nil
+}
+
+func lambdaApiGetIntegrations(ctx context.Context, name string, api *apitypes.Api) ([]apitypes.Integration, error) {
+	out, err := ApiClient().GetIntegrations(ctx, &apigatewayv2.GetIntegrationsInput{
+		ApiId:      api.ApiId,
+		MaxResults: aws.String(fmt.Sprint(500)),
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if len(out.Items) == 500 {
+		err := fmt.Errorf("too many integrations for %s %s", name, *api.ApiId)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+func lambdaApiGetRoutes(ctx context.Context, name string, api *apitypes.Api) ([]apitypes.Route, error) {
+	out, err := ApiClient().GetRoutes(ctx, &apigatewayv2.GetRoutesInput{
+		ApiId:      api.ApiId,
+		MaxResults: aws.String(fmt.Sprint(500)),
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if len(out.Items) == 500 {
+		err := fmt.Errorf("api has 500 or more routes: %s %s", name, *api.ApiId)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+// lambdaEnsureTriggerApiIntegration creates the integration when it is nil,
+// otherwise validates it, and returns its id
+func lambdaEnsureTriggerApiIntegration(ctx context.Context, name, arnLambda string, api *apitypes.Api, integration *apitypes.Integration, timeoutMillis int32, preview bool) (string, error) {
+	if integration == nil {
+		var integrationId string
+		if !preview {
+			out, err := ApiClient().CreateIntegration(ctx, &apigatewayv2.CreateIntegrationInput{
+				ApiId:                api.ApiId,
+				IntegrationUri:       aws.String(arnLambda),
+				ConnectionType:       apitypes.ConnectionTypeInternet,
+				IntegrationType:      apitypes.IntegrationTypeAwsProxy,
+				IntegrationMethod:    aws.String(lambdaIntegrationMethod),
+				TimeoutInMillis:      aws.Int32(timeoutMillis),
+				PayloadFormatVersion: aws.String(lambdaPayloadVersion),
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return "", err
+			}
+			integrationId = *out.IntegrationId
+		}
+		Logger.Println(PreviewString(preview)+"created api integration:", name, LambdaArnToLambdaName(arnLambda))
+		return integrationId, nil
+	}
+	if integration.ConnectionType != apitypes.ConnectionTypeInternet {
+		err := fmt.Errorf("api connection type misconfigured for %s %s: %s != %s", name, *api.ApiId, integration.ConnectionType, apitypes.ConnectionTypeInternet)
+		Logger.Println("error:", err)
+		return "", err
+	}
+	if integration.IntegrationType != apitypes.IntegrationTypeAwsProxy {
+		err := fmt.Errorf("api integration type misconfigured for %s %s: %s != %s", name, *api.ApiId, integration.IntegrationType, apitypes.IntegrationTypeAwsProxy)
+		Logger.Println("error:", err)
+		return "", err
+	}
+	if *integration.IntegrationMethod != lambdaIntegrationMethod {
+		err := fmt.Errorf("api integration method misconfigured for %s %s: %s != %s", name, *api.ApiId, *integration.IntegrationMethod, lambdaIntegrationMethod)
+		Logger.Println("error:", err)
+		return "", err
+	}
+	if *integration.TimeoutInMillis != timeoutMillis {
+		err := fmt.Errorf("api timeout misconfigured for %s %s: %d != %d", name, *api.ApiId, *integration.TimeoutInMillis, timeoutMillis)
+		Logger.Println("error:", err)
+		return "", err
+	}
+	if *integration.PayloadFormatVersion != lambdaPayloadVersion {
+		err := fmt.Errorf("api payload format version misconfigured for %s %s: %s != %s", name, *api.ApiId, *integration.PayloadFormatVersion, lambdaPayloadVersion)
+		Logger.Println("error:", err)
+		return "", err
+	}
+	return *integration.IntegrationId, nil
+}
+
+type lambdaApiStage struct {
+	throttleRate      *float64
+	throttleBurst     *int32
+	accessLogs        bool
+	accessLogsTTLDays int
+	detailedMetrics   bool
+}
+
+// lambdaApiStageInput parses the stage attrs of an api or websocket trigger
+func lambdaApiStageInput(trigger *InfraTrigger) (*lambdaApiStage, error) {
+	stage := &lambdaApiStage{accessLogsTTLDays: lambdaApiAccessLogsTTLDaysDefault}
+	hasTTL := false
+	for _, line := range trigger.Attr {
+		k, v, err := SplitOnce(line, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case lambdaTriggerApiAttrThrottleRate:
+			val, err := strconv.ParseFloat(v, 64)
+			if err != nil || val < 0 {
+				err := fmt.Errorf("throttle-rate should be a non-negative number: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			stage.throttleRate = aws.Float64(val)
+		case lambdaTriggerApiAttrThrottleBurst:
+			if !IsDigit(v) || Atoi(v) < 0 {
+				err := fmt.Errorf("throttle-burst should be digits: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			stage.throttleBurst = aws.Int32(int32(Atoi(v)))
+		case lambdaTriggerApiAttrAccessLogs:
+			val, err := strconv.ParseBool(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			stage.accessLogs = val
+		case lambdaTriggerApiAttrAccessLogsTTLDays:
+			if !IsDigit(v) {
+				err := fmt.Errorf("access-logs-ttl-days should be digits: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			hasTTL = true
+			stage.accessLogsTTLDays = Atoi(v)
+		case lambdaTriggerApiAttrDetailedMetrics:
+			val, err := strconv.ParseBool(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			stage.detailedMetrics = val
+		}
+	}
+	if hasTTL && !stage.accessLogs {
+		err := fmt.Errorf("access-logs-ttl-days requires access-logs=true: %v", trigger.Attr)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	// websocket access logs need the account level api gateway cloudwatch
+	// role, which is not managed here, so fail now instead of at apply time
+	if stage.accessLogs && trigger.Type == lambdaTriggerWebsocket {
+		err := fmt.Errorf("access-logs is not supported for websocket triggers, since it needs the account level api gateway cloudwatch role: %v", trigger.Attr)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return stage, nil
+}
+
+func lambdaApiAccessLogGroup(apiName string) string {
+	return "/aws/apigateway/" + apiName
+}
+
+func lambdaApiAccessLogFormat(protocolType apitypes.ProtocolType) string {
+	fields := []string{
+		`"requestId":"$context.requestId"`,
+		`"ip":"$context.identity.sourceIp"`,
+		`"requestTime":"$context.requestTime"`,
+		`"routeKey":"$context.routeKey"`,
+		`"status":"$context.status"`,
+		`"integrationError":"$context.integrationErrorMessage"`,
+	}
+	if protocolType == apitypes.ProtocolTypeWebsocket {
+		fields = append(fields, `"eventType":"$context.eventType"`, `"connectionId":"$context.connectionId"`)
+	} else {
+		fields = append(fields, `"httpMethod":"$context.httpMethod"`, `"path":"$context.path"`, `"responseLength":"$context.responseLength"`, `"latency":"$context.responseLatency"`)
+	}
+	return "{" + strings.Join(fields, ",") + "}"
+}
+
+// api gateway account level throttling, which a stage uses when it has no
+// throttle attrs. unset stage throttling cannot be restored once set, so
+// these values are sent instead and are not shown as attrs.
+const (
+	lambdaApiThrottleRateDefault  = 10000.0
+	lambdaApiThrottleBurstDefault = 5000
+)
+
+// lambdaApiStageAttrs flattens stage settings to trigger attrs for diffing and infra-ls
+func lambdaApiStageAttrs(routeSettings *apitypes.RouteSettings, accessLogSettings *apitypes.AccessLogSettings) []string {
+	var attrs []string
+	if routeSettings != nil {
+		if routeSettings.ThrottlingRateLimit != nil && *routeSettings.ThrottlingRateLimit != lambdaApiThrottleRateDefault {
+			attrs = append(attrs, lambdaTriggerApiAttrThrottleRate+"="+strconv.FormatFloat(*routeSettings.ThrottlingRateLimit, 'f', -1, 64))
+		}
+		if routeSettings.ThrottlingBurstLimit != nil && *routeSettings.ThrottlingBurstLimit != lambdaApiThrottleBurstDefault {
+			attrs = append(attrs, fmt.Sprintf("%s=%d", lambdaTriggerApiAttrThrottleBurst, *routeSettings.ThrottlingBurstLimit))
+		}
+	}
+	if accessLogSettings != nil && aws.ToString(accessLogSettings.DestinationArn) != "" {
+		attrs = append(attrs, lambdaTriggerApiAttrAccessLogs+"=true")
+	}
+	if routeSettings != nil && routeSettings.DetailedMetricsEnabled != nil && *routeSettings.DetailedMetricsEnabled {
+		attrs = append(attrs, lambdaTriggerApiAttrDetailedMetrics+"=true")
+	}
+	return attrs
+}
+
+func lambdaEnsureTriggerApiStage(ctx context.Context, name, account string, api *apitypes.Api, stage *lambdaApiStage, preview bool) error {
+	routeSettings := &apitypes.RouteSettings{
+		ThrottlingRateLimit:    aws.Float64(lambdaApiThrottleRateDefault),
+		ThrottlingBurstLimit:   aws.Int32(lambdaApiThrottleBurstDefault),
+		DetailedMetricsEnabled: aws.Bool(stage.detailedMetrics),
+	}
+	if stage.throttleRate != nil {
+		routeSettings.ThrottlingRateLimit = stage.throttleRate
+	}
+	if stage.throttleBurst != nil {
+		routeSettings.ThrottlingBurstLimit = stage.throttleBurst
+	}
+	var accessLogSettings *apitypes.AccessLogSettings
+	if stage.accessLogs {
+		accessLogSettings = &apitypes.AccessLogSettings{
+			DestinationArn: aws.String(fmt.Sprintf("arn:aws:logs:%s:%s:log-group:%s", Region(), account, lambdaApiAccessLogGroup(name))),
+			Format:         aws.String(lambdaApiAccessLogFormat(api.ProtocolType)),
+		}
+	}
+	getStageOut, err := ApiClient().GetStage(ctx, &apigatewayv2.GetStageInput{
+		ApiId:     api.ApiId,
+		StageName: aws.String(lambdaDollarDefault),
+	})
+	if err != nil {
+		var nfe *apitypes.NotFoundException
+		if !errors.As(err, &nfe) {
+			Logger.Println("error:", err)
+			return err
 		}
-		for _, statement := range policy.Statement {
-			if statement.Sid == sid {
-				needsUpdate = false
-				break
+		if !preview {
+			_, err := ApiClient().CreateStage(ctx, &apigatewayv2.CreateStageInput{
+				ApiId:                api.ApiId,
+				AutoDeploy:           aws.Bool(true),
+				StageName:            aws.String(lambdaDollarDefault),
+				DefaultRouteSettings: routeSettings,
+				AccessLogSettings:    accessLogSettings,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
 			}
 		}
+		Logger.Println(PreviewString(preview)+"created api stage:", name, strings.Join(lambdaApiStageAttrs(routeSettings, accessLogSettings), " "))
+		return nil
 	}
-	if needsUpdate {
+	if *getStageOut.StageName != lambdaDollarDefault {
+		err := fmt.Errorf("api stage name misconfigured for %s %s: %s != %s", name, *api.ApiId, *getStageOut.StageName, lambdaDollarDefault)
+		Logger.Println("error:", err)
+		return err
+	}
+	if !*getStageOut.AutoDeploy {
+		err := fmt.Errorf("api stage auto deploy misconfigured for %s %s, should be enabled", name, *api.ApiId)
+		Logger.Println("error:", err)
+		return err
+	}
+	existingAttrs := lambdaApiStageAttrs(getStageOut.DefaultRouteSettings, getStageOut.AccessLogSettings)
+	newAttrs := lambdaApiStageAttrs(routeSettings, accessLogSettings)
+	formatChanged := accessLogSettings != nil && getStageOut.AccessLogSettings != nil && aws.ToString(getStageOut.AccessLogSettings.Format) != *accessLogSettings.Format
+	if !reflect.DeepEqual(existingAttrs, newAttrs) || formatChanged {
 		if !preview {
-			err := lambdaAddPermission(ctx, sid, name, callerPrincipal, callerArn)
+			if accessLogSettings == nil && getStageOut.AccessLogSettings != nil && aws.ToString(getStageOut.AccessLogSettings.DestinationArn) != "" {
+				_, err := ApiClient().DeleteAccessLogSettings(ctx, &apigatewayv2.DeleteAccessLogSettingsInput{
+					ApiId:     api.ApiId,
+					StageName: aws.String(lambdaDollarDefault),
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			_, err := ApiClient().UpdateStage(ctx, &apigatewayv2.UpdateStageInput{
+				ApiId:                api.ApiId,
+				StageName:            aws.String(lambdaDollarDefault),
+				DefaultRouteSettings: routeSettings,
+				AccessLogSettings:    accessLogSettings,
+			})
 			if err != nil {
 				Logger.Println("error:", err)
-				return "", err
+				return err
 			}
 		}
-		Logger.Println(PreviewString(preview)+"updated lambda permission:", name, callerPrincipal, callerArn)
-		return sid, nil
+		Logger.Println(PreviewString(preview)+"updated api stage:", name, strings.Join(existingAttrs, " "), "=>", strings.Join(newAttrs, " "))
 	}
-	return sid, nil
-}
-
-func LambdaArnToLambdaName(arn string) string {
-	// "arn:aws:lambda:%s:%s:function:%s"
-	name := Last(strings.Split(arn, ":"))
-	return name
+	return nil
 }
 
-func lambdaEnsureTriggerApi(ctx context.Context, infraSetName, apiName, arnLambda string, protocolType apitypes.ProtocolType, preview bool) (*apitypes.Api, error) {
-	if doDebug {
-		d := &Debug{start: time.Now(), name: "lambdaEnsureTriggerApi"}
-		d.Start()
-		defer d.End()
+// lambdaApiRouteAuthUpdate returns the update to apply the authorization to a
+// route, or nil if it already matches. a nil authorizerId is sent as an empty
+// string, since the sdk omits nil fields and the old authorizer would remain.
+func lambdaApiRouteAuthUpdate(route apitypes.Route, authorizationType apitypes.AuthorizationType, authorizerId *string) *apigatewayv2.UpdateRouteInput {
+	if route.AuthorizationType == authorizationType && aws.ToString(route.AuthorizerId) == aws.ToString(authorizerId) {
+		return nil
 	}
-	var pType apitypes.ProtocolType
-	if !slices.Contains(pType.Values(), protocolType) {
-		err := fmt.Errorf("invalid protocol type: %s", protocolType)
-		Logger.Println("error:", err)
-		return nil, err
+	return &apigatewayv2.UpdateRouteInput{
+		RouteId:           route.RouteId,
+		AuthorizationType: authorizationType,
+		AuthorizerId:      aws.String(aws.ToString(authorizerId)),
 	}
-	api, err := Api(ctx, apiName)
-	if err != nil && err.Error() != ErrApiNotFound {
-		Logger.Println("error:", err)
-		return nil, err
+}
+
+// lambdaEnsureTriggerApiRoute ensures routeKey targets the integration, given
+// the existing routes of the api
+func lambdaEnsureTriggerApiRoute(ctx context.Context, name, routeKey, integrationId string, api *apitypes.Api, existingRoutes []apitypes.Route, authorizationType apitypes.AuthorizationType, authorizerId *string, preview bool) error {
+	var routes []apitypes.Route
+	for _, route := range existingRoutes {
+		if *route.RouteKey == routeKey {
+			routes = append(routes, route)
+		}
 	}
-	if api == nil {
+	target := fmt.Sprintf("integrations/%s", integrationId)
+	switch len(routes) {
+	case 0:
 		if !preview {
-			input := &apigatewayv2.CreateApiInput{
-				Name:         aws.String(apiName),
-				ProtocolType: apitypes.ProtocolType(protocolType),
-				Tags: map[string]string{
-					infraSetTagName: infraSetName,
-				},
-			}
-			if protocolType == apitypes.ProtocolTypeWebsocket {
-				input.RouteKey = aws.String(lambdaDollarDefault)
-				input.Target = aws.String(arnLambda)
-				input.RouteSelectionExpression = aws.String(lambdaRouteSelection)
-			}
-			_, err := ApiClient().CreateApi(ctx, input)
+			_, err := ApiClient().CreateRoute(ctx, &apigatewayv2.CreateRouteInput{
+				ApiId:             api.ApiId,
+				Target:            aws.String(target),
+				RouteKey:          aws.String(routeKey),
+				AuthorizationType: authorizationType,
+				AuthorizerId:      authorizerId,
+				ApiKeyRequired:    aws.Bool(false),
+			})
 			if err != nil {
 				Logger.Println("error:", err)
-				return nil, err
+				return err
 			}
-			api, err := Api(ctx, apiName)
-			if err != nil {
-				Logger.Println("error:", err)
-				return nil, err
+		}
+		Logger.Println(PreviewString(preview)+"created api route:", name, routeKey)
+	case 1:
+		route := routes[0]
+		if aws.ToString(route.Target) != target {
+			if integrationId == "" && preview { // integration will be created
+				Logger.Println(PreviewString(preview)+"updated api route target:", name, routeKey)
+				return nil
 			}
-			return api, nil
+			if !preview {
+				_, err := ApiClient().UpdateRoute(ctx, &apigatewayv2.UpdateRouteInput{
+					ApiId:   api.ApiId,
+					RouteId: route.RouteId,
+					Target:  aws.String(target),
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"updated api route target:", name, routeKey, aws.ToString(route.Target), "=>", target)
 		}
-		Logger.Println(PreviewString(preview)+"created api:", apiName)
-		return nil, nil
-	}
-	if api.ProtocolType != apitypes.ProtocolType(protocolType) {
-		err := fmt.Errorf("api protocol type misconfigured for %s %s: %v != %v", apiName, *api.ApiId, api.ProtocolType, protocolType)
+		update := lambdaApiRouteAuthUpdate(route, authorizationType, authorizerId)
+		if update != nil {
+			if !preview {
+				update.ApiId = api.ApiId
+				_, err := ApiClient().UpdateRoute(ctx, update)
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"updated api route authorization:", name, routeKey, route.AuthorizationType, "=>", authorizationType)
+		}
+		if *route.ApiKeyRequired {
+			err := fmt.Errorf("api route apiKeyRequired misconfigured for %s %s, should be disabled", name, *api.ApiId)
+			Logger.Println("error:", err)
+			return err
+		}
+	default:
+		err := fmt.Errorf("api has more than one route: %s %s %v", name, routeKey, Pformat(routes))
 		Logger.Println("error:", err)
-		return nil, err
+		return err
 	}
-	return api, nil
+	return nil
 }
 
-func lambdaEnsureTriggerApiIntegrationStageRoute(ctx context.Context, name, arnLambda string, protocolType apitypes.ProtocolType, api *apitypes.Api, timeoutMillis int32, preview bool) (string, error) {
+func lambdaEnsureTriggerApiIntegrationStageRoute(ctx context.Context, name, arnLambda string, protocolType apitypes.ProtocolType, api *apitypes.Api, timeoutMillis int32, auth *lambdaApiAuth, stage *lambdaApiStage, preview bool) (string, error) {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "lambdaEnsureTriggerApiIntegrationStageRoute"}
 		d.Start()
@@ -925,187 +2088,390 @@ func lambdaEnsureTriggerApiIntegrationStageRoute(ctx context.Context, name, arnL
 		Logger.Println("error:", err)
 		return "", err
 	}
-	var integrationId string
-	var getIntegrationsOut *apigatewayv2.GetIntegrationsOutput
-	if !(preview && api == nil) {
-		getIntegrationsOut, err = ApiClient().GetIntegrations(ctx, &apigatewayv2.GetIntegrationsInput{
-			ApiId:      api.ApiId,
-			MaxResults: aws.String(fmt.Sprint(500)),
-		})
+	integrations, err := lambdaApiGetIntegrations(ctx, name, api)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	var integration *apitypes.Integration
+	switch len(integrations) {
+	case 0:
+	case 1:
+		integration = &integrations[0]
+	default:
+		err := fmt.Errorf("api has more than one integration: %s %v", name, Pformat(integrations))
+		Logger.Println("error:", err)
+		return "", err
+	}
+	integrationId, err := lambdaEnsureTriggerApiIntegration(ctx, name, arnLambda, api, integration, timeoutMillis, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	err = lambdaEnsureTriggerApiStage(ctx, name, account, api, stage, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	authorizerId, err := lambdaEnsureTriggerApiAuthorizer(ctx, name, account, api, auth, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	routes, err := lambdaApiGetRoutes(ctx, name, api)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	var routeKeys []string
+	if protocolType == apitypes.ProtocolTypeHttp {
+		routeKeys = []string{lambdaDollarDefault}
+	} else if protocolType == apitypes.ProtocolTypeWebsocket {
+		routeKeys = []string{lambdaDollarDefault, lambdaDollarConnect, lambdaDollarDisconnect}
+	}
+	for _, routeKey := range routeKeys {
+		authorizationType := apitypes.AuthorizationType(lambdaAuthorizationType)
+		var routeAuthorizerId *string
+		if auth != nil && (protocolType == apitypes.ProtocolTypeHttp || routeKey == lambdaDollarConnect) { // websockets only authorize on connect
+			authorizationType = auth.authorizationType()
+			if authorizerId != "" {
+				routeAuthorizerId = aws.String(authorizerId)
+			}
+		}
+		err := lambdaEnsureTriggerApiRoute(ctx, name, routeKey, integrationId, api, routes, authorizationType, routeAuthorizerId, preview)
 		if err != nil {
 			Logger.Println("error:", err)
 			return "", err
 		}
-		if len(getIntegrationsOut.Items) == 500 {
-			err := fmt.Errorf("too many integrations for %s %s", name, *api.ApiId)
+	}
+	if !(preview && auth != nil && authorizerId == "") { // in preview a new authorizer has no id yet
+		err = lambdaRemoveUnusedApiAuthorizers(ctx, name, api, authorizerId, preview)
+		if err != nil {
 			Logger.Println("error:", err)
 			return "", err
 		}
 	}
-	switch len(getIntegrationsOut.Items) {
-	case 0:
-		if !preview {
-			out, err := ApiClient().CreateIntegration(ctx, &apigatewayv2.CreateIntegrationInput{
-				ApiId:                api.ApiId,
-				IntegrationUri:       aws.String(arnLambda),
-				ConnectionType:       apitypes.ConnectionTypeInternet,
-				IntegrationType:      apitypes.IntegrationTypeAwsProxy,
-				IntegrationMethod:    aws.String(lambdaIntegrationMethod),
-				TimeoutInMillis:      aws.Int32(timeoutMillis),
-				PayloadFormatVersion: aws.String(lambdaPayloadVersion),
-			})
-			if err != nil {
+	lambdaName := Last(strings.Split(arnLambda, ":"))
+	sid, err := lambdaEnsurePermission(ctx, lambdaName, "apigateway.amazonaws.com", apiPermissionArn(account, *api.ApiId), preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	return sid, nil
+}
+
+type lambdaApiAuth struct {
+	authorizerType apitypes.AuthorizerType
+	issuer         string
+	audience       []string
+	lambdaName     string
+	identity       []string
+	ttl            int32
+}
+
+func (a *lambdaApiAuth) authorizationType() apitypes.AuthorizationType {
+	if a.authorizerType == apitypes.AuthorizerTypeJwt {
+		return apitypes.AuthorizationTypeJwt
+	}
+	return apitypes.AuthorizationTypeCustom
+}
+
+// lambdaApiAuthInput parses the authorizer attrs of an api or websocket
+// trigger, returning nil when the api is open
+func lambdaApiAuthInput(trigger *InfraTrigger) (*lambdaApiAuth, error) {
+	auth := &lambdaApiAuth{}
+	hasTTL := false
+	for _, line := range trigger.Attr {
+		k, v, err := SplitOnce(line, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case lambdaTriggerApiAttrJwtIssuer:
+			auth.issuer = v
+		case lambdaTriggerApiAttrJwtAudience:
+			auth.audience = append(auth.audience, v)
+		case lambdaTriggerApiAttrAuthorizer:
+			auth.lambdaName = v
+		case lambdaTriggerApiAttrAuthorizerIdentity:
+			auth.identity = append(auth.identity, v)
+		case lambdaTriggerApiAttrAuthorizerTTL:
+			if !IsDigit(v) {
+				err := fmt.Errorf("authorizer-ttl should be digits: %s", v)
 				Logger.Println("error:", err)
-				return "", err
+				return nil, err
 			}
-			integrationId = *out.IntegrationId
+			hasTTL = true
+			auth.ttl = int32(Atoi(v))
 		}
-		Logger.Println(PreviewString(preview)+"created api integration:", name)
-	case 1:
-		integration := getIntegrationsOut.Items[0]
-		integrationId = *integration.IntegrationId
-		if integration.ConnectionType != apitypes.ConnectionTypeInternet {
-			err := fmt.Errorf("api connection type misconfigured for %s %s: %s != %s", name, *api.ApiId, integration.ConnectionType, apitypes.ConnectionTypeInternet)
+	}
+	isJwt := auth.issuer != "" || len(auth.audience) > 0
+	switch {
+	case isJwt && auth.lambdaName != "":
+		err := fmt.Errorf("api trigger cannot have both a jwt and a lambda authorizer: %v", trigger.Attr)
+		Logger.Println("error:", err)
+		return nil, err
+	case isJwt:
+		if trigger.Type != lambdaTriggerApi {
+			err := fmt.Errorf("jwt authorizers are only supported for api triggers: %v", trigger.Attr)
 			Logger.Println("error:", err)
-			return "", err
+			return nil, err
 		}
-		if integration.IntegrationType != apitypes.IntegrationTypeAwsProxy {
-			err := fmt.Errorf("api integration type misconfigured for %s %s: %s != %s", name, *api.ApiId, integration.IntegrationType, apitypes.IntegrationTypeAwsProxy)
+		if auth.issuer == "" || len(auth.audience) == 0 {
+			err := fmt.Errorf("jwt authorizer requires jwt-issuer and jwt-audience: %v", trigger.Attr)
 			Logger.Println("error:", err)
-			return "", err
+			return nil, err
 		}
-		if *integration.IntegrationMethod != lambdaIntegrationMethod {
-			err := fmt.Errorf("api integration method misconfigured for %s %s: %s != %s", name, *api.ApiId, *integration.IntegrationMethod, lambdaIntegrationMethod)
+		if hasTTL {
+			err := fmt.Errorf("authorizer-ttl is only valid for lambda authorizers: %v", trigger.Attr)
 			Logger.Println("error:", err)
-			return "", err
+			return nil, err
 		}
-		if *integration.TimeoutInMillis != timeoutMillis {
-			err := fmt.Errorf("api timeout misconfigured for %s %s: %d != %d", name, *api.ApiId, *integration.TimeoutInMillis, timeoutMillis)
+		auth.authorizerType = apitypes.AuthorizerTypeJwt
+	case auth.lambdaName != "":
+		if trigger.Type == lambdaTriggerWebsocket && hasTTL {
+			err := fmt.Errorf("authorizer-ttl is not supported for websocket triggers: %v", trigger.Attr)
 			Logger.Println("error:", err)
-			return "", err
+			return nil, err
 		}
-		if *integration.PayloadFormatVersion != lambdaPayloadVersion {
-			err := fmt.Errorf("api payload format version misconfigured for %s %s: %s != %s", name, *api.ApiId, *integration.PayloadFormatVersion, lambdaPayloadVersion)
+		auth.authorizerType = apitypes.AuthorizerTypeRequest
+	default:
+		if len(auth.identity) > 0 || hasTTL {
+			err := fmt.Errorf("authorizer-identity and authorizer-ttl require jwt-issuer or authorizer: %v", trigger.Attr)
 			Logger.Println("error:", err)
-			return "", err
+			return nil, err
 		}
-	default:
-		err := fmt.Errorf("api has more than one integration: %s %v", name, Pformat(getIntegrationsOut.Items))
-		Logger.Println("error:", err)
-		return "", err
+		return nil, nil
 	}
-	getStageOut, err := ApiClient().GetStage(ctx, &apigatewayv2.GetStageInput{
-		ApiId:     api.ApiId,
-		StageName: aws.String(lambdaDollarDefault),
-	})
-	if err != nil {
-		var nfe *apitypes.NotFoundException
-		if !errors.As(err, &nfe) {
-			Logger.Println("error:", err)
-			return "", err
+	if len(auth.identity) == 0 {
+		if trigger.Type == lambdaTriggerWebsocket {
+			auth.identity = []string{"route.request.header.Authorization"}
+		} else {
+			auth.identity = []string{"$request.header.Authorization"}
 		}
-		if !preview {
-			_, err := ApiClient().CreateStage(ctx, &apigatewayv2.CreateStageInput{
-				ApiId:      api.ApiId,
-				AutoDeploy: aws.Bool(true),
-				StageName:  aws.String(lambdaDollarDefault),
-			})
-			if err != nil {
-				Logger.Println("error:", err)
-				return "", err
-			}
+	}
+	return auth, nil
+}
+
+// lambdaApiAuthAttrs is the inverse of lambdaApiAuthInput, used by infra-ls
+func lambdaApiAuthAttrs(authorizer apitypes.Authorizer) []string {
+	var attrs []string
+	defaultIdentity := []string{"$request.header.Authorization"}
+	if authorizer.AuthorizerType == apitypes.AuthorizerTypeJwt && authorizer.JwtConfiguration != nil {
+		attrs = append(attrs, lambdaTriggerApiAttrJwtIssuer+"="+aws.ToString(authorizer.JwtConfiguration.Issuer))
+		for _, audience := range authorizer.JwtConfiguration.Audience {
+			attrs = append(attrs, lambdaTriggerApiAttrJwtAudience+"="+audience)
 		}
-		Logger.Println(PreviewString(preview)+"created api stage:", name)
-	} else {
-		if *getStageOut.StageName != lambdaDollarDefault {
-			err := fmt.Errorf("api stage name misconfigured for %s %s: %s != %s", name, *api.ApiId, *getStageOut.StageName, lambdaDollarDefault)
-			Logger.Println("error:", err)
-			return "", err
+	} else if authorizer.AuthorizerType == apitypes.AuthorizerTypeRequest {
+		arn := strings.TrimSuffix(aws.ToString(authorizer.AuthorizerUri), "/invocations")
+		attrs = append(attrs, lambdaTriggerApiAttrAuthorizer+"="+LambdaArnToLambdaName(arn))
+		if authorizer.AuthorizerPayloadFormatVersion == nil {
+			defaultIdentity = []string{"route.request.header.Authorization"}
 		}
-		if !*getStageOut.AutoDeploy {
-			err := fmt.Errorf("api stage auto deploy misconfigured for %s %s, should be enabled", name, *api.ApiId)
-			Logger.Println("error:", err)
-			return "", err
+	}
+	if !reflect.DeepEqual(authorizer.IdentitySource, defaultIdentity) {
+		for _, identity := range authorizer.IdentitySource {
+			attrs = append(attrs, lambdaTriggerApiAttrAuthorizerIdentity+"="+identity)
 		}
 	}
-	getRoutesOut, err := ApiClient().GetRoutes(ctx, &apigatewayv2.GetRoutesInput{
-		ApiId:      api.ApiId,
+	if authorizer.AuthorizerResultTtlInSeconds != nil && *authorizer.AuthorizerResultTtlInSeconds != 0 {
+		attrs = append(attrs, fmt.Sprintf("%s=%d", lambdaTriggerApiAttrAuthorizerTTL, *authorizer.AuthorizerResultTtlInSeconds))
+	}
+	return attrs
+}
+
+func lambdaApiAuthorizerUri(account, lambdaName string) string {
+	arnLambda := fmt.Sprintf("arn:aws:lambda:%s:%s:function:%s", Region(), account, lambdaName)
+	return fmt.Sprintf("arn:aws:apigateway:%s:lambda:path/2015-03-31/functions/%s/invocations", Region(), arnLambda)
+}
+
+func lambdaApiAuthorizerPermissionArn(account, apiId string) string {
+	return fmt.Sprintf("arn:aws:execute-api:%s:%s:%s/authorizers/*", Region(), account, apiId)
+}
+
+func lambdaApiGetAuthorizers(ctx context.Context, apiId string) ([]apitypes.Authorizer, error) {
+	out, err := ApiClient().GetAuthorizers(ctx, &apigatewayv2.GetAuthorizersInput{
+		ApiId:      aws.String(apiId),
 		MaxResults: aws.String(fmt.Sprint(500)),
 	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return out.Items, nil
+}
+
+func lambdaEnsureTriggerApiAuthorizer(ctx context.Context, name, account string, api *apitypes.Api, auth *lambdaApiAuth, preview bool) (string, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "lambdaEnsureTriggerApiAuthorizer"}
+		d.Start()
+		defer d.End()
+	}
+	if auth == nil {
+		return "", nil
+	}
+	if api == nil || api.ApiId == nil {
+		Logger.Println(PreviewString(preview)+"created api authorizer:", name, auth.authorizerType)
+		return "", nil
+	}
+	authorizers, err := lambdaApiGetAuthorizers(ctx, *api.ApiId)
 	if err != nil {
 		Logger.Println("error:", err)
 		return "", err
 	}
-	if len(getRoutesOut.Items) == 500 {
-		err := fmt.Errorf("api has 500 or more routes: %s %s", name, *api.ApiId)
-		Logger.Println("error:", err)
-		return "", err
+	authorizerName := lambdaApiAuthorizerName + "-" + strings.ToLower(string(auth.authorizerType)) // type cannot be updated, so changing it creates a new authorizer and the old one is removed as unused
+	var existing *apitypes.Authorizer
+	for _, authorizer := range authorizers {
+		if aws.ToString(authorizer.Name) == authorizerName {
+			existing = &authorizer
+			break
+		}
 	}
-	var routeKeys []string
-	if protocolType == apitypes.ProtocolTypeHttp {
-		routeKeys = []string{lambdaDollarDefault}
-	} else if protocolType == apitypes.ProtocolTypeWebsocket {
-		routeKeys = []string{lambdaDollarDefault, lambdaDollarConnect, lambdaDollarDisconnect}
+	input := &apigatewayv2.CreateAuthorizerInput{
+		ApiId:          api.ApiId,
+		Name:           aws.String(authorizerName),
+		AuthorizerType: auth.authorizerType,
+		IdentitySource: auth.identity,
 	}
-	for _, routeKey := range routeKeys {
-		var routes []apitypes.Route
-		for _, route := range getRoutesOut.Items {
-			if *route.RouteKey == routeKey {
-				routes = append(routes, route)
+	if auth.authorizerType == apitypes.AuthorizerTypeJwt {
+		input.JwtConfiguration = &apitypes.JWTConfiguration{
+			Issuer:   aws.String(auth.issuer),
+			Audience: auth.audience,
+		}
+	} else {
+		input.AuthorizerUri = aws.String(lambdaApiAuthorizerUri(account, auth.lambdaName))
+		if api.ProtocolType == apitypes.ProtocolTypeHttp {
+			input.AuthorizerPayloadFormatVersion = aws.String(lambdaApiAuthorizerPayloadFormat)
+			input.EnableSimpleResponses = aws.Bool(true)
+			input.AuthorizerResultTtlInSeconds = aws.Int32(auth.ttl)
+		}
+	}
+	authorizerId := ""
+	if existing == nil {
+		if !preview {
+			out, err := ApiClient().CreateAuthorizer(ctx, input)
+			if err != nil {
+				Logger.Println("error:", err)
+				return "", err
 			}
+			authorizerId = *out.AuthorizerId
 		}
-		switch len(routes) {
-		case 0:
+		Logger.Println(PreviewString(preview)+"created api authorizer:", name, auth.authorizerType)
+	} else {
+		authorizerId = *existing.AuthorizerId
+		existingAttrs := lambdaApiAuthAttrs(*existing)
+		newAttrs := lambdaApiAuthAttrs(apitypes.Authorizer{
+			AuthorizerType:                 input.AuthorizerType,
+			AuthorizerUri:                  input.AuthorizerUri,
+			AuthorizerPayloadFormatVersion: input.AuthorizerPayloadFormatVersion,
+			AuthorizerResultTtlInSeconds:   input.AuthorizerResultTtlInSeconds,
+			IdentitySource:                 input.IdentitySource,
+			JwtConfiguration:               input.JwtConfiguration,
+		})
+		if !reflect.DeepEqual(existingAttrs, newAttrs) {
 			if !preview {
-				_, err := ApiClient().CreateRoute(ctx, &apigatewayv2.CreateRouteInput{
-					ApiId:             api.ApiId,
-					Target:            aws.String(fmt.Sprintf("integrations/%s", integrationId)),
-					RouteKey:          aws.String(routeKey),
-					AuthorizationType: apitypes.AuthorizationType(lambdaAuthorizationType),
-					ApiKeyRequired:    aws.Bool(false),
+				_, err := ApiClient().UpdateAuthorizer(ctx, &apigatewayv2.UpdateAuthorizerInput{
+					ApiId:                          api.ApiId,
+					AuthorizerId:                   existing.AuthorizerId,
+					AuthorizerType:                 input.AuthorizerType,
+					AuthorizerUri:                  input.AuthorizerUri,
+					AuthorizerPayloadFormatVersion: input.AuthorizerPayloadFormatVersion,
+					AuthorizerResultTtlInSeconds:   input.AuthorizerResultTtlInSeconds,
+					EnableSimpleResponses:          input.EnableSimpleResponses,
+					IdentitySource:                 input.IdentitySource,
+					JwtConfiguration:               input.JwtConfiguration,
 				})
 				if err != nil {
 					Logger.Println("error:", err)
 					return "", err
 				}
 			}
-			Logger.Println(PreviewString(preview)+"created api route:", name, routeKey)
-		case 1:
-			route := routes[0]
-			if *route.Target != fmt.Sprintf("integrations/%s", integrationId) {
-				err := fmt.Errorf("api route target misconfigured for %s %s: %s != %s", name, *api.ApiId, *route.Target, fmt.Sprintf("integrations/%s", integrationId))
-				Logger.Println("error:", err)
-				return "", err
-			}
-			if *route.RouteKey != routeKey {
-				err := fmt.Errorf("api route key misconfigured for %s %s: %s != %s", name, *api.ApiId, *route.RouteKey, routeKey)
-				Logger.Println("error:", err)
-				return "", err
-			}
-			if route.AuthorizationType != apitypes.AuthorizationType(lambdaAuthorizationType) {
-				err := fmt.Errorf("api route authorization type misconfigured for %s %s: %s != %s", name, *api.ApiId, route.AuthorizationType, lambdaAuthorizationType)
-				Logger.Println("error:", err)
-				return "", err
-			}
-			if *route.ApiKeyRequired {
-				err := fmt.Errorf("api route apiKeyRequired misconfigured for %s %s, should be disabled", name, *api.ApiId)
-				Logger.Println("error:", err)
-				return "", err
-			}
-		default:
-			err := fmt.Errorf("api has more than one route: %s %s %v", name, routeKey, Pformat(routes))
+			Logger.Println(PreviewString(preview)+"updated api authorizer:", name, strings.Join(existingAttrs, " "), "=>", strings.Join(newAttrs, " "))
+		}
+	}
+	if auth.authorizerType == apitypes.AuthorizerTypeRequest {
+		_, err := lambdaEnsurePermission(ctx, auth.lambdaName, "apigateway.amazonaws.com", lambdaApiAuthorizerPermissionArn(account, *api.ApiId), preview)
+		if err != nil {
 			Logger.Println("error:", err)
 			return "", err
 		}
 	}
-	arn := fmt.Sprintf("arn:aws:execute-api:%s:%s:%s/*/*", Region(), account, *api.ApiId)
-	lambdaName := Last(strings.Split(arnLambda, ":"))
-	sid, err := lambdaEnsurePermission(ctx, lambdaName, "apigateway.amazonaws.com", arn, preview)
+	return authorizerId, nil
+}
+
+func lambdaRemoveUnusedApiAuthorizers(ctx context.Context, name string, api *apitypes.Api, authorizerId string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "lambdaRemoveUnusedApiAuthorizers"}
+		d.Start()
+		defer d.End()
+	}
+	if api == nil || api.ApiId == nil {
+		return nil
+	}
+	authorizers, err := lambdaApiGetAuthorizers(ctx, *api.ApiId)
 	if err != nil {
 		Logger.Println("error:", err)
-		return "", err
+		return err
 	}
-	return sid, nil
+	for _, authorizer := range authorizers {
+		if *authorizer.AuthorizerId == authorizerId {
+			continue
+		}
+		if !preview {
+			_, err := ApiClient().DeleteAuthorizer(ctx, &apigatewayv2.DeleteAuthorizerInput{
+				ApiId:        api.ApiId,
+				AuthorizerId: authorizer.AuthorizerId,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"deleted api authorizer:", name, authorizer.AuthorizerType)
+	}
+	return nil
+}
+
+// lambdaEnsureApiPermissions keeps the invoke permissions granted to this
+// lambda by shared apis routing to it and by api triggers of other lambdas
+// using it as their authorizer
+func lambdaEnsureApiPermissions(ctx context.Context, infraLambda *InfraLambda, preview bool) ([]string, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "lambdaEnsureApiPermissions"}
+		d.Start()
+		defer d.End()
+	}
+	if len(infraLambda.authorizerFor) == 0 && len(infraLambda.sharedApis) == 0 {
+		return nil, nil
+	}
+	account, err := StsAccount(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	var sids []string
+	for _, apiName := range append(slices.Clone(infraLambda.authorizerFor), infraLambda.sharedApis...) {
+		api, err := Api(ctx, apiName)
+		if err != nil {
+			if err.Error() == ErrApiNotFound {
+				continue // the permission is granted once the api is created
+			}
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		arn := apiPermissionArn(account, *api.ApiId)
+		if slices.Contains(infraLambda.authorizerFor, apiName) {
+			arn = lambdaApiAuthorizerPermissionArn(account, *api.ApiId)
+		}
+		sid, err := lambdaEnsurePermission(ctx, infraLambda.Name, "apigateway.amazonaws.com", arn, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		sids = append(sids, sid)
+	}
+	return sids, nil
 }
 
 func lambdaEnsureTriggerApiDomainName(ctx context.Context, name, domain string, preview bool) error {
@@ -1480,7 +2846,24 @@ func LambdaEnsureTriggerApi(ctx context.Context, infraLambda *InfraLambda, previ
 					}
 				}
 			}
-			sid, err := lambdaEnsureTriggerApiIntegrationStageRoute(ctx, apiName, arnLambda, protocolType, api, timeoutMillis, preview)
+			auth, err := lambdaApiAuthInput(trigger)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			stage, err := lambdaApiStageInput(trigger)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if stage.accessLogs {
+				err := LogsEnsureGroup(ctx, infraLambda.infraSetName, lambdaApiAccessLogGroup(apiName), stage.accessLogsTTLDays, preview)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			sid, err := lambdaEnsureTriggerApiIntegrationStageRoute(ctx, apiName, arnLambda, protocolType, api, timeoutMillis, auth, stage, preview)
 			if err != nil {
 				Logger.Println("error:", err)
 				return nil, err
@@ -1512,6 +2895,8 @@ func LambdaEnsureTriggerApi(ctx context.Context, infraLambda *InfraLambda, previ
 						Logger.Println("error:", err)
 						return nil, err
 					}
+				case lambdaTriggerApiAttrJwtIssuer, lambdaTriggerApiAttrJwtAudience, lambdaTriggerApiAttrAuthorizer, lambdaTriggerApiAttrAuthorizerIdentity, lambdaTriggerApiAttrAuthorizerTTL: // handled by lambdaApiAuthInput
+				case lambdaTriggerApiAttrThrottleRate, lambdaTriggerApiAttrThrottleBurst, lambdaTriggerApiAttrAccessLogs, lambdaTriggerApiAttrAccessLogsTTLDays, lambdaTriggerApiAttrDetailedMetrics: // handled by lambdaApiStageInput
 				default:
 					err := fmt.Errorf("unknown attr: %s", attr)
 					Logger.Println("error:", err)
@@ -1592,6 +2977,11 @@ func lambdaTriggerApiDeleteApi(ctx context.Context, name string, api *apitypes.A
 		}
 	}
 	Logger.Println(PreviewString(preview)+"deleted api trigger for:", name)
+	err := LogsDeleteGroup(ctx, lambdaApiAccessLogGroup(name), preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
 	return nil
 }
 
@@ -1689,150 +3079,375 @@ func lambdaScheduleName(name, schedule string) string {
 	return name + lambdaEventRuleNameSeparator + strings.ReplaceAll(base64.StdEncoding.EncodeToString([]byte(schedule)), "=", "")
 }
 
+const (
+	lambdaScheduleAttrTimezone = "timezone"
+	lambdaScheduleAttrPayload  = "payload"
+	lambdaScheduleAttrStart    = "start"
+	lambdaScheduleAttrEnd      = "end"
+	lambdaScheduleAttrWindow   = "window"
+
+	lambdaScheduleRoleSuffix = lambdaEventRuleNameSeparator + "scheduler"
+	lambdaScheduleRoleMaxLen = 64 // iam role name limit
+)
+
+type lambdaSchedule struct {
+	expression string
+	timezone   string
+	payload    string
+	start      *time.Time
+	end        *time.Time
+	window     int32
+}
+
+// lambdaScheduleInput parses a schedule trigger like:
+//
+//	rate(15 minutes)
+//	cron(0 9 * * ? *) timezone=America/New_York payload={"job":"report"}
+//	at(2030-01-01T00:00:00) window=15 start=2029-01-01T00:00:00Z
+func lambdaScheduleInput(trigger *InfraTrigger) (*lambdaSchedule, error) {
+	if len(trigger.Attr) == 0 {
+		err := fmt.Errorf("schedule trigger requires an expression: %#v", trigger)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	schedule := &lambdaSchedule{expression: trigger.Attr[0]}
+	if !(strings.HasPrefix(schedule.expression, "rate(") || strings.HasPrefix(schedule.expression, "cron(") || strings.HasPrefix(schedule.expression, "at(")) || !strings.HasSuffix(schedule.expression, ")") {
+		err := fmt.Errorf("schedule expression should be rate(...), cron(...) or at(...), got: %s", schedule.expression)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	for _, attr := range trigger.Attr[1:] {
+		k, v, err := SplitOnce(attr, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case lambdaScheduleAttrTimezone:
+			_, err := time.LoadLocation(v)
+			if err != nil {
+				err := fmt.Errorf("schedule timezone should be an IANA timezone like America/New_York, got: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			schedule.timezone = v
+		case lambdaScheduleAttrPayload:
+			if !json.Valid([]byte(v)) {
+				err := fmt.Errorf("schedule payload should be json, got: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			schedule.payload = v
+		case lambdaScheduleAttrStart, lambdaScheduleAttrEnd:
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				err := fmt.Errorf("schedule %s should be RFC3339 like 2030-01-01T00:00:00Z, got: %s", k, v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			t = t.UTC()
+			if k == lambdaScheduleAttrStart {
+				schedule.start = &t
+			} else {
+				schedule.end = &t
+			}
+		case lambdaScheduleAttrWindow:
+			if !IsDigit(v) || Atoi(v) < 1 || Atoi(v) > 1440 {
+				err := fmt.Errorf("schedule window should be minutes between 1 and 1440, got: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			schedule.window = int32(Atoi(v))
+		default:
+			err := fmt.Errorf("unknown schedule attr: %s", attr)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	if schedule.start != nil && schedule.end != nil && !schedule.start.Before(*schedule.end) {
+		err := fmt.Errorf("schedule start should be before end: %s", strings.Join(trigger.Attr, " "))
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func lambdaScheduleAttrs(schedule *lambdaSchedule) []string {
+	attrs := []string{schedule.expression}
+	if schedule.timezone != "" && schedule.timezone != "UTC" {
+		attrs = append(attrs, lambdaScheduleAttrTimezone+"="+schedule.timezone)
+	}
+	if schedule.payload != "" {
+		attrs = append(attrs, lambdaScheduleAttrPayload+"="+schedule.payload)
+	}
+	if schedule.start != nil {
+		attrs = append(attrs, lambdaScheduleAttrStart+"="+schedule.start.UTC().Format(time.RFC3339))
+	}
+	if schedule.end != nil {
+		attrs = append(attrs, lambdaScheduleAttrEnd+"="+schedule.end.UTC().Format(time.RFC3339))
+	}
+	if schedule.window > 0 {
+		attrs = append(attrs, lambdaScheduleAttrWindow+"="+fmt.Sprint(schedule.window))
+	}
+	return attrs
+}
+
+// schedules are named by the hash of their attrs, so any change replaces the schedule
+func lambdaScheduleHashName(schedule *lambdaSchedule) string {
+	return sha256Hex([]byte(strings.Join(lambdaScheduleAttrs(schedule), "\n")))[:32]
+}
+
+// lambdaScheduleRoleName is the lambda name with a suffix. long names are
+// truncated and made unique with a hash to fit the iam role name limit.
+func lambdaScheduleRoleName(name string) string {
+	roleName := name + lambdaScheduleRoleSuffix
+	if len(roleName) <= lambdaScheduleRoleMaxLen {
+		return roleName
+	}
+	hash := sha256Hex([]byte(name))[:8]
+	return name[:lambdaScheduleRoleMaxLen-len(lambdaScheduleRoleSuffix)-len(hash)-1] + "_" + hash + lambdaScheduleRoleSuffix
+}
+
+// lambdaScheduleGetGroup returns the schedule group for a lambda, or nil if it does not exist
+func lambdaScheduleGetGroup(ctx context.Context, name string) (*scheduler.GetScheduleGroupOutput, error) {
+	out, err := SchedulerClient().GetScheduleGroup(ctx, &scheduler.GetScheduleGroupInput{
+		Name: aws.String(name),
+	})
+	if err != nil {
+		var rnfe *schedulertypes.ResourceNotFoundException
+		if errors.As(err, &rnfe) {
+			return nil, nil
+		}
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return out, nil
+}
+
+// lambdaScheduleWaitForGroupGone waits up to 5 minutes for an asynchronous
+// schedule group deletion to finish
+func lambdaScheduleWaitForGroupGone(ctx context.Context, name string) error {
+	for range 150 {
+		group, err := lambdaScheduleGetGroup(ctx, name)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if group == nil {
+			return nil
+		}
+		Logger.Println("waiting for schedule group to be deleted:", name)
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			Logger.Println("error:", ctx.Err())
+			return ctx.Err()
+		}
+	}
+	err := fmt.Errorf("timed out waiting for schedule group to be deleted: %s", name)
+	Logger.Println("error:", err)
+	return err
+}
+
 func LambdaEnsureTriggerSchedule(ctx context.Context, infraLambda *InfraLambda, preview bool) ([]string, error) {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "LambdaEnsureTriggerSchedule"}
 		d.Start()
 		defer d.End()
 	}
-	var permissionSids []string
-	var triggers []string
+	schedules := map[string]*lambdaSchedule{}
 	for _, trigger := range infraLambda.Trigger {
 		if trigger.Type == lambdaTriggerSchedule {
-			triggers = append(triggers, trigger.Attr[0])
+			schedule, err := lambdaScheduleInput(trigger)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			schedules[lambdaScheduleHashName(schedule)] = schedule
 		}
 	}
-	if len(triggers) > 0 {
-		for _, schedule := range triggers {
-			var scheduleArn string
-			scheduleName := lambdaScheduleName(infraLambda.Name, schedule)
-			out, err := EventsClient().DescribeRule(ctx, &eventbridge.DescribeRuleInput{
-				Name: aws.String(scheduleName),
-			})
+	roleName := lambdaScheduleRoleName(infraLambda.Name)
+	group, err := lambdaScheduleGetGroup(ctx, infraLambda.Name)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if group != nil && group.State == schedulertypes.ScheduleGroupStateDeleting {
+		if !preview {
+			err := lambdaScheduleWaitForGroupGone(ctx, infraLambda.Name)
 			if err != nil {
-				var rnfe *eventbridgetypes.ResourceNotFoundException
-				if !errors.As(err, &rnfe) {
-					return nil, err
-				}
-				if !preview {
-					out, err := EventsClient().PutRule(ctx, &eventbridge.PutRuleInput{
-						Name:               aws.String(scheduleName),
-						ScheduleExpression: aws.String(schedule),
-						Tags: []eventbridgetypes.Tag{{
-							Key:   aws.String(infraSetTagName),
-							Value: aws.String(infraLambda.infraSetName),
-						}},
-					})
-					if err != nil {
-						Logger.Println("error:", err)
-						return nil, err
-					}
-					scheduleArn = *out.RuleArn
-				}
-				Logger.Println(PreviewString(preview)+"created cloudwatch rule:", scheduleName, schedule)
-			} else {
-				if *out.ScheduleExpression != schedule {
-					err := fmt.Errorf("cloudwatch rule misconfigured: %s %s != %s", scheduleName, schedule, *out.ScheduleExpression)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+		}
+		group = nil
+	}
+	if len(schedules) > 0 {
+		err := IamEnsureRole(ctx, infraLambda.infraSetName, roleName, "scheduler", preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		err = IamEnsureRoleAllows(ctx, roleName, []string{"lambda:InvokeFunction " + infraLambda.Arn}, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		roleArn, err := IamRoleArn(ctx, "scheduler", roleName)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		existing := map[string]bool{}
+		if group == nil {
+			if !preview {
+				_, err := SchedulerClient().CreateScheduleGroup(ctx, &scheduler.CreateScheduleGroupInput{
+					Name: aws.String(infraLambda.Name),
+					Tags: []schedulertypes.Tag{{
+						Key:   aws.String(infraSetTagName),
+						Value: aws.String(infraLambda.infraSetName),
+					}},
+				})
+				if err != nil {
 					Logger.Println("error:", err)
 					return nil, err
 				}
-				scheduleArn = *out.Arn
 			}
-			sid, err := lambdaEnsurePermission(ctx, infraLambda.Name, "events.amazonaws.com", scheduleArn, preview)
+			Logger.Println(PreviewString(preview)+"created schedule group:", infraLambda.Name)
+		} else {
+			summaries, err := SchedulerListSchedules(ctx, infraLambda.Name)
 			if err != nil {
 				Logger.Println("error:", err)
 				return nil, err
 			}
-			permissionSids = append(permissionSids, sid)
-			var targets []eventbridgetypes.Target
-			err = Retry(ctx, func() error {
-				var err error
-				targets, err = EventsListRuleTargets(ctx, scheduleName, nil)
-				var rnfe *eventbridgetypes.ResourceNotFoundException
-				if errors.As(err, &rnfe) {
-					return nil
+			for _, summary := range summaries {
+				_, ok := schedules[*summary.Name]
+				if ok && summary.Target != nil && summary.Target.Arn != nil && *summary.Target.Arn == infraLambda.Arn {
+					existing[*summary.Name] = true
+					continue
 				}
-				return err
-			})
-			if err != nil {
-				Logger.Println("error:", err)
-				return nil, err
-			}
-			switch len(targets) {
-			case 0:
 				if !preview {
-					_, err := EventsClient().PutTargets(ctx, &eventbridge.PutTargetsInput{
-						Rule: aws.String(scheduleName),
-						Targets: []eventbridgetypes.Target{{
-							Id:  aws.String("1"),
-							Arn: aws.String(infraLambda.Arn),
-						}},
+					_, err := SchedulerClient().DeleteSchedule(ctx, &scheduler.DeleteScheduleInput{
+						Name:      summary.Name,
+						GroupName: aws.String(infraLambda.Name),
 					})
 					if err != nil {
 						Logger.Println("error:", err)
 						return nil, err
 					}
 				}
-				Logger.Println(PreviewString(preview)+"created cloudwatch rule target:", scheduleName, infraLambda.Arn)
-			case 1:
-				if *targets[0].Arn != infraLambda.Arn {
-					err := fmt.Errorf("cloudwatch rule is misconfigured with unknown target: %s != %s", infraLambda.Arn, *targets[0].Arn)
+				Logger.Println(PreviewString(preview)+"deleted schedule:", infraLambda.Name, *summary.Name)
+			}
+		}
+		for name, schedule := range schedules {
+			if existing[name] {
+				continue
+			}
+			if !preview {
+				input := &scheduler.CreateScheduleInput{
+					Name:               aws.String(name),
+					GroupName:          aws.String(infraLambda.Name),
+					ScheduleExpression: aws.String(schedule.expression),
+					StartDate:          schedule.start,
+					EndDate:            schedule.end,
+					FlexibleTimeWindow: &schedulertypes.FlexibleTimeWindow{
+						Mode: schedulertypes.FlexibleTimeWindowModeOff,
+					},
+					Target: &schedulertypes.Target{
+						Arn:     aws.String(infraLambda.Arn),
+						RoleArn: aws.String(roleArn),
+					},
+				}
+				if schedule.timezone != "" {
+					input.ScheduleExpressionTimezone = aws.String(schedule.timezone)
+				}
+				if schedule.payload != "" {
+					input.Target.Input = aws.String(schedule.payload)
+				}
+				if schedule.window > 0 {
+					input.FlexibleTimeWindow = &schedulertypes.FlexibleTimeWindow{
+						Mode:                   schedulertypes.FlexibleTimeWindowModeFlexible,
+						MaximumWindowInMinutes: aws.Int32(schedule.window),
+					}
+				}
+				err := Retry(ctx, func() error {
+					_, err := SchedulerClient().CreateSchedule(ctx, input)
+					return err // retry while the new role propagates
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"created schedule:", infraLambda.Name, strings.Join(lambdaScheduleAttrs(schedule), " "))
+		}
+	} else {
+		if group != nil {
+			if !preview {
+				_, err := SchedulerClient().DeleteScheduleGroup(ctx, &scheduler.DeleteScheduleGroupInput{
+					Name: aws.String(infraLambda.Name),
+				})
+				if err != nil {
 					Logger.Println("error:", err)
 					return nil, err
 				}
-			default:
-				var targetArns []string
-				for _, target := range targets {
-					targetArns = append(targetArns, *target.Arn)
+				err = lambdaScheduleWaitForGroupGone(ctx, infraLambda.Name)
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
 				}
-				err := fmt.Errorf("cloudwatch rule is misconfigured with unknown targets: %s %v", infraLambda.Arn, targetArns)
-				Logger.Println("error:", err)
-				return nil, err
 			}
+			Logger.Println(PreviewString(preview)+"deleted schedule group:", infraLambda.Name)
+		}
+		err := IamDeleteRole(ctx, roleName, preview)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
 		}
 	}
+	// schedules were previously cloudwatch rules, remove any that remain
 	rules, err := EventsListRules(ctx, nil)
 	if err != nil {
 		Logger.Println("error:", err)
 		return nil, err
 	}
 	for _, rule := range rules {
+		if rule.ScheduleExpression == nil || *rule.Name != lambdaScheduleName(infraLambda.Name, *rule.ScheduleExpression) {
+			continue
+		}
 		targets, err := EventsListRuleTargets(ctx, *rule.Name, nil)
 		if err != nil {
 			Logger.Println("error:", err)
 			return nil, err
 		}
-		for _, target := range targets {
-			if *target.Arn == infraLambda.Arn && rule.ScheduleExpression != nil && !slices.Contains(triggers, *rule.ScheduleExpression) {
-				if !preview {
-					ids := []string{}
-					for _, target := range targets {
-						if target.Id != nil {
-							ids = append(ids, *target.Id)
-						}
-					}
-					_, err := EventsClient().RemoveTargets(ctx, &eventbridge.RemoveTargetsInput{
-						Rule: rule.Name,
-						Ids:  ids,
-					})
-					if err != nil {
-						Logger.Println("error:", err)
-						return nil, err
-					}
-					_, err = EventsClient().DeleteRule(ctx, &eventbridge.DeleteRuleInput{
-						Name: rule.Name,
-					})
-					if err != nil {
-						Logger.Println("error:", err)
-						return nil, err
-					}
+		if !preview {
+			ids := []string{}
+			for _, target := range targets {
+				if target.Id != nil {
+					ids = append(ids, *target.Id)
 				}
-				Logger.Println(PreviewString(preview)+"deleted schedule trigger:", infraLambda.Name)
-				break
+			}
+			if len(ids) > 0 {
+				_, err := EventsClient().RemoveTargets(ctx, &eventbridge.RemoveTargetsInput{
+					Rule: rule.Name,
+					Ids:  ids,
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return nil, err
+				}
+			}
+			_, err = EventsClient().DeleteRule(ctx, &eventbridge.DeleteRuleInput{
+				Name: rule.Name,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
 			}
 		}
+		Logger.Println(PreviewString(preview)+"deleted cloudwatch rule:", *rule.Name, *rule.ScheduleExpression)
 	}
-	return permissionSids, nil
+	return nil, nil
 }
 
 func lambdaDynamoDBTriggerAttrShortcut(s string) string {
@@ -2040,6 +3655,211 @@ func LambdaEnsureTriggerDynamoDB(ctx context.Context, infraLambda *InfraLambda,
 	return nil
 }
 
+func lambdaKinesisTriggerAttrShortcut(s string) string {
+	s2, ok := map[string]string{
+		"batch":    "BatchSize",
+		"bisect":   "BisectBatchOnFunctionError",
+		"parallel": "ParallelizationFactor",
+		"retry":    "MaximumRetryAttempts",
+		"start":    "StartingPosition",
+		"window":   "MaximumBatchingWindowInSeconds",
+	}[s]
+	if ok {
+		return s2
+	}
+	return s
+}
+
+func lambdaKinesisTriggerInput(functionName string, triggerAttrs []string) (*lambda.CreateEventSourceMappingInput, error) {
+	input := &lambda.CreateEventSourceMappingInput{
+		FunctionName:                   aws.String(functionName),
+		Enabled:                        aws.Bool(true),
+		BatchSize:                      aws.Int32(100),
+		BisectBatchOnFunctionError:     aws.Bool(false),
+		MaximumBatchingWindowInSeconds: aws.Int32(0),
+		MaximumRetryAttempts:           aws.Int32(-1),
+		ParallelizationFactor:          aws.Int32(1),
+		StartingPosition:               lambdatypes.EventSourcePositionLatest,
+	}
+	for _, line := range triggerAttrs {
+		attr, value, err := SplitOnce(line, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		attr = lambdaKinesisTriggerAttrShortcut(attr)
+		switch attr {
+		case "BatchSize", "MaximumBatchingWindowInSeconds", "MaximumRetryAttempts", "ParallelizationFactor":
+			num, err := strconv.Atoi(value)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			switch attr {
+			case "BatchSize":
+				input.BatchSize = aws.Int32(int32(num))
+			case "MaximumBatchingWindowInSeconds":
+				input.MaximumBatchingWindowInSeconds = aws.Int32(int32(num))
+			case "MaximumRetryAttempts":
+				input.MaximumRetryAttempts = aws.Int32(int32(num))
+			case "ParallelizationFactor":
+				input.ParallelizationFactor = aws.Int32(int32(num))
+			}
+		case "BisectBatchOnFunctionError":
+			if value != "true" && value != "false" {
+				err := fmt.Errorf("lambda kinesis trigger attribute bisect should be true or false, got: %s", value)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.BisectBatchOnFunctionError = aws.Bool(value == "true")
+		case "StartingPosition":
+			position := lambdatypes.EventSourcePosition(strings.ToUpper(value))
+			if position != lambdatypes.EventSourcePositionLatest && position != lambdatypes.EventSourcePositionTrimHorizon {
+				err := fmt.Errorf("lambda kinesis trigger attribute start should be latest or trim_horizon, got: %s", value)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.StartingPosition = position
+		default:
+			err := fmt.Errorf("unknown lambda kinesis trigger attribute: %s", line)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	return input, nil
+}
+
+func LambdaEnsureTriggerKinesis(ctx context.Context, infraLambda *InfraLambda, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "LambdaEnsureTriggerKinesis"}
+		d.Start()
+		defer d.End()
+	}
+	var triggerStreams []string
+	for _, trigger := range infraLambda.Trigger {
+		if trigger.Type != lambdaTriggerKinesis {
+			continue
+		}
+		streamName := trigger.Attr[0]
+		triggerAttrs := trigger.Attr[1:]
+		triggerStreams = append(triggerStreams, streamName)
+		createMappingInput, err := lambdaKinesisTriggerInput(infraLambda.Name, triggerAttrs)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		streamArn, err := KinesisStreamArn(ctx, streamName)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		createMappingInput.EventSourceArn = aws.String(streamArn)
+		var found *lambdatypes.EventSourceMappingConfiguration
+		count := 0
+		eventSourceMappings, err := lambdaListEventSourceMappings(ctx, infraLambda.Name)
+		if err != nil {
+			if !preview {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		for _, mapping := range eventSourceMappings {
+			if *mapping.EventSourceArn == streamArn && *mapping.FunctionArn == infraLambda.Arn {
+				found = &mapping
+				count++
+			}
+		}
+		switch count {
+		case 0:
+			if !preview {
+				err := Retry(ctx, func() error {
+					_, err := LambdaClient().CreateEventSourceMapping(ctx, createMappingInput)
+					return err
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"created event source mapping:", infraLambda.Name, infraLambda.Arn, streamArn, strings.Join(triggerAttrs, " "))
+		case 1:
+			needsUpdate := false
+			update := &lambda.UpdateEventSourceMappingInput{UUID: found.UUID}
+			update.FunctionName = createMappingInput.FunctionName
+			if *found.BatchSize != *createMappingInput.BatchSize {
+				Logger.Printf(PreviewString(preview)+"will update lambda event source mapping BatchSize for %s %s: %d => %d\n", infraLambda.Name, streamName, *found.BatchSize, *createMappingInput.BatchSize)
+				update.BatchSize = createMappingInput.BatchSize
+				needsUpdate = true
+			}
+			if aws.ToBool(found.BisectBatchOnFunctionError) != *createMappingInput.BisectBatchOnFunctionError {
+				Logger.Printf(PreviewString(preview)+"will update lambda event source mapping BisectBatchOnFunctionError for %s %s: %t => %t\n", infraLambda.Name, streamName, aws.ToBool(found.BisectBatchOnFunctionError), *createMappingInput.BisectBatchOnFunctionError)
+				update.BisectBatchOnFunctionError = createMappingInput.BisectBatchOnFunctionError
+				needsUpdate = true
+			}
+			if *found.MaximumRetryAttempts != *createMappingInput.MaximumRetryAttempts {
+				Logger.Printf(PreviewString(preview)+"will update lambda event source mapping MaximumRetryAttempts for %s %s: %d => %d\n", infraLambda.Name, streamName, *found.MaximumRetryAttempts, *createMappingInput.MaximumRetryAttempts)
+				update.MaximumRetryAttempts = createMappingInput.MaximumRetryAttempts
+				needsUpdate = true
+			}
+			if *found.ParallelizationFactor != *createMappingInput.ParallelizationFactor {
+				Logger.Printf(PreviewString(preview)+"will update lambda event source mapping ParallelizationFactor for %s %s: %d => %d\n", infraLambda.Name, streamName, *found.ParallelizationFactor, *createMappingInput.ParallelizationFactor)
+				update.ParallelizationFactor = createMappingInput.ParallelizationFactor
+				needsUpdate = true
+			}
+			if *found.MaximumBatchingWindowInSeconds != *createMappingInput.MaximumBatchingWindowInSeconds {
+				Logger.Printf(PreviewString(preview)+"will update lambda event source mapping MaximumBatchingWindowInSeconds for %s %s: %d => %d\n", infraLambda.Name, streamName, *found.MaximumBatchingWindowInSeconds, *createMappingInput.MaximumBatchingWindowInSeconds)
+				update.MaximumBatchingWindowInSeconds = createMappingInput.MaximumBatchingWindowInSeconds
+				needsUpdate = true
+			}
+			if found.StartingPosition != createMappingInput.StartingPosition {
+				err := fmt.Errorf("cannot update StartingPosition for %s %s: %s => %s", infraLambda.Name, streamName, found.StartingPosition, createMappingInput.StartingPosition)
+				Logger.Println("error:", err)
+				return err
+			}
+			if needsUpdate {
+				if !preview {
+					_, err := LambdaClient().UpdateEventSourceMapping(ctx, update)
+					if err != nil {
+						Logger.Println("error:", err)
+						return err
+					}
+				}
+				Logger.Println(PreviewString(preview)+"updated event source mapping for", infraLambda.Name, streamName)
+			}
+		default:
+			err := fmt.Errorf("found more than 1 event source mapping for %s %s", infraLambda.Name, streamName)
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	eventSourceMappings, err := lambdaListEventSourceMappings(ctx, infraLambda.Arn)
+	if err != nil {
+		if !preview {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	for _, mapping := range eventSourceMappings {
+		if ArnToInfraName(*mapping.EventSourceArn) != lambdaTriggerKinesis {
+			continue
+		}
+		streamName := KinesisArnToName(*mapping.EventSourceArn)
+		if !slices.Contains(triggerStreams, streamName) {
+			if !preview {
+				_, err := LambdaClient().DeleteEventSourceMapping(ctx, &lambda.DeleteEventSourceMappingInput{
+					UUID: mapping.UUID,
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"deleted trigger:", infraLambda.Name, streamName)
+		}
+	}
+	return nil
+}
+
 func lambdaListEventSourceMappings(ctx context.Context, name string) ([]lambdatypes.EventSourceMappingConfiguration, error) {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "lambdaListEventSourceMappings"}
@@ -2067,8 +3887,10 @@ func lambdaListEventSourceMappings(ctx context.Context, name string) ([]lambdaty
 
 func lambdaSQSTriggerAttrShortcut(s string) string {
 	s2, ok := map[string]string{
-		"batch":  "BatchSize",
-		"window": "MaximumBatchingWindowInSeconds",
+		"batch":       "BatchSize",
+		"window":      "MaximumBatchingWindowInSeconds",
+		"partial":     "ReportBatchItemFailures",
+		"concurrency": "MaximumConcurrency",
 	}[s]
 	if ok {
 		return s2
@@ -2076,6 +3898,104 @@ func lambdaSQSTriggerAttrShortcut(s string) string {
 	return s
 }
 
+// lambdaSQSTriggerInput parses sqs trigger attrs, where filter may be repeated:
+//
+//	batch=10 window=5 partial=true concurrency=5 filter={"body": {"type": ["order"]}}
+func lambdaSQSTriggerInput(functionName string, triggerAttrs []string) (*lambda.CreateEventSourceMappingInput, error) {
+	input := &lambda.CreateEventSourceMappingInput{
+		FunctionName:                   aws.String(functionName),
+		Enabled:                        aws.Bool(true),
+		BatchSize:                      aws.Int32(10),
+		MaximumBatchingWindowInSeconds: aws.Int32(0),
+	}
+	for _, line := range triggerAttrs {
+		attr, value, err := SplitOnce(line, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		attr = lambdaSQSTriggerAttrShortcut(attr)
+		switch attr {
+		case "BatchSize":
+			size, err := strconv.Atoi(value)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.BatchSize = aws.Int32(int32(size))
+		case "MaximumBatchingWindowInSeconds":
+			size, err := strconv.Atoi(value)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.MaximumBatchingWindowInSeconds = aws.Int32(int32(size))
+		case "ReportBatchItemFailures":
+			if value != "true" && value != "false" {
+				err := fmt.Errorf("lambda sqs trigger attribute partial should be true or false, got: %s", value)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if value == "true" {
+				input.FunctionResponseTypes = []lambdatypes.FunctionResponseType{lambdatypes.FunctionResponseTypeReportBatchItemFailures}
+			}
+		case "MaximumConcurrency":
+			num, err := strconv.Atoi(value)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if num < 2 || num > 1000 {
+				err := fmt.Errorf("lambda sqs trigger attribute concurrency should be between 2 and 1000, got: %d", num)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.ScalingConfig = &lambdatypes.ScalingConfig{MaximumConcurrency: aws.Int32(int32(num))}
+		case "filter":
+			var pattern map[string]any
+			err := json.Unmarshal([]byte(value), &pattern)
+			if err != nil {
+				err := fmt.Errorf("lambda sqs trigger filter should be a json object, got: %s", value)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if input.FilterCriteria == nil {
+				input.FilterCriteria = &lambdatypes.FilterCriteria{}
+			}
+			input.FilterCriteria.Filters = append(input.FilterCriteria.Filters, lambdatypes.Filter{Pattern: aws.String(value)})
+		default:
+			err := fmt.Errorf("unknown sqs trigger attribute: %s", line)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	return input, nil
+}
+
+// lambdaFilterPatterns returns the normalized json patterns of filter criteria, sorted
+func lambdaFilterPatterns(criteria *lambdatypes.FilterCriteria) ([]string, error) {
+	var patterns []string
+	if criteria == nil {
+		return patterns, nil
+	}
+	for _, filter := range criteria.Filters {
+		var pattern map[string]any
+		err := json.Unmarshal([]byte(aws.ToString(filter.Pattern)), &pattern)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		data, err := json.Marshal(pattern)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		patterns = append(patterns, string(data))
+	}
+	slices.Sort(patterns)
+	return patterns, nil
+}
+
 func LambdaEnsureTriggerSQS(ctx context.Context, infraLambda *InfraLambda, preview bool) error {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "LambdaEnsureTriggerSQS"}
@@ -2099,41 +4019,12 @@ func LambdaEnsureTriggerSQS(ctx context.Context, infraLambda *InfraLambda, previ
 				Logger.Println("error:", err)
 				return err
 			}
-			input := &lambda.CreateEventSourceMappingInput{
-				FunctionName:                   aws.String(infraLambda.Name),
-				EventSourceArn:                 aws.String(sqsArn),
-				Enabled:                        aws.Bool(true),
-				BatchSize:                      aws.Int32(10),
-				MaximumBatchingWindowInSeconds: aws.Int32(0),
-			}
-			for _, line := range triggerAttrs {
-				attr, value, err := SplitOnce(line, "=")
-				if err != nil {
-					Logger.Println("error:", err)
-					return err
-				}
-				attr = lambdaSQSTriggerAttrShortcut(attr)
-				switch attr {
-				case "BatchSize":
-					size, err := strconv.Atoi(value)
-					if err != nil {
-						Logger.Println("error:", err)
-						return err
-					}
-					input.BatchSize = aws.Int32(int32(size))
-				case "MaximumBatchingWindowInSeconds":
-					size, err := strconv.Atoi(value)
-					if err != nil {
-						Logger.Println("error:", err)
-						return err
-					}
-					input.MaximumBatchingWindowInSeconds = aws.Int32(int32(size))
-				default:
-					err := fmt.Errorf("unknown sqs trigger attribute: %s", line)
-					Logger.Println("error:", err)
-					return err
-				}
+			input, err := lambdaSQSTriggerInput(infraLambda.Name, triggerAttrs)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
 			}
+			input.EventSourceArn = aws.String(sqsArn)
 			eventSourceMappings, err := lambdaListEventSourceMappings(ctx, infraLambda.Name)
 			if err != nil {
 				Logger.Println("error:", err)
@@ -2169,9 +4060,48 @@ func LambdaEnsureTriggerSQS(ctx context.Context, infraLambda *InfraLambda, previ
 					update.BatchSize = input.BatchSize
 					needsUpdate = true
 				}
-				if *found.MaximumBatchingWindowInSeconds != *input.MaximumBatchingWindowInSeconds {
-					Logger.Printf(PreviewString(preview)+"will update lambda event source mapping MaximumBatchingWindowInSeconds for %s %s: %d => %d\n", infraLambda.Name, queueName, *found.MaximumBatchingWindowInSeconds, *input.MaximumBatchingWindowInSeconds)
-					update.MaximumBatchingWindowInSeconds = input.MaximumBatchingWindowInSeconds
+				if *found.MaximumBatchingWindowInSeconds != *input.MaximumBatchingWindowInSeconds {
+					Logger.Printf(PreviewString(preview)+"will update lambda event source mapping MaximumBatchingWindowInSeconds for %s %s: %d => %d\n", infraLambda.Name, queueName, *found.MaximumBatchingWindowInSeconds, *input.MaximumBatchingWindowInSeconds)
+					update.MaximumBatchingWindowInSeconds = input.MaximumBatchingWindowInSeconds
+					needsUpdate = true
+				}
+				if !slices.Equal(found.FunctionResponseTypes, input.FunctionResponseTypes) {
+					Logger.Printf(PreviewString(preview)+"will update lambda event source mapping FunctionResponseTypes for %s %s: %v => %v\n", infraLambda.Name, queueName, found.FunctionResponseTypes, input.FunctionResponseTypes)
+					update.FunctionResponseTypes = append([]lambdatypes.FunctionResponseType{}, input.FunctionResponseTypes...) // empty, not nil, to remove
+					needsUpdate = true
+				}
+				foundConcurrency := int32(0)
+				if found.ScalingConfig != nil {
+					foundConcurrency = aws.ToInt32(found.ScalingConfig.MaximumConcurrency)
+				}
+				inputConcurrency := int32(0)
+				if input.ScalingConfig != nil {
+					inputConcurrency = aws.ToInt32(input.ScalingConfig.MaximumConcurrency)
+				}
+				if foundConcurrency != inputConcurrency {
+					Logger.Printf(PreviewString(preview)+"will update lambda event source mapping MaximumConcurrency for %s %s: %d => %d\n", infraLambda.Name, queueName, foundConcurrency, inputConcurrency)
+					update.ScalingConfig = &lambdatypes.ScalingConfig{}
+					if input.ScalingConfig != nil {
+						update.ScalingConfig = input.ScalingConfig
+					}
+					needsUpdate = true
+				}
+				foundPatterns, err := lambdaFilterPatterns(found.FilterCriteria)
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+				inputPatterns, err := lambdaFilterPatterns(input.FilterCriteria)
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+				if !slices.Equal(foundPatterns, inputPatterns) {
+					Logger.Printf(PreviewString(preview)+"will update lambda event source mapping FilterCriteria for %s %s: %v => %v\n", infraLambda.Name, queueName, foundPatterns, inputPatterns)
+					update.FilterCriteria = &lambdatypes.FilterCriteria{Filters: []lambdatypes.Filter{}}
+					if input.FilterCriteria != nil {
+						update.FilterCriteria = input.FilterCriteria
+					}
 					needsUpdate = true
 				}
 				if needsUpdate {
@@ -2242,40 +4172,175 @@ func lambdaUpdateZipGo(infraLambda *InfraLambda) error {
 	return lambdaCreateZipGo(infraLambda)
 }
 
+type lambdaGoBuild struct {
+	tags     []string
+	ldflags  []string
+	trimpath bool
+	cgo      bool
+	dir      string // directory to run the build in, defaults to the entrypoint's directory
+	cmd      string // custom build command, its output is the binary
+	binary   string // prebuilt binary, or the output of cmd
+}
+
+func lambdaBuildPath(infraLambda *InfraLambda, pth string) string {
+	if strings.HasPrefix(pth, "/") {
+		return pth
+	}
+	return path.Join(infraLambda.dir, pth)
+}
+
+func lambdaGoBuildInput(infraLambda *InfraLambda) (*lambdaGoBuild, error) {
+	build := &lambdaGoBuild{}
+	for _, line := range infraLambda.Build {
+		k, v, err := SplitOnce(line, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case lambdaBuildAttrTags:
+			for tag := range strings.SplitSeq(strings.ReplaceAll(v, ",", " "), " ") {
+				if tag != "" {
+					build.tags = append(build.tags, tag)
+				}
+			}
+		case lambdaBuildAttrLdflags:
+			if strings.Contains(v, "'") {
+				err := fmt.Errorf("build ldflags cannot contain single quotes: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			build.ldflags = append(build.ldflags, v)
+		case lambdaBuildAttrTrimpath:
+			val, err := strconv.ParseBool(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			build.trimpath = val
+		case lambdaBuildAttrCgo:
+			val, err := strconv.ParseBool(v)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			build.cgo = val
+		case lambdaBuildAttrDir:
+			build.dir = lambdaBuildPath(infraLambda, v)
+		case lambdaBuildAttrCmd:
+			build.cmd = v
+		case lambdaBuildAttrBinary:
+			build.binary = lambdaBuildPath(infraLambda, v)
+		default:
+			err := fmt.Errorf("unknown build attr: %s", line)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	if build.cmd != "" && build.binary == "" {
+		err := fmt.Errorf("build cmd requires binary=PATH to locate its output: %s", infraLambda.Name)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if build.binary != "" && (len(build.tags) > 0 || len(build.ldflags) > 0 || build.trimpath || build.cgo) {
+		err := fmt.Errorf("build binary cannot be combined with go build flags: %s", infraLambda.Name)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if build.dir == "" {
+		build.dir = path.Dir(infraLambda.Entrypoint)
+	}
+	return build, nil
+}
+
 func lambdaCreateZipGo(infraLambda *InfraLambda) error {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "lambdaCreateZipGo"}
 		d.Start()
 		defer d.End()
 	}
-	zipFile := LambdaZipFile(infraLambda.Name)
-	dir := path.Dir(zipFile)
-	err := os.RemoveAll(dir)
+	build, err := lambdaGoBuildInput(infraLambda)
 	if err != nil {
 		Logger.Println("error:", err)
 		return err
 	}
-	_ = os.MkdirAll(dir, os.ModePerm)
-	prefix := ""
-	ldflags := os.Getenv("LDFLAGS")
-	if ldflags != " " {
-		prefix = " " // ldflags might contain secrets, shellAt() logs cmdString on error unless it starts with whitespace
-	}
-	err = shellAt(path.Dir(infraLambda.Entrypoint), "%sCGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -ldflags='-s -w %s' -tags 'netgo osusergo purego' -o %s %s",
-		prefix,
-		ldflags,
-		path.Join(dir, "bootstrap"),
-		path.Base(infraLambda.Entrypoint),
-	)
+	zipFile := LambdaZipFile(infraLambda.Name)
+	dir := path.Dir(zipFile)
+	err = os.RemoveAll(dir)
 	if err != nil {
 		Logger.Println("error:", err)
 		return err
 	}
+	_ = os.MkdirAll(dir, os.ModePerm)
+	bootstrap := path.Join(dir, "bootstrap")
+	if build.binary != "" {
+		if build.cmd != "" {
+			err := shellAt(build.dir, "%s", build.cmd)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		if !Exists(build.binary) {
+			err := fmt.Errorf("no such build binary: %s", build.binary)
+			Logger.Println("error:", err)
+			return err
+		}
+		err := shellAt(dir, "cp -L %s %s && chmod +x %s", shellQuote(build.binary), shellQuote(bootstrap), shellQuote(bootstrap))
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	} else {
+		prefix := ""
+		ldflags := os.Getenv("LDFLAGS")
+		if ldflags != " " {
+			prefix = " " // ldflags might contain secrets, shellAt() logs cmdString on error unless it starts with whitespace
+		}
+		ldflags = strings.TrimSpace(strings.Join(append(build.ldflags, ldflags), " "))
+		cgo := 0
+		if build.cgo {
+			cgo = 1
+		}
+		flags := ""
+		if build.trimpath {
+			flags = "-trimpath "
+		}
+		tags := append([]string{"netgo", "osusergo", "purego"}, build.tags...)
+		src, err := filepath.Rel(build.dir, infraLambda.Entrypoint)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if strings.HasPrefix(src, "..") {
+			err := fmt.Errorf("build dir must contain the entrypoint: %s %s", build.dir, infraLambda.Entrypoint)
+			Logger.Println("error:", err)
+			return err
+		}
+		goarch := "amd64"
+		if infraLambda.arch == string(lambdatypes.ArchitectureArm64) {
+			goarch = "arm64"
+		}
+		err = shellAt(build.dir, "%sCGO_ENABLED=%d GOOS=linux GOARCH=%s go build %s-ldflags='-s -w %s' -tags '%s' -o %s %s",
+			prefix,
+			cgo,
+			goarch,
+			flags,
+			ldflags,
+			strings.Join(tags, " "),
+			shellQuote(bootstrap),
+			shellQuote("./"+src),
+		)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
 	compression := "-9"
 	if os.Getenv("ZIP_COMPRESSION") != "" {
 		compression = "-" + os.Getenv("ZIP_COMPRESSION")
 	}
-	err = shellAt(dir, "zip %s %s ./bootstrap", compression, zipFile)
+	err = shellAt(dir, "zip %s %s ./bootstrap", compression, shellQuote(zipFile))
 	if err != nil {
 		Logger.Println("error:", err)
 		return err
@@ -2283,68 +4348,227 @@ func lambdaCreateZipGo(infraLambda *InfraLambda) error {
 	return nil
 }
 
-func lambdaCreateZipPy(infraLambda *InfraLambda) error {
-	if doDebug {
-		d := &Debug{start: time.Now(), name: "lambdaCreateZipPy"}
-		d.Start()
-		defer d.End()
+// lambdaPyLockfile returns the path of a require entry which is a
+// requirements.txt, uv.lock or poetry.lock, or empty if it is a pip spec
+func lambdaPyLockfile(infraLambda *InfraLambda, require string) string {
+	base := path.Base(require)
+	if strings.HasSuffix(base, ".txt") || base == "uv.lock" || base == "poetry.lock" {
+		return lambdaBuildPath(infraLambda, require)
+	}
+	return ""
+}
+
+// lambdaPyCacheKey hashes everything that determines the installed
+// dependencies, so that lambdas with the same requirements share a cache
+func lambdaPyCacheKey(infraLambda *InfraLambda) (string, error) {
+	lines := []string{infraLambda.runtime, infraLambda.arch}
+	for _, require := range infraLambda.Require {
+		lockfile := lambdaPyLockfile(infraLambda, require)
+		if lockfile == "" {
+			lines = append(lines, require)
+			continue
+		}
+		data, err := os.ReadFile(lockfile)
+		if err != nil {
+			Logger.Println("error:", err)
+			return "", err
+		}
+		lines = append(lines, path.Base(lockfile)+"="+sha256Hex(data))
 	}
-	zipFile := LambdaZipFile(infraLambda.Name)
-	dir := path.Dir(zipFile)
-	err := os.RemoveAll(dir)
+	return sha256Hex([]byte(strings.Join(lines, "\n")))[:32], nil
+}
+
+func lambdaPyCacheDir() (string, error) {
+	dir := os.Getenv("LIBAWS_CACHE_DIR")
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			Logger.Println("error:", err)
+			return "", err
+		}
+		dir = path.Join(cache, "libaws")
+	}
+	return path.Join(dir, "python"), nil
+}
+
+const lambdaPyCacheDays = 30
+
+// lambdaPyCachePrune removes cached deps that have not been used for maxAge.
+// entries are touched on every use, so the least recently used go first.
+func lambdaPyCachePrune(cacheDir string, maxAge time.Duration) error {
+	entries, err := os.ReadDir(cacheDir)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		Logger.Println("error:", err)
 		return err
 	}
-	_ = os.MkdirAll(dir, os.ModePerm)
-	err = shell("virtualenv --python python3 %s/env", dir)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue // removed concurrently
+		}
+		if time.Since(info.ModTime()) < maxAge {
+			continue
+		}
+		err = os.RemoveAll(path.Join(cacheDir, entry.Name()))
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		Logger.Println("pruned cached python deps:", entry.Name())
+	}
+	return nil
+}
+
+// lambdaPyInstall installs requirements into a directory shared between
+// lambdas, keyed by the hash of the requirements, python version and arch.
+// only manylinux wheels for the target arch are used, so native deps built
+// on the local machine never end up in the zip.
+func lambdaPyInstall(infraLambda *InfraLambda) (string, error) {
+	key, err := lambdaPyCacheKey(infraLambda)
 	if err != nil {
 		Logger.Println("error:", err)
-		return err
+		return "", err
 	}
-	if len(infraLambda.Require) > 0 {
-		var args []string
-		for _, require := range infraLambda.Require {
-			args = append(args, fmt.Sprintf(`"%s"`, require))
+	cacheDir, err := lambdaPyCacheDir()
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	target := path.Join(cacheDir, key)
+	if Exists(target) {
+		now := time.Now()
+		err := os.Chtimes(target, now, now)
+		if err != nil {
+			Logger.Println("error:", err)
+			return "", err
 		}
-		arg := strings.Join(args, " ")
-		err = shell("%s/env/bin/pip install %s", dir, arg)
+	}
+	days := lambdaPyCacheDays
+	if os.Getenv("LIBAWS_CACHE_DAYS") != "" {
+		days, err = strconv.Atoi(os.Getenv("LIBAWS_CACHE_DAYS"))
 		if err != nil {
 			Logger.Println("error:", err)
-			return err
+			return "", err
 		}
 	}
-	site_packages, err := filepath.Glob(fmt.Sprintf("%s/env/lib/python3*/site-packages", dir))
+	err = lambdaPyCachePrune(cacheDir, time.Duration(days)*24*time.Hour)
 	if err != nil {
 		Logger.Println("error:", err)
-		return err
+		return "", err
+	}
+	if Exists(target) {
+		Logger.Println("using cached python deps:", infraLambda.Name, target)
+		return target, nil
 	}
-	if len(site_packages) != 1 {
-		err := fmt.Errorf("expected 1 site-package dir: %v", site_packages)
+	tmp := fmt.Sprintf("%s.tmp.%d", target, os.Getpid())
+	_ = os.RemoveAll(tmp)
+	err = os.MkdirAll(tmp, os.ModePerm)
+	if err != nil {
 		Logger.Println("error:", err)
-		return err
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+	var args []string
+	for i, require := range infraLambda.Require {
+		lockfile := lambdaPyLockfile(infraLambda, require)
+		if lockfile == "" {
+			args = append(args, shellQuote(require))
+			continue
+		}
+		if !Exists(lockfile) {
+			err := fmt.Errorf("no such path for require: %s", lockfile)
+			Logger.Println("error:", err)
+			return "", err
+		}
+		requirements := lockfile
+		switch path.Base(lockfile) {
+		case "uv.lock":
+			requirements = fmt.Sprintf("%s/requirements-%d.txt", tmp, i)
+			err := shellAt(path.Dir(lockfile), "uv export --frozen --no-dev --no-emit-project --format requirements-txt --output-file %s", shellQuote(requirements))
+			if err != nil {
+				Logger.Println("error:", err)
+				return "", err
+			}
+		case "poetry.lock":
+			requirements = fmt.Sprintf("%s/requirements-%d.txt", tmp, i)
+			err := shellAt(path.Dir(lockfile), "poetry export --format requirements.txt --output %s", shellQuote(requirements))
+			if err != nil {
+				Logger.Println("error:", err)
+				return "", err
+			}
+		}
+		args = append(args, "-r "+shellQuote(requirements))
 	}
-	site_package := site_packages[0]
-	err = shellAt(site_package, "cp %s .", infraLambda.Entrypoint)
+	platform := "x86_64"
+	if infraLambda.arch == string(lambdatypes.ArchitectureArm64) {
+		platform = "aarch64"
+	}
+	site := path.Join(tmp, "site")
+	err = shell("python3 -m pip install --no-cache-dir --disable-pip-version-check --only-binary=:all: --implementation cp --python-version %s --platform manylinux2014_%s --platform manylinux_2_28_%s --target %s %s",
+		strings.TrimPrefix(infraLambda.runtime, "python"),
+		platform,
+		platform,
+		shellQuote(site),
+		strings.Join(args, " "),
+	)
 	if err != nil {
 		Logger.Println("error:", err)
-		return err
+		return "", err
 	}
-	err = shellAt(site_package, "rm -rf wheel pip setuptools pkg_resources easy_install.py")
+	err = shellAt(site, "rm -rf bin; ls | grep -E 'info$' | grep -v ' ' | xargs rm -rf")
 	if err != nil {
 		Logger.Println("error:", err)
-		return err
+		return "", err
 	}
-	err = shellAt(site_package, "ls | grep -E 'info$' | grep -v ' ' | xargs rm -rf")
+	err = os.Rename(site, target)
+	if err != nil && !Exists(target) { // another deploy may have populated the cache concurrently
+		Logger.Println("error:", err)
+		return "", err
+	}
+	return target, nil
+}
+
+func lambdaCreateZipPy(infraLambda *InfraLambda) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "lambdaCreateZipPy"}
+		d.Start()
+		defer d.End()
+	}
+	zipFile := LambdaZipFile(infraLambda.Name)
+	dir := path.Dir(zipFile)
+	err := os.RemoveAll(dir)
 	if err != nil {
 		Logger.Println("error:", err)
 		return err
 	}
+	_ = os.MkdirAll(dir, os.ModePerm)
 	compression := "-9"
 	if os.Getenv("ZIP_COMPRESSION") != "" {
 		compression = "-" + os.Getenv("ZIP_COMPRESSION")
 	}
-	err = shellAt(site_package, "zip %s -r %s .", compression, zipFile)
+	if len(infraLambda.Require) > 0 {
+		site, err := lambdaPyInstall(infraLambda)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		entries, err := os.ReadDir(site)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if len(entries) > 0 {
+			err = shellAt(site, "zip %s -r %s .", compression, shellQuote(zipFile))
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+	}
+	err = shell("zip %s --junk-paths %s %s", compression, zipFile, infraLambda.Entrypoint)
 	if err != nil {
 		Logger.Println("error:", err)
 		return err
@@ -2425,28 +4649,14 @@ func lambdaUpdateZipPy(infraLambda *InfraLambda) error {
 		defer d.End()
 	}
 	zipFile := LambdaZipFile(infraLambda.Name)
-	dir := path.Dir(zipFile)
-	site_packages, err := filepath.Glob(fmt.Sprintf("%s/env/lib/python3*/site-packages", dir))
-	if err != nil {
-		Logger.Println("error:", err)
-		return err
-	}
-	if len(site_packages) != 1 {
-		err := fmt.Errorf("expected 1 site-package dir: %v", site_packages)
-		Logger.Println("error:", err)
-		return err
-	}
-	site_package := site_packages[0]
-	err = shellAt(site_package, "cp %s .", infraLambda.Entrypoint)
-	if err != nil {
-		Logger.Println("error:", err)
-		return err
+	if !Exists(zipFile) { // deps come from the cache, so building from scratch is still fast
+		return lambdaCreateZipPy(infraLambda)
 	}
 	compression := "-9"
 	if os.Getenv("ZIP_COMPRESSION") != "" {
 		compression = "-" + os.Getenv("ZIP_COMPRESSION")
 	}
-	err = shellAt(site_package, "zip %s %s %s", compression, zipFile, path.Base(infraLambda.Entrypoint))
+	err := shell("zip %s --junk-paths %s %s", compression, zipFile, infraLambda.Entrypoint)
 	if err != nil {
 		Logger.Println("error:", err)
 		return err
@@ -2510,14 +4720,20 @@ func lambdaEnsure(ctx context.Context, infraLambda *InfraLambda, quick, preview,
 			timeout = Atoi(v)
 		case lambdaAttrLogsTTLDays:
 			logsTTLDays = Atoi(v)
+		case lambdaAttrArch:
+			infraLambda.arch = v
+		case lambdaAttrPython:
+			infraLambda.runtime = "python" + v
 		default:
 			err := fmt.Errorf("unknown attr: %s", k)
 			Logger.Println("error:", err)
 			return err
 		}
 	}
-	zipFile := LambdaZipFile(infraLambda.Name)
-	if quick && !(infraLambda.runtime == lambdaRuntimePython && !Exists(zipFile)) { // python requires existing zip for quick, since it only adds source instead of rebuilding the virtualenv, which is way faster
+	if infraLambda.arch == "" {
+		infraLambda.arch = lambdaAttrArchDefault
+	}
+	if quick {
 		err = updateZipFn(infraLambda)
 		if err != nil {
 			Logger.Println("error:", err)
@@ -2598,13 +4814,14 @@ func lambdaEnsure(ctx context.Context, infraLambda *InfraLambda, quick, preview,
 		getFunctionOut = &lambda.GetFunctionOutput{}
 	}
 	createInput := &lambda.CreateFunctionInput{
-		FunctionName: aws.String(infraLambda.Name),
-		Timeout:      aws.Int32(int32(timeout)),
-		MemorySize:   aws.Int32(int32(memory)),
-		Role:         aws.String(arnRole),
-		Code:         &lambdatypes.FunctionCode{},
-		Environment:  &lambdatypes.Environment{Variables: map[string]string{}},
-		Tags:         map[string]string{infraSetTagName: infraLambda.infraSetName},
+		FunctionName:  aws.String(infraLambda.Name),
+		Timeout:       aws.Int32(int32(timeout)),
+		MemorySize:    aws.Int32(int32(memory)),
+		Role:          aws.String(arnRole),
+		Code:          &lambdatypes.FunctionCode{},
+		Environment:   &lambdatypes.Environment{Variables: map[string]string{}},
+		Tags:          map[string]string{infraSetTagName: infraLambda.infraSetName},
+		Architectures: []lambdatypes.Architecture{lambdatypes.Architecture(infraLambda.arch)},
 	}
 	for _, val := range infraLambda.Env {
 		k, v, err := SplitOnce(val, "=")
@@ -2718,6 +4935,16 @@ func lambdaEnsure(ctx context.Context, infraLambda *InfraLambda, quick, preview,
 				return err
 			}
 		}
+		if getFunctionOut.Configuration != nil && len(getFunctionOut.Configuration.Architectures) > 0 { // arch can only be changed by updating code
+			existing := map[string]string{"arch": string(getFunctionOut.Configuration.Architectures[0])}
+			new := map[string]string{"arch": infraLambda.arch}
+			archDiff, err := diffMapStringString(new, existing, PreviewString(preview), true)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			diff = diff || archDiff
+		}
 		if diff {
 			err := LambdaUpdateFunctionCode(ctx, infraLambda, preview)
 			if err != nil {
@@ -2769,17 +4996,25 @@ func lambdaEnsure(ctx context.Context, infraLambda *InfraLambda, quick, preview,
 			needsUpdate = true
 			Logger.Printf(PreviewString(preview)+"update memory: %d => %d\n", *outConf.MemorySize, memory)
 		}
+		if infraLambda.runtime != lambdaRuntimeContainer && string(outConf.Runtime) != infraLambda.runtime {
+			needsUpdate = true
+			Logger.Printf(PreviewString(preview)+"update runtime: %s => %s\n", outConf.Runtime, infraLambda.runtime)
+		}
 		if needsUpdate {
 			if !preview {
 				err := Retry(ctx, func() error {
-					_, err := LambdaClient().UpdateFunctionConfiguration(ctx, &lambda.UpdateFunctionConfigurationInput{
+					input := &lambda.UpdateFunctionConfigurationInput{
 						FunctionName: aws.String(infraLambda.Name),
 						Timeout:      aws.Int32(int32(timeout)),
 						MemorySize:   aws.Int32(int32(memory)),
 						Environment: &lambdatypes.Environment{
 							Variables: createInput.Environment.Variables,
 						},
-					})
+					}
+					if infraLambda.runtime != lambdaRuntimeContainer {
+						input.Runtime = lambdatypes.Runtime(infraLambda.runtime)
+					}
+					_, err := LambdaClient().UpdateFunctionConfiguration(ctx, input)
 					return err
 				})
 				if err != nil {
@@ -2800,6 +5035,12 @@ func lambdaEnsure(ctx context.Context, infraLambda *InfraLambda, quick, preview,
 		return err
 	}
 	permissionSids = append(permissionSids, sids...)
+	sids, err = lambdaEnsureApiPermissions(ctx, infraLambda, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	permissionSids = append(permissionSids, sids...)
 	err = IamEnsureRoleAllows(ctx, infraLambda.Name, infraLambda.Allow, preview) // ensure role allows after api trigger because it defines $API_ID and WEBSOCKET_ID
 	if err != nil {
 		Logger.Println("error:", err)
@@ -2810,6 +5051,11 @@ func lambdaEnsure(ctx context.Context, infraLambda *InfraLambda, quick, preview,
 		Logger.Println("error:", err)
 		return err
 	}
+	err = LambdaEnsureTriggerKinesis(ctx, infraLambda, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
 	sids, err = LambdaEnsureTriggerSchedule(ctx, infraLambda, preview)
 	if err != nil {
 		Logger.Println("error:", err)
@@ -2828,6 +5074,18 @@ func lambdaEnsure(ctx context.Context, infraLambda *InfraLambda, quick, preview,
 		return err
 	}
 	permissionSids = append(permissionSids, sids...)
+	sids, err = LambdaEnsureTriggerEvent(ctx, infraLambda, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	permissionSids = append(permissionSids, sids...)
+	sids, err = LambdaEnsureTriggerSNS(ctx, infraLambda, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	permissionSids = append(permissionSids, sids...)
 	sids, err = LambdaEnsureTriggerURL(ctx, infraLambda, preview)
 	if err != nil {
 		Logger.Println("error:", err)
@@ -2884,6 +5142,9 @@ func LambdaUpdateFunctionCode(ctx context.Context, infraLambda *InfraLambda, pre
 			} else {
 				updateInput.ZipFile = zipBytes
 			}
+			if infraLambda.arch != "" {
+				updateInput.Architectures = []lambdatypes.Architecture{lambdatypes.Architecture(infraLambda.arch)}
+			}
 			_, err := LambdaClient().UpdateFunctionCode(ctx, updateInput)
 			if err != nil {
 				var notFound *lambdatypes.ResourceNotFoundException
@@ -2988,6 +5249,16 @@ func LambdaDelete(ctx context.Context, name string, preview bool) error {
 				Logger.Println("error:", err)
 				return err
 			}
+			_, err = LambdaEnsureTriggerEvent(ctx, infraLambda, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			_, err = LambdaEnsureTriggerSNS(ctx, infraLambda, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
 			_, err = LambdaEnsureTriggerSchedule(ctx, infraLambda, preview)
 			if err != nil {
 				Logger.Println("error:", err)
@@ -2998,6 +5269,11 @@ func LambdaDelete(ctx context.Context, name string, preview bool) error {
 				Logger.Println("error:", err)
 				return err
 			}
+			err = LambdaEnsureTriggerKinesis(ctx, infraLambda, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
 			err = LambdaEnsureTriggerSQS(ctx, infraLambda, preview)
 			if err != nil {
 				Logger.Println("error:", err)
@@ -3036,3 +5312,21 @@ func FuncUrl(ctx context.Context, lambdaName string) (string, error) {
 	}
 	return strings.Trim(*out.FunctionUrl, "/"), nil
 }
+
+// LambdaUrlSign signs a request to a function url with sigv4, which is
+// required to invoke urls with auth=iam
+func LambdaUrlSign(ctx context.Context, req *http.Request, body []byte) error {
+	creds, err := Session().Credentials.Retrieve(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	hash := sha256Hex(body)
+	req.Header.Set("X-Amz-Content-Sha256", hash)
+	err = v4.NewSigner().SignHTTP(ctx, creds, req, hash, "lambda", Region(), time.Now())
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	return nil
+}
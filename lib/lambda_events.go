@@ -1,10 +1,14 @@
 package lib
 
 import (
+	"context"
 	"fmt"
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"reflect"
 	"slices"
+	"strings"
 )
 
 func FromDynamoDBEventAVList(from []events.DynamoDBAttributeValue) (to []ddbtypes.AttributeValue, err error) {
@@ -67,3 +71,186 @@ func FromDynamoDBEventAVMap(from map[string]events.DynamoDBAttributeValue) (to m
 	}
 	return to, nil
 }
+
+// DynamoDBStreamRecord is a stream record with its images decoded into T using
+// dynamodbav struct tags. New is nil for REMOVE and Old is nil for INSERT, and
+// either may be nil when the stream view type does not include it.
+type DynamoDBStreamRecord[T any] struct {
+	EventName events.DynamoDBOperationType // INSERT | MODIFY | REMOVE
+	Keys      map[string]ddbtypes.AttributeValue
+	New       *T
+	Old       *T
+	Changed   []string // sorted names of top level attributes that differ between old and new images, nil when unknown
+	Record    events.DynamoDBEventRecord
+}
+
+// HasChanged reports whether any of the named attributes changed
+func (r *DynamoDBStreamRecord[T]) HasChanged(names ...string) bool {
+	for _, name := range names {
+		if slices.Contains(r.Changed, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// DynamoDBStreamRecords decodes every record of a dynamodb stream event
+func DynamoDBStreamRecords[T any](event events.DynamoDBEvent) ([]DynamoDBStreamRecord[T], error) {
+	var records []DynamoDBStreamRecord[T]
+	for _, record := range event.Records {
+		decoded, err := DynamoDBStreamDecode[T](record)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *decoded)
+	}
+	return records, nil
+}
+
+// DynamoDBStreamDecode decodes the keys and images of a dynamodb stream record
+func DynamoDBStreamDecode[T any](record events.DynamoDBEventRecord) (*DynamoDBStreamRecord[T], error) {
+	keys, err := FromDynamoDBEventAVMap(record.Change.Keys)
+	if err != nil {
+		return nil, err
+	}
+	oldImage, err := FromDynamoDBEventAVMap(record.Change.OldImage)
+	if err != nil {
+		return nil, err
+	}
+	newImage, err := FromDynamoDBEventAVMap(record.Change.NewImage)
+	if err != nil {
+		return nil, err
+	}
+	decoded := &DynamoDBStreamRecord[T]{
+		EventName: events.DynamoDBOperationType(record.EventName),
+		Keys:      keys,
+		Record:    record,
+	}
+	if len(oldImage) != 0 {
+		decoded.Old = new(T)
+		err = attributevalue.UnmarshalMap(oldImage, decoded.Old)
+		if err != nil {
+			return nil, fmt.Errorf("decode old image %s: %w", record.EventID, err)
+		}
+	}
+	if len(newImage) != 0 {
+		decoded.New = new(T)
+		err = attributevalue.UnmarshalMap(newImage, decoded.New)
+		if err != nil {
+			return nil, fmt.Errorf("decode new image %s: %w", record.EventID, err)
+		}
+	}
+	// with only one image of a MODIFY, as with stream view types KEYS_ONLY,
+	// NEW_IMAGE and OLD_IMAGE, the changed fields are unknown and left nil
+	switch {
+	case len(oldImage) != 0 && len(newImage) != 0,
+		decoded.EventName == events.DynamoDBOperationTypeInsert && len(newImage) != 0,
+		decoded.EventName == events.DynamoDBOperationTypeRemove && len(oldImage) != 0:
+		decoded.Changed = DynamoDBChangedFields(oldImage, newImage)
+	}
+	return decoded, nil
+}
+
+// DynamoDBChangedFields returns the sorted names of top level attributes which
+// were added, removed or modified between two images. a missing image counts
+// as empty, so only pass nil for an insert or remove. sets are compared
+// without regard to element order.
+func DynamoDBChangedFields(oldImage, newImage map[string]ddbtypes.AttributeValue) []string {
+	changed := []string{}
+	for name, value := range newImage {
+		oldValue, ok := oldImage[name]
+		if !ok || !dynamoDBAttributeValueEqual(oldValue, value) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range oldImage {
+		_, ok := newImage[name]
+		if !ok {
+			changed = append(changed, name)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// dynamoDBAttributeValueEqual compares attribute values, ignoring the order of
+// set elements at any depth
+func dynamoDBAttributeValueEqual(a, b ddbtypes.AttributeValue) bool {
+	switch a := a.(type) {
+	case *ddbtypes.AttributeValueMemberSS:
+		b, ok := b.(*ddbtypes.AttributeValueMemberSS)
+		return ok && dynamoDBSetEqual(a.Value, b.Value)
+	case *ddbtypes.AttributeValueMemberNS:
+		b, ok := b.(*ddbtypes.AttributeValueMemberNS)
+		return ok && dynamoDBSetEqual(a.Value, b.Value)
+	case *ddbtypes.AttributeValueMemberBS:
+		b, ok := b.(*ddbtypes.AttributeValueMemberBS)
+		if !ok {
+			return false
+		}
+		var as, bs []string
+		for _, v := range a.Value {
+			as = append(as, string(v))
+		}
+		for _, v := range b.Value {
+			bs = append(bs, string(v))
+		}
+		return dynamoDBSetEqual(as, bs)
+	case *ddbtypes.AttributeValueMemberL:
+		b, ok := b.(*ddbtypes.AttributeValueMemberL)
+		if !ok || len(a.Value) != len(b.Value) {
+			return false
+		}
+		for i := range a.Value {
+			if !dynamoDBAttributeValueEqual(a.Value[i], b.Value[i]) {
+				return false
+			}
+		}
+		return true
+	case *ddbtypes.AttributeValueMemberM:
+		b, ok := b.(*ddbtypes.AttributeValueMemberM)
+		if !ok || len(a.Value) != len(b.Value) {
+			return false
+		}
+		for k, v := range a.Value {
+			bv, ok := b.Value[k]
+			if !ok || !dynamoDBAttributeValueEqual(v, bv) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+func dynamoDBSetEqual(a, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// SQSBatchItemFailures runs handler for each message in order and returns the
+// response for a sqs trigger with partial=true, reporting the messages whose
+// handler returned an error as failed so that only they are retried. For fifo
+// queues, processing stops at the first failure and every remaining message is
+// reported as failed, which preserves ordering within message groups.
+func SQSBatchItemFailures(ctx context.Context, event events.SQSEvent, handler func(context.Context, events.SQSMessage) error) events.SQSEventResponse {
+	response := events.SQSEventResponse{BatchItemFailures: []events.SQSBatchItemFailure{}}
+	failed := false
+	for _, message := range event.Records {
+		if failed && strings.HasSuffix(message.EventSourceARN, sqsFifoSuffix) {
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+			continue
+		}
+		err := handler(ctx, message)
+		if err != nil {
+			Logger.Println("error:", message.MessageId, err)
+			response.BatchItemFailures = append(response.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: message.MessageId})
+			failed = true
+		}
+	}
+	return response
+}
@@ -0,0 +1,659 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apigatewayv2"
+	apitypes "github.com/aws/aws-sdk-go-v2/service/apigatewayv2/types"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestLambdaGoBuildInput(t *testing.T) {
+	type test struct {
+		build  []string
+		output *lambdaGoBuild
+		err    bool
+	}
+	tests := []test{
+		{nil, &lambdaGoBuild{dir: "/repo/svc"}, false},
+		{[]string{"tags=foo,bar", "tags=baz"}, &lambdaGoBuild{dir: "/repo/svc", tags: []string{"foo", "bar", "baz"}}, false},
+		{[]string{"ldflags=-X main.version=1.2.3", "trimpath=true", "cgo=true"}, &lambdaGoBuild{dir: "/repo/svc", ldflags: []string{"-X main.version=1.2.3"}, trimpath: true, cgo: true}, false},
+		{[]string{"dir=.."}, &lambdaGoBuild{dir: "/repo"}, false},
+		{[]string{"binary=/out/bootstrap"}, &lambdaGoBuild{dir: "/repo/svc", binary: "/out/bootstrap"}, false},
+		{[]string{"cmd=make", "binary=bin/bootstrap"}, &lambdaGoBuild{dir: "/repo/svc", cmd: "make", binary: "/repo/svc/bin/bootstrap"}, false},
+		{[]string{"cmd=make"}, nil, true},
+		{[]string{"binary=bootstrap", "trimpath=true"}, nil, true},
+		{[]string{"ldflags=-X 'main.version=1'"}, nil, true},
+		{[]string{"trimpath=yes"}, nil, true},
+		{[]string{"unknown=1"}, nil, true},
+	}
+	for _, test := range tests {
+		infraLambda := &InfraLambda{
+			dir:        "/repo/svc",
+			Entrypoint: "/repo/svc/main.go",
+			Build:      test.build,
+		}
+		output, err := lambdaGoBuildInput(infraLambda)
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error for: %v", test.build)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nunexpected error: %s", err)
+			continue
+		}
+		if !reflect.DeepEqual(output, test.output) {
+			t.Errorf("\ngot:\n%#v\nwant:\n%#v\n", output, test.output)
+		}
+	}
+}
+
+func TestLambdaPyCacheKey(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(path.Join(dir, "uv.lock"), []byte("version = 1\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func(runtime, arch string, require ...string) string {
+		k, err := lambdaPyCacheKey(&InfraLambda{dir: dir, runtime: runtime, arch: arch, Require: require})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	base := key("python3.13", "x86_64", "uv.lock", "requests==2.32.3")
+	if base != key("python3.13", "x86_64", "uv.lock", "requests==2.32.3") {
+		t.Errorf("\nexpected stable key")
+	}
+	if base == key("python3.12", "x86_64", "uv.lock", "requests==2.32.3") {
+		t.Errorf("\nexpected key to depend on python version")
+	}
+	if base == key("python3.13", "arm64", "uv.lock", "requests==2.32.3") {
+		t.Errorf("\nexpected key to depend on arch")
+	}
+	err = os.WriteFile(path.Join(dir, "uv.lock"), []byte("version = 2\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base == key("python3.13", "x86_64", "uv.lock", "requests==2.32.3") {
+		t.Errorf("\nexpected key to depend on lockfile contents")
+	}
+	_, err = lambdaPyCacheKey(&InfraLambda{dir: dir, Require: []string{"missing/requirements.txt"}})
+	if err == nil {
+		t.Errorf("\nexpected error for missing lockfile")
+	}
+}
+
+func TestLambdaUrlConfigInput(t *testing.T) {
+	attrs := []string{"auth=iam", "invoke-mode=buffered", "cors-origin=https://example.com", "cors-method=GET", "cors-method=POST", "cors-header=content-type", "cors-max-age=300"}
+	conf, err := lambdaUrlConfigInput(&InfraTrigger{Type: lambdaTriggerUrl, Attr: attrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := lambdaUrlConfigAttrs(conf.authType, conf.invokeMode, conf.cors)
+	if !reflect.DeepEqual(output, attrs) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", output, attrs)
+	}
+	conf, err = lambdaUrlConfigInput(&InfraTrigger{Type: lambdaTriggerUrl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.cors != nil || len(lambdaUrlConfigAttrs(conf.authType, conf.invokeMode, conf.cors)) != 0 {
+		t.Errorf("\nexpected defaults")
+	}
+	for _, attr := range []string{"auth=sso", "invoke-mode=fast", "cors-max-age=ten", "unknown=1"} {
+		_, err := lambdaUrlConfigInput(&InfraTrigger{Type: lambdaTriggerUrl, Attr: []string{attr}})
+		if err == nil {
+			t.Errorf("\nexpected error for: %s", attr)
+		}
+	}
+}
+
+func TestLambdaApiAuthInput(t *testing.T) {
+	type test struct {
+		triggerType string
+		attr        []string
+		output      *lambdaApiAuth
+		err         bool
+	}
+	tests := []test{
+		{lambdaTriggerApi, []string{"dns=api.example.com"}, nil, false},
+		{lambdaTriggerApi, []string{"jwt-issuer=https://example.auth0.com/", "jwt-audience=api"}, &lambdaApiAuth{authorizerType: apitypes.AuthorizerTypeJwt, issuer: "https://example.auth0.com/", audience: []string{"api"}, identity: []string{"$request.header.Authorization"}}, false},
+		{lambdaTriggerApi, []string{"authorizer=auth", "authorizer-ttl=60", "authorizer-identity=$request.header.X-Token"}, &lambdaApiAuth{authorizerType: apitypes.AuthorizerTypeRequest, lambdaName: "auth", ttl: 60, identity: []string{"$request.header.X-Token"}}, false},
+		{lambdaTriggerWebsocket, []string{"authorizer=auth"}, &lambdaApiAuth{authorizerType: apitypes.AuthorizerTypeRequest, lambdaName: "auth", identity: []string{"route.request.header.Authorization"}}, false},
+		{lambdaTriggerWebsocket, []string{"jwt-issuer=https://example.auth0.com/", "jwt-audience=api"}, nil, true},
+		{lambdaTriggerWebsocket, []string{"authorizer=auth", "authorizer-ttl=60"}, nil, true},
+		{lambdaTriggerApi, []string{"jwt-issuer=https://example.auth0.com/"}, nil, true},
+		{lambdaTriggerApi, []string{"jwt-issuer=https://example.auth0.com/", "jwt-audience=api", "authorizer=auth"}, nil, true},
+		{lambdaTriggerApi, []string{"authorizer-ttl=60"}, nil, true},
+	}
+	for _, test := range tests {
+		output, err := lambdaApiAuthInput(&InfraTrigger{Type: test.triggerType, Attr: test.attr})
+		if test.err {
+			if err == nil {
+				t.Errorf("\nexpected error for: %v", test.attr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("\nunexpected error: %s", err)
+			continue
+		}
+		if !reflect.DeepEqual(output, test.output) {
+			t.Errorf("\ngot:\n%#v\nwant:\n%#v\n", output, test.output)
+		}
+	}
+}
+
+func TestLambdaApiRouteAuthUpdate(t *testing.T) {
+	type test struct {
+		route             apitypes.Route
+		authorizationType apitypes.AuthorizationType
+		authorizerId      *string
+		output            *apigatewayv2.UpdateRouteInput
+	}
+	tests := []test{
+		{
+			apitypes.Route{RouteId: aws.String("r1"), AuthorizationType: apitypes.AuthorizationTypeNone},
+			apitypes.AuthorizationTypeNone,
+			nil,
+			nil,
+		},
+		{
+			apitypes.Route{RouteId: aws.String("r1"), AuthorizationType: apitypes.AuthorizationTypeJwt, AuthorizerId: aws.String("a1")},
+			apitypes.AuthorizationTypeJwt,
+			aws.String("a1"),
+			nil,
+		},
+		{
+			apitypes.Route{RouteId: aws.String("r1"), AuthorizationType: apitypes.AuthorizationTypeNone},
+			apitypes.AuthorizationTypeJwt,
+			aws.String("a1"),
+			&apigatewayv2.UpdateRouteInput{RouteId: aws.String("r1"), AuthorizationType: apitypes.AuthorizationTypeJwt, AuthorizerId: aws.String("a1")},
+		},
+		{
+			apitypes.Route{RouteId: aws.String("r1"), AuthorizationType: apitypes.AuthorizationTypeJwt, AuthorizerId: aws.String("a1")},
+			apitypes.AuthorizationTypeNone,
+			nil,
+			&apigatewayv2.UpdateRouteInput{RouteId: aws.String("r1"), AuthorizationType: apitypes.AuthorizationTypeNone, AuthorizerId: aws.String("")},
+		},
+	}
+	for _, test := range tests {
+		output := lambdaApiRouteAuthUpdate(test.route, test.authorizationType, test.authorizerId)
+		if !reflect.DeepEqual(output, test.output) {
+			t.Errorf("\ngot:\n%s\nwant:\n%s\n", Pformat(output), Pformat(test.output))
+		}
+	}
+}
+
+func TestLambdaApiStageInput(t *testing.T) {
+	attrs := []string{"throttle-rate=10.5", "throttle-burst=20", "access-logs=true", "detailed-metrics=true"}
+	stage, err := lambdaApiStageInput(&InfraTrigger{Type: lambdaTriggerApi, Attr: append(attrs, "access-logs-ttl-days=30", "dns=api.example.com")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stage.accessLogsTTLDays != 30 {
+		t.Errorf("\nexpected ttl 30, got: %d", stage.accessLogsTTLDays)
+	}
+	routeSettings := &apitypes.RouteSettings{ThrottlingRateLimit: stage.throttleRate, ThrottlingBurstLimit: stage.throttleBurst, DetailedMetricsEnabled: &stage.detailedMetrics}
+	accessLogSettings := &apitypes.AccessLogSettings{DestinationArn: aws.String("arn")}
+	output := lambdaApiStageAttrs(routeSettings, accessLogSettings)
+	if !reflect.DeepEqual(output, attrs) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", output, attrs)
+	}
+	defaults := &apitypes.RouteSettings{ThrottlingRateLimit: aws.Float64(lambdaApiThrottleRateDefault), ThrottlingBurstLimit: aws.Int32(lambdaApiThrottleBurstDefault), DetailedMetricsEnabled: aws.Bool(false)}
+	output = lambdaApiStageAttrs(defaults, nil)
+	if len(output) != 0 {
+		t.Errorf("\nexpected no attrs for default throttling, got: %v", output)
+	}
+	for _, attr := range []string{"throttle-rate=fast", "throttle-burst=-1", "access-logs=yes", "access-logs-ttl-days=3"} {
+		_, err := lambdaApiStageInput(&InfraTrigger{Type: lambdaTriggerApi, Attr: []string{attr}})
+		if err == nil {
+			t.Errorf("\nexpected error for: %s", attr)
+		}
+	}
+	_, err = lambdaApiStageInput(&InfraTrigger{Type: lambdaTriggerWebsocket, Attr: []string{"access-logs=true"}})
+	if err == nil {
+		t.Errorf("\nexpected error for websocket access-logs")
+	}
+}
+
+func TestLambdaScheduleInput(t *testing.T) {
+	attrs := []string{"cron(0 9 * * ? *)", "timezone=America/New_York", `payload={"job":"report"}`, "start=2030-01-01T00:00:00Z", "end=2031-01-01T00:00:00Z", "window=15"}
+	schedule, err := lambdaScheduleInput(&InfraTrigger{Type: lambdaTriggerSchedule, Attr: attrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := lambdaScheduleAttrs(schedule)
+	if !reflect.DeepEqual(output, attrs) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", output, attrs)
+	}
+	other, err := lambdaScheduleInput(&InfraTrigger{Type: lambdaTriggerSchedule, Attr: attrs[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lambdaScheduleHashName(schedule) == lambdaScheduleHashName(other) {
+		t.Errorf("\nexpected name to depend on attrs")
+	}
+	for _, attr := range [][]string{
+		nil,
+		{"every 5 minutes"},
+		{"rate(5 minutes)", "timezone=Mars/Olympus"},
+		{"rate(5 minutes)", "payload={bad"},
+		{"rate(5 minutes)", "start=2030-01-01"},
+		{"rate(5 minutes)", "window=0"},
+		{"rate(5 minutes)", "start=2031-01-01T00:00:00Z", "end=2030-01-01T00:00:00Z"},
+		{"rate(5 minutes)", "unknown=1"},
+	} {
+		_, err := lambdaScheduleInput(&InfraTrigger{Type: lambdaTriggerSchedule, Attr: attr})
+		if err == nil {
+			t.Errorf("\nexpected error for: %v", attr)
+		}
+	}
+}
+
+func TestLambdaScheduleRoleName(t *testing.T) {
+	short := "my-lambda"
+	if lambdaScheduleRoleName(short) != short+lambdaScheduleRoleSuffix {
+		t.Errorf("\ngot:\n%s\n", lambdaScheduleRoleName(short))
+	}
+	maxLen := lambdaScheduleRoleMaxLen - len(lambdaScheduleRoleSuffix)
+	fits := strings.Repeat("a", maxLen)
+	if lambdaScheduleRoleName(fits) != fits+lambdaScheduleRoleSuffix {
+		t.Errorf("\ngot:\n%s\n", lambdaScheduleRoleName(fits))
+	}
+	long1 := strings.Repeat("a", 64)
+	long2 := strings.Repeat("a", 63) + "b"
+	for _, name := range []string{long1, long2} {
+		roleName := lambdaScheduleRoleName(name)
+		if len(roleName) != lambdaScheduleRoleMaxLen || !strings.HasSuffix(roleName, lambdaScheduleRoleSuffix) {
+			t.Errorf("\nbad role name: %s", roleName)
+		}
+	}
+	if lambdaScheduleRoleName(long1) == lambdaScheduleRoleName(long2) {
+		t.Errorf("\nexpected distinct role names for long lambda names")
+	}
+}
+
+func TestLambdaEventRuleName(t *testing.T) {
+	pattern := `{"source":["aws.ec2"]}`
+	short := lambdaEventRuleName("my-lambda", pattern)
+	if !strings.HasPrefix(short, "my-lambda___event_") || len(short) != len("my-lambda___event_")+16 {
+		t.Errorf("\ngot:\n%s\n", short)
+	}
+	long1 := strings.Repeat("a", 64)
+	long2 := strings.Repeat("a", 63) + "b"
+	for _, name := range []string{long1, long2, strings.Repeat("a", 40)} {
+		ruleName := lambdaEventRuleName(name, pattern)
+		if len(ruleName) > lambdaEventRuleNameMaxLen || !strings.HasPrefix(ruleName, lambdaEventRulePrefix(name)) {
+			t.Errorf("\nbad rule name: %s", ruleName)
+		}
+	}
+	if lambdaEventRuleName(long1, pattern) == lambdaEventRuleName(long2, pattern) {
+		t.Errorf("\nexpected distinct rule names for long lambda names")
+	}
+}
+
+func TestLambdaEventPatternInput(t *testing.T) {
+	attrs := []string{"source=aws.ec2", "detail-type=EC2 Instance State-change Notification", "detail.state=running", "detail.state=stopped", "bus=custom"}
+	bus, pattern, err := lambdaEventPatternInput(&InfraTrigger{Type: lambdaTriggerEvent, Attr: attrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bus != "custom" {
+		t.Errorf("\nexpected bus custom, got: %s", bus)
+	}
+	expected := `{"detail":{"state":["running","stopped"]},"detail-type":["EC2 Instance State-change Notification"],"source":["aws.ec2"]}`
+	if pattern != expected {
+		t.Errorf("\ngot:\n%s\nwant:\n%s\n", pattern, expected)
+	}
+	output, err := lambdaEventPatternAttrs(bus, pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(output, attrs) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", output, attrs)
+	}
+	_, nested, err := lambdaEventPatternInput(&InfraTrigger{Type: lambdaTriggerEvent, Attr: []string{"source=app", "detail.user.role=admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nested != `{"detail":{"user":{"role":["admin"]}},"source":["app"]}` {
+		t.Errorf("\nunexpected nested pattern: %s", nested)
+	}
+	for _, attr := range [][]string{
+		nil,
+		{"bus=custom"},
+		{"source=app", "detail.user=x", "detail.user.role=admin"},
+		{"source=app", "detail.user.role=admin", "detail.user=x"},
+		{"source=app", "detail..role=admin"},
+		{"source=app", "unknown=1"},
+	} {
+		_, _, err := lambdaEventPatternInput(&InfraTrigger{Type: lambdaTriggerEvent, Attr: attr})
+		if err == nil {
+			t.Errorf("\nexpected error for: %v", attr)
+		}
+	}
+}
+
+func TestLambdaSNSTriggerInput(t *testing.T) {
+	attrs := []string{"test-topic", `filter={"type": ["paid"]}`, "filter-scope=MessageBody"}
+	input, err := lambdaSNSTriggerInput(&InfraTrigger{Type: lambdaTriggerSNS, Attr: attrs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := lambdaSNSTriggerAttrs(input.topic, input.filter, input.filterScope)
+	if !reflect.DeepEqual(output, attrs) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", output, attrs)
+	}
+	for _, attr := range [][]string{
+		nil,
+		{"filter={}"},
+		{"test-topic.fifo"},
+		{"test-topic", "filter=[]"},
+		{"test-topic", "filter-scope=MessageBody"},
+		{"test-topic", "unknown=1"},
+	} {
+		_, err := lambdaSNSTriggerInput(&InfraTrigger{Type: lambdaTriggerSNS, Attr: attr})
+		if err == nil {
+			t.Errorf("\nexpected error for: %v", attr)
+		}
+	}
+}
+
+func TestLambdaSNSFilterEqual(t *testing.T) {
+	type test struct {
+		a     string
+		b     string
+		equal bool
+	}
+	tests := []test{
+		{"", "", true},
+		{"{}", "", true},
+		{"", "{}", true},
+		{`{"type": ["paid"]}`, `{"type":["paid"]}`, true},
+		{`{"type": ["paid"]}`, "", false},
+		{"{}", `{"type": ["paid"]}`, false},
+	}
+	for _, test := range tests {
+		equal, err := lambdaSNSFilterEqual(test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if equal != test.equal {
+			t.Errorf("\na: %s\nb: %s\nexpected: %v\ngot: %v\n", test.a, test.b, test.equal, equal)
+		}
+	}
+}
+
+func TestLambdaPolicySourceArns(t *testing.T) {
+	policy := `{"Version": "2012-10-17", "Statement": [
+		{"Sid": "a", "Effect": "Allow", "Principal": {"Service": "sns.amazonaws.com"}, "Action": "lambda:InvokeFunction", "Condition": {"ArnLike": {"AWS:SourceArn": "arn:aws:sns:us-west-2:123:topic-a"}}},
+		{"Sid": "b", "Effect": "Allow", "Principal": {"Service": "s3.amazonaws.com"}, "Action": "lambda:InvokeFunction", "Condition": {"ArnLike": {"AWS:SourceArn": "arn:aws:s3:::bucket"}}},
+		{"Sid": "c", "Effect": "Allow", "Principal": {"Service": "sns.amazonaws.com"}, "Action": "lambda:InvokeFunction", "Condition": {"ArnLike": {"AWS:SourceArn": "arn:aws:sns:us-west-2:123:topic-b"}}},
+		{"Sid": "d", "Effect": "Allow", "Principal": "*", "Action": "lambda:InvokeFunctionUrl"}
+	]}`
+	arns, err := lambdaPolicySourceArns(policy, "sns.amazonaws.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"arn:aws:sns:us-west-2:123:topic-a", "arn:aws:sns:us-west-2:123:topic-b"}
+	if !reflect.DeepEqual(arns, expected) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", arns, expected)
+	}
+}
+
+func TestLambdaKinesisTriggerInput(t *testing.T) {
+	input, err := lambdaKinesisTriggerInput("test-lambda", []string{"batch=500", "parallel=4", "start=trim_horizon", "bisect=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *input.BatchSize != 500 || *input.ParallelizationFactor != 4 || !*input.BisectBatchOnFunctionError || input.StartingPosition != lambdatypes.EventSourcePositionTrimHorizon {
+		t.Errorf("\nunexpected input: %+v", input)
+	}
+	input, err = lambdaKinesisTriggerInput("test-lambda", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.StartingPosition != lambdatypes.EventSourcePositionLatest || *input.MaximumRetryAttempts != -1 {
+		t.Errorf("\nunexpected defaults: %+v", input)
+	}
+	for _, attrs := range [][]string{
+		{"start=at_timestamp"},
+		{"bisect=yes"},
+		{"batch=x"},
+		{"unknown=1"},
+	} {
+		_, err := lambdaKinesisTriggerInput("test-lambda", attrs)
+		if err == nil {
+			t.Errorf("\nexpected error for: %v", attrs)
+		}
+	}
+}
+
+func TestLambdaSQSTriggerInput(t *testing.T) {
+	input, err := lambdaSQSTriggerInput("test-lambda", []string{"batch=100", "partial=true", "concurrency=5", `filter={"body": {"type": ["order"]}}`, `filter={"attributes": {"MessageGroupId": ["a"]}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *input.BatchSize != 100 || *input.ScalingConfig.MaximumConcurrency != 5 || !reflect.DeepEqual(input.FunctionResponseTypes, []lambdatypes.FunctionResponseType{lambdatypes.FunctionResponseTypeReportBatchItemFailures}) {
+		t.Errorf("\nunexpected input: %+v", input)
+	}
+	patterns, err := lambdaFilterPatterns(input.FilterCriteria)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`{"attributes":{"MessageGroupId":["a"]}}`, `{"body":{"type":["order"]}}`}
+	if !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", patterns, expected)
+	}
+	for _, attrs := range [][]string{
+		{"partial=yes"},
+		{"concurrency=1"},
+		{"filter=[1]"},
+		{"unknown=1"},
+	} {
+		_, err := lambdaSQSTriggerInput("test-lambda", attrs)
+		if err == nil {
+			t.Errorf("\nexpected error for: %v", attrs)
+		}
+	}
+}
+
+func TestSQSBatchItemFailures(t *testing.T) {
+	handler := func(ctx context.Context, message events.SQSMessage) error {
+		if message.Body == "bad" {
+			return fmt.Errorf("bad message")
+		}
+		return nil
+	}
+	for _, arn := range []string{"arn:aws:sqs:us-west-2:123:queue", "arn:aws:sqs:us-west-2:123:queue.fifo"} {
+		event := events.SQSEvent{Records: []events.SQSMessage{
+			{MessageId: "1", Body: "good", EventSourceARN: arn},
+			{MessageId: "2", Body: "bad", EventSourceARN: arn},
+			{MessageId: "3", Body: "good", EventSourceARN: arn},
+		}}
+		var failed []string
+		for _, failure := range SQSBatchItemFailures(context.Background(), event, handler).BatchItemFailures {
+			failed = append(failed, failure.ItemIdentifier)
+		}
+		expected := []string{"2"}
+		if arn == "arn:aws:sqs:us-west-2:123:queue.fifo" {
+			expected = []string{"2", "3"}
+		}
+		if !reflect.DeepEqual(failed, expected) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", failed, expected)
+		}
+	}
+}
+
+func TestDynamoDBStreamDecode(t *testing.T) {
+	type user struct {
+		ID    string   `dynamodbav:"id"`
+		Name  string   `dynamodbav:"name"`
+		Age   int      `dynamodbav:"age"`
+		Tags  []string `dynamodbav:"tags,stringset"`
+		Email string   `dynamodbav:"email,omitempty"`
+	}
+	data := `{"Records": [
+		{"eventName": "INSERT", "dynamodb": {
+			"Keys": {"id": {"S": "1"}},
+			"NewImage": {"id": {"S": "1"}, "name": {"S": "jane"}, "age": {"N": "30"}}}},
+		{"eventName": "MODIFY", "dynamodb": {
+			"Keys": {"id": {"S": "1"}},
+			"OldImage": {"id": {"S": "1"}, "name": {"S": "jane"}, "age": {"N": "30"}, "email": {"S": "j@example.com"}},
+			"NewImage": {"id": {"S": "1"}, "name": {"S": "jane"}, "age": {"N": "31"}, "tags": {"SS": ["a"]}}}},
+		{"eventName": "REMOVE", "dynamodb": {
+			"Keys": {"id": {"S": "1"}},
+			"OldImage": {"id": {"S": "1"}, "name": {"S": "jane"}, "age": {"N": "31"}}}},
+		{"eventName": "REMOVE", "dynamodb": {
+			"Keys": {"id": {"S": "2"}}}},
+		{"eventName": "MODIFY", "dynamodb": {
+			"Keys": {"id": {"S": "1"}},
+			"NewImage": {"id": {"S": "1"}, "name": {"S": "jane"}, "age": {"N": "32"}}}},
+		{"eventName": "MODIFY", "dynamodb": {
+			"Keys": {"id": {"S": "1"}},
+			"OldImage": {"id": {"S": "1"}, "name": {"S": "jane"}, "age": {"N": "32"}, "tags": {"SS": ["a", "b"]}},
+			"NewImage": {"id": {"S": "1"}, "name": {"S": "jane"}, "age": {"N": "33"}, "tags": {"SS": ["b", "a"]}}}}
+	]}`
+	var event events.DynamoDBEvent
+	err := json.Unmarshal([]byte(data), &event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := DynamoDBStreamRecords[user](event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type test struct {
+		eventName events.DynamoDBOperationType
+		old       *user
+		new       *user
+		changed   []string
+	}
+	tests := []test{
+		{events.DynamoDBOperationTypeInsert, nil, &user{ID: "1", Name: "jane", Age: 30}, []string{"age", "id", "name"}},
+		{events.DynamoDBOperationTypeModify, &user{ID: "1", Name: "jane", Age: 30, Email: "j@example.com"}, &user{ID: "1", Name: "jane", Age: 31, Tags: []string{"a"}}, []string{"age", "email", "tags"}},
+		{events.DynamoDBOperationTypeRemove, &user{ID: "1", Name: "jane", Age: 31}, nil, []string{"age", "id", "name"}},
+		{events.DynamoDBOperationTypeRemove, nil, nil, nil},
+		{events.DynamoDBOperationTypeModify, nil, &user{ID: "1", Name: "jane", Age: 32}, nil},
+		{events.DynamoDBOperationTypeModify, &user{ID: "1", Name: "jane", Age: 32, Tags: []string{"a", "b"}}, &user{ID: "1", Name: "jane", Age: 33, Tags: []string{"b", "a"}}, []string{"age"}},
+	}
+	if len(records) != len(tests) {
+		t.Fatalf("\ngot %d records, want %d", len(records), len(tests))
+	}
+	for i, test := range tests {
+		record := records[i]
+		if record.EventName != test.eventName || !reflect.DeepEqual(record.Old, test.old) || !reflect.DeepEqual(record.New, test.new) || !reflect.DeepEqual(record.Changed, test.changed) {
+			t.Errorf("\ngot:\n%s %+v %+v %v\nwant:\n%s %+v %+v %v\n", record.EventName, record.Old, record.New, record.Changed, test.eventName, test.old, test.new, test.changed)
+		}
+	}
+	if !records[1].HasChanged("name", "age") || records[1].HasChanged("name", "id") {
+		t.Errorf("\nunexpected HasChanged for: %v", records[1].Changed)
+	}
+}
+
+func TestDynamoDBChangedFields(t *testing.T) {
+	type test struct {
+		old     map[string]ddbtypes.AttributeValue
+		new     map[string]ddbtypes.AttributeValue
+		changed []string
+	}
+	tests := []test{
+		{
+			map[string]ddbtypes.AttributeValue{
+				"ss": &ddbtypes.AttributeValueMemberSS{Value: []string{"a", "b"}},
+				"ns": &ddbtypes.AttributeValueMemberNS{Value: []string{"1", "2"}},
+				"bs": &ddbtypes.AttributeValueMemberBS{Value: [][]byte{[]byte("a"), []byte("b")}},
+				"m": &ddbtypes.AttributeValueMemberM{Value: map[string]ddbtypes.AttributeValue{
+					"ss": &ddbtypes.AttributeValueMemberSS{Value: []string{"x", "y"}},
+				}},
+			},
+			map[string]ddbtypes.AttributeValue{
+				"ss": &ddbtypes.AttributeValueMemberSS{Value: []string{"b", "a"}},
+				"ns": &ddbtypes.AttributeValueMemberNS{Value: []string{"2", "1"}},
+				"bs": &ddbtypes.AttributeValueMemberBS{Value: [][]byte{[]byte("b"), []byte("a")}},
+				"m": &ddbtypes.AttributeValueMemberM{Value: map[string]ddbtypes.AttributeValue{
+					"ss": &ddbtypes.AttributeValueMemberSS{Value: []string{"y", "x"}},
+				}},
+			},
+			[]string{},
+		},
+		{
+			map[string]ddbtypes.AttributeValue{
+				"ss": &ddbtypes.AttributeValueMemberSS{Value: []string{"a", "b"}},
+				"ns": &ddbtypes.AttributeValueMemberNS{Value: []string{"1"}},
+				"l": &ddbtypes.AttributeValueMemberL{Value: []ddbtypes.AttributeValue{
+					&ddbtypes.AttributeValueMemberS{Value: "a"},
+					&ddbtypes.AttributeValueMemberS{Value: "b"},
+				}},
+				"s": &ddbtypes.AttributeValueMemberS{Value: "1"},
+			},
+			map[string]ddbtypes.AttributeValue{
+				"ss": &ddbtypes.AttributeValueMemberSS{Value: []string{"a", "c"}},
+				"ns": &ddbtypes.AttributeValueMemberSS{Value: []string{"1"}},
+				"l": &ddbtypes.AttributeValueMemberL{Value: []ddbtypes.AttributeValue{
+					&ddbtypes.AttributeValueMemberS{Value: "b"},
+					&ddbtypes.AttributeValueMemberS{Value: "a"},
+				}},
+				"s": &ddbtypes.AttributeValueMemberS{Value: "1"},
+			},
+			[]string{"l", "ns", "ss"},
+		},
+	}
+	for _, test := range tests {
+		changed := DynamoDBChangedFields(test.old, test.new)
+		if !reflect.DeepEqual(changed, test.changed) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", changed, test.changed)
+		}
+	}
+}
+
+func TestLambdaPyCachePrune(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-31 * 24 * time.Hour)
+	for _, name := range []string{"fresh", "stale", "stale.tmp.123"} {
+		err := os.MkdirAll(path.Join(dir, name, "pkg"), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(name, "stale") {
+			err = os.Chtimes(path.Join(dir, name), old, old)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	err := lambdaPyCachePrune(dir, 30*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"fresh"}) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", names, []string{"fresh"})
+	}
+	err = lambdaPyCachePrune(path.Join(dir, "missing"), time.Hour)
+	if err != nil {
+		t.Errorf("\nexpected no error for missing cache dir: %s", err)
+	}
+}
@@ -199,6 +199,11 @@ func shell(format string, a ...any) error {
 	return nil
 }
 
+// shellQuote quotes a string as a single argument for shell() and shellAt()
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 func shellAt(dir string, format string, a ...any) error {
 	cmdString := fmt.Sprintf(format, a...)
 	cmd := exec.Command("bash", "-c", cmdString)
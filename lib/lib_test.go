@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"os/exec"
 	"reflect"
 	"slices"
 	"testing"
@@ -40,3 +41,26 @@ func TestChunk(t *testing.T) {
 		}
 	}
 }
+
+func TestShellQuote(t *testing.T) {
+	type test struct {
+		input  string
+		output string
+	}
+	tests := []test{
+		{"/tmp/build/bootstrap", "'/tmp/build/bootstrap'"},
+		{"/tmp/my dir/bootstrap", "'/tmp/my dir/bootstrap'"},
+		{"requests>=2.0", "'requests>=2.0'"},
+		{"it's; rm -rf /", `'it'\''s; rm -rf /'`},
+	}
+	for _, test := range tests {
+		output := shellQuote(test.input)
+		if output != test.output {
+			t.Errorf("\ngot:\n%s\nwant:\n%s\n", output, test.output)
+		}
+		out, err := exec.Command("bash", "-c", "printf %s "+output).Output()
+		if err != nil || string(out) != test.input {
+			t.Errorf("\nshell got:\n%s %v\nwant:\n%s\n", string(out), err, test.input)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -181,6 +182,8 @@ type s3EnsureInput struct {
 	corsOrigins  []string
 	ttlDays      int
 
+	notifications []*s3Notification // sqs and sns destinations, targets like sqs:QUEUE_NAME
+	eventbridge   bool
 	// NOTE: you almost never want to use this, danger close.
 	// currently used in cmd/vpc/ensure_flowlogs.go
 	//
@@ -207,6 +210,7 @@ func S3EnsureInput(infraSetName, bucketName string, attrs []string) (*s3EnsureIn
 		Statement: []IamStatementEntry{},
 	}
 	for _, line := range attrs {
+		original := line
 		line = strings.ToLower(line)
 		attr, value, err := SplitOnce(line, "=")
 		if err != nil {
@@ -214,6 +218,23 @@ func S3EnsureInput(infraSetName, bucketName string, attrs []string) (*s3EnsureIn
 			return nil, err
 		}
 		switch attr {
+		case "notify":
+			_, value, _ := SplitOnce(original, "=") // keys are case sensitive
+			notification, err := s3NotifyInput(value)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.notifications = append(input.notifications, notification)
+		case "eventbridge":
+			switch value {
+			case "true", "false":
+				input.eventbridge = value == "true"
+			default:
+				err := fmt.Errorf("unknown attr: %s", line)
+				Logger.Println("error:", err)
+				return nil, err
+			}
 		case "allow_put":
 			policy.Statement = append(policy.Statement, IamStatementEntry{
 				Sid:       "allow put from " + value,
@@ -289,6 +310,11 @@ func S3EnsureInput(infraSetName, bucketName string, attrs []string) (*s3EnsureIn
 	if len(input.corsOrigins) > 0 {
 		input.cors = aws.Bool(true)
 	}
+	err := s3ValidateNotifications(bucketName, input.notifications)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
 	return input, nil
 }
 
@@ -835,6 +861,359 @@ func S3Ensure(ctx context.Context, input *s3EnsureInput, preview bool) error {
 			}
 		}
 	}
+	err = s3EnsureNotifications(ctx, input, preview)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	return nil
+}
+
+const (
+	s3NotificationAttrPrefix = "prefix"
+	s3NotificationAttrSuffix = "suffix"
+	s3NotificationAttrEvent  = "event"
+
+	s3NotifySQS = "sqs"
+	s3NotifySNS = "sns"
+)
+
+var s3NotificationEvents = map[string]s3types.Event{
+	"created":     "s3:ObjectCreated:*",
+	"removed":     "s3:ObjectRemoved:*",
+	"restore":     "s3:ObjectRestore:*",
+	"replication": "s3:Replication:*",
+}
+
+var s3NotificationEventsDefault = []string{"created", "removed"}
+
+// s3Notification is a bucket notification to a lambda, queue or topic
+type s3Notification struct {
+	target string   // lambda, queue or topic arn
+	events []string // sorted keys of s3NotificationEvents
+	prefix string
+	suffix string
+}
+
+// s3NotificationInput parses notification attrs like:
+//
+//	prefix=uploads/
+//	suffix=.jpg
+//	event=created
+func s3NotificationInput(target string, attrs []string) (*s3Notification, error) {
+	notification := &s3Notification{target: target}
+	for _, attr := range attrs {
+		k, v, err := SplitOnce(attr, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case s3NotificationAttrPrefix:
+			notification.prefix = v
+		case s3NotificationAttrSuffix:
+			notification.suffix = v
+		case s3NotificationAttrEvent:
+			_, ok := s3NotificationEvents[v]
+			if !ok {
+				err := fmt.Errorf("s3 notification event should be one of created, removed, restore or replication, got: %s", v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if !slices.Contains(notification.events, v) {
+				notification.events = append(notification.events, v)
+			}
+		default:
+			err := fmt.Errorf("unknown s3 notification attr: %s", attr)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	if len(notification.events) == 0 {
+		notification.events = slices.Clone(s3NotificationEventsDefault)
+	}
+	slices.Sort(notification.events)
+	return notification, nil
+}
+
+// s3NotificationFromConfig converts an existing notification, returning nil if it
+// uses events or filters not expressible as attrs
+func s3NotificationFromConfig(target string, events []s3types.Event, filter *s3types.NotificationConfigurationFilter) *s3Notification {
+	notification := &s3Notification{target: target}
+	for _, event := range events {
+		found := false
+		for name, e := range s3NotificationEvents {
+			if e == event {
+				notification.events = append(notification.events, name)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	slices.Sort(notification.events)
+	if filter != nil && filter.Key != nil {
+		for _, rule := range filter.Key.FilterRules {
+			switch strings.ToLower(string(rule.Name)) {
+			case s3NotificationAttrPrefix:
+				notification.prefix = aws.ToString(rule.Value)
+			case s3NotificationAttrSuffix:
+				notification.suffix = aws.ToString(rule.Value)
+			}
+		}
+	}
+	return notification
+}
+
+func (n *s3Notification) Attrs() []string {
+	var attrs []string
+	if n.prefix != "" {
+		attrs = append(attrs, s3NotificationAttrPrefix+"="+n.prefix)
+	}
+	if n.suffix != "" {
+		attrs = append(attrs, s3NotificationAttrSuffix+"="+n.suffix)
+	}
+	if !slices.Equal(n.events, s3NotificationEventsDefault) {
+		for _, event := range n.events {
+			attrs = append(attrs, s3NotificationAttrEvent+"="+event)
+		}
+	}
+	return attrs
+}
+
+func (n *s3Notification) Events() []s3types.Event {
+	var events []s3types.Event
+	for _, event := range n.events {
+		events = append(events, s3NotificationEvents[event])
+	}
+	return events
+}
+
+func (n *s3Notification) Filter() *s3types.NotificationConfigurationFilter {
+	var rules []s3types.FilterRule
+	if n.prefix != "" {
+		rules = append(rules, s3types.FilterRule{Name: s3types.FilterRuleNamePrefix, Value: aws.String(n.prefix)})
+	}
+	if n.suffix != "" {
+		rules = append(rules, s3types.FilterRule{Name: s3types.FilterRuleNameSuffix, Value: aws.String(n.suffix)})
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return &s3types.NotificationConfigurationFilter{Key: &s3types.S3KeyFilter{FilterRules: rules}}
+}
+
+func (n *s3Notification) String() string {
+	return strings.Join(append([]string{n.target}, n.Attrs()...), " ")
+}
+
+// s3NotificationsOverlap reports whether s3 would reject both notifications on one
+// bucket, which happens when they share an event and a key could match both filters
+func s3NotificationsOverlap(a, b *s3Notification) bool {
+	sharedEvent := false
+	for _, event := range a.events {
+		if slices.Contains(b.events, event) {
+			sharedEvent = true
+			break
+		}
+	}
+	if !sharedEvent {
+		return false
+	}
+	prefixOverlap := strings.HasPrefix(a.prefix, b.prefix) || strings.HasPrefix(b.prefix, a.prefix)
+	suffixOverlap := strings.HasSuffix(a.suffix, b.suffix) || strings.HasSuffix(b.suffix, a.suffix)
+	return prefixOverlap && suffixOverlap
+}
+
+func s3ValidateNotifications(bucket string, notifications []*s3Notification) error {
+	for i, a := range notifications {
+		for _, b := range notifications[i+1:] {
+			if s3NotificationsOverlap(a, b) {
+				err := fmt.Errorf("overlapping s3 notifications for bucket %s: [%s] [%s]", bucket, a, b)
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// s3NotifyInput parses a notify attr value like:
+//
+//	sqs:QUEUE_NAME prefix=uploads/ event=created
+//	sns:TOPIC_NAME suffix=.jpg
+func s3NotifyInput(value string) (*s3Notification, error) {
+	parts := SplitWhiteSpace(value)
+	if len(parts) == 0 {
+		err := fmt.Errorf("empty s3 notify attr")
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	protocol, name, err := SplitOnce(parts[0], ":")
+	if err != nil || name == "" || (protocol != s3NotifySQS && protocol != s3NotifySNS) {
+		err := fmt.Errorf("s3 notify attr should start with sqs:QUEUE or sns:TOPIC, got: %s", value)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if strings.HasSuffix(name, sqsFifoSuffix) {
+		err := fmt.Errorf("s3 cannot notify fifo queues or topics, got: %s", value)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	notification, err := s3NotificationInput(parts[0], parts[1:])
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return notification, nil
+}
+
+// s3EnsureNotifications reconciles sqs and sns notifications, which are all managed
+// by notify attrs, and eventbridge. lambda notifications are managed by s3 triggers.
+func s3EnsureNotifications(ctx context.Context, input *s3EnsureInput, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "s3EnsureNotifications"}
+		d.Start()
+		defer d.End()
+	}
+	bucketArn := "arn:aws:s3:::" + input.name
+	out, err := S3Client().GetBucketNotificationConfiguration(ctx, &s3.GetBucketNotificationConfigurationInput{
+		Bucket: aws.String(input.name),
+	})
+	if err != nil {
+		if !preview || !strings.Contains(err.Error(), s3ErrCodeNoSuchBucket) {
+			Logger.Println("error:", err)
+			return err
+		}
+		out = &s3.GetBucketNotificationConfigurationOutput{}
+	}
+	// policies must allow s3 before notifications are put, since s3 validates destinations
+	for _, notification := range input.notifications {
+		protocol, name, _ := SplitOnce(notification.target, ":")
+		if protocol == s3NotifySQS {
+			err = SQSEnsurePolicyAllows(ctx, name, "s3.amazonaws.com", bucketArn, preview)
+		} else {
+			err = SNSEnsurePolicyAllows(ctx, name, "s3.amazonaws.com", bucketArn, preview)
+		}
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	var existing []*s3Notification
+	var unmanaged []string
+	for _, conf := range out.QueueConfigurations {
+		target := s3NotifySQS + ":" + SQSArnToName(aws.ToString(conf.QueueArn))
+		notification := s3NotificationFromConfig(target, conf.Events, conf.Filter)
+		if notification == nil {
+			unmanaged = append(unmanaged, target)
+			notification = &s3Notification{target: target}
+		}
+		existing = append(existing, notification)
+	}
+	for _, conf := range out.TopicConfigurations {
+		target := s3NotifySNS + ":" + SNSArnToName(aws.ToString(conf.TopicArn))
+		notification := s3NotificationFromConfig(target, conf.Events, conf.Filter)
+		if notification == nil {
+			unmanaged = append(unmanaged, target)
+			notification = &s3Notification{target: target}
+		}
+		existing = append(existing, notification)
+	}
+	var existingStrings, wantedStrings []string
+	for _, notification := range existing {
+		existingStrings = append(existingStrings, notification.String())
+	}
+	for _, notification := range input.notifications {
+		wantedStrings = append(wantedStrings, notification.String())
+	}
+	slices.Sort(existingStrings)
+	slices.Sort(wantedStrings)
+	eventbridge := out.EventBridgeConfiguration != nil
+	if slices.Equal(existingStrings, wantedStrings) && len(unmanaged) == 0 && eventbridge == input.eventbridge {
+		return nil
+	}
+	var lambdas []*s3Notification
+	for _, conf := range out.LambdaFunctionConfigurations {
+		notification := s3NotificationFromConfig(aws.ToString(conf.LambdaFunctionArn), conf.Events, conf.Filter)
+		if notification != nil {
+			lambdas = append(lambdas, notification)
+		}
+	}
+	err = s3ValidateNotifications(input.name, append(lambdas, input.notifications...))
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	conf := &s3types.NotificationConfiguration{
+		LambdaFunctionConfigurations: out.LambdaFunctionConfigurations,
+	}
+	if input.eventbridge {
+		conf.EventBridgeConfiguration = &s3types.EventBridgeConfiguration{}
+	}
+	for _, notification := range input.notifications {
+		protocol, name, _ := SplitOnce(notification.target, ":")
+		if protocol == s3NotifySQS {
+			queueArn, err := SQSArn(ctx, name)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			conf.QueueConfigurations = append(conf.QueueConfigurations, s3types.QueueConfiguration{
+				QueueArn: aws.String(queueArn),
+				Events:   notification.Events(),
+				Filter:   notification.Filter(),
+			})
+		} else {
+			topicArn, err := SNSArn(ctx, name)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			conf.TopicConfigurations = append(conf.TopicConfigurations, s3types.TopicConfiguration{
+				TopicArn: aws.String(topicArn),
+				Events:   notification.Events(),
+				Filter:   notification.Filter(),
+			})
+		}
+	}
+	if !preview {
+		err := Retry(ctx, func() error {
+			_, err := S3Client().PutBucketNotificationConfiguration(ctx, &s3.PutBucketNotificationConfigurationInput{
+				Bucket:                    aws.String(input.name),
+				NotificationConfiguration: conf,
+			})
+			return err
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	if eventbridge != input.eventbridge {
+		Logger.Printf(PreviewString(preview)+"updated bucket eventbridge notifications for %s: %t => %t\n", input.name, eventbridge, input.eventbridge)
+	}
+	if !slices.Equal(existingStrings, wantedStrings) || len(unmanaged) > 0 {
+		Logger.Printf(PreviewString(preview)+"updated bucket notifications for %s: %q => %q\n", input.name, existingStrings, wantedStrings)
+	}
+	// remove policy statements for destinations no longer notified
+	for _, notification := range existing {
+		if slices.ContainsFunc(input.notifications, func(n *s3Notification) bool { return n.target == notification.target }) {
+			continue
+		}
+		protocol, name, _ := SplitOnce(notification.target, ":")
+		if protocol == s3NotifySQS {
+			err = SQSRemovePolicyAllows(ctx, name, "s3.amazonaws.com", bucketArn, preview)
+		} else {
+			err = SNSRemovePolicyAllows(ctx, name, "s3.amazonaws.com", bucketArn, preview)
+		}
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -496,3 +496,78 @@ func TestS3EnsurePublicToPrivateNotAllowed(t *testing.T) {
 		return
 	}
 }
+
+func TestS3NotificationsOverlap(t *testing.T) {
+	type test struct {
+		a, b    []string
+		overlap bool
+	}
+	tests := []test{
+		{nil, nil, true},
+		{[]string{"prefix=a/"}, []string{"prefix=b/"}, false},
+		{[]string{"prefix=a/"}, []string{"prefix=a/b/"}, true},
+		{[]string{"prefix=a/"}, nil, true},
+		{[]string{"suffix=.jpg"}, []string{"suffix=.png"}, false},
+		{[]string{"suffix=.jpg"}, []string{"suffix=thumb.jpg"}, true},
+		{[]string{"prefix=a/", "suffix=.jpg"}, []string{"prefix=b/", "suffix=.jpg"}, false},
+		{[]string{"event=created"}, []string{"event=removed"}, false},
+		{[]string{"event=created", "event=restore"}, []string{"event=restore"}, true},
+	}
+	for _, test := range tests {
+		a, err := s3NotificationInput("a", test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := s3NotificationInput("b", test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s3NotificationsOverlap(a, b) != test.overlap {
+			t.Errorf("\nexpected overlap=%t for: %v %v", test.overlap, test.a, test.b)
+		}
+	}
+	n, err := s3NotificationInput("a", []string{"suffix=.jpg", "event=removed", "prefix=uploads/", "event=created"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n.Attrs(), []string{"prefix=uploads/", "suffix=.jpg"}) {
+		t.Errorf("\nunexpected attrs: %v", n.Attrs())
+	}
+	n2 := s3NotificationFromConfig("a", []s3types.Event{"s3:ObjectRestore:*"}, n.Filter())
+	if !reflect.DeepEqual(n2.Attrs(), []string{"prefix=uploads/", "suffix=.jpg", "event=restore"}) {
+		t.Errorf("\nunexpected attrs: %v", n2.Attrs())
+	}
+	_, err = s3NotificationInput("a", []string{"event=copied"})
+	if err == nil {
+		t.Errorf("\nexpected error for unknown event")
+	}
+}
+
+func TestS3EnsureInputNotify(t *testing.T) {
+	input, err := S3EnsureInput("", "bucket", []string{"notify=sqs:Queue prefix=Uploads/ event=created", "notify=sns:topic prefix=logs/", "eventbridge=true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !input.eventbridge {
+		t.Errorf("\nexpected eventbridge")
+	}
+	var notifications []string
+	for _, n := range input.notifications {
+		notifications = append(notifications, n.String())
+	}
+	expected := []string{"sqs:Queue prefix=Uploads/ event=created", "sns:topic prefix=logs/"}
+	if !reflect.DeepEqual(notifications, expected) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", notifications, expected)
+	}
+	for _, attrs := range [][]string{
+		{"notify=lambda:fn"},
+		{"notify=sqs:"},
+		{"notify=sqs:a", "notify=sns:b"},
+		{"notify=sqs:a prefix=x/", "notify=sqs:b prefix=x/y/"},
+	} {
+		_, err := S3EnsureInput("", "bucket", attrs)
+		if err == nil {
+			t.Errorf("\nexpected error for: %v", attrs)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/scheduler"
+	schedulertypes "github.com/aws/aws-sdk-go-v2/service/scheduler/types"
+)
+
+var schedulerClient *scheduler.Client
+var schedulerClientLock sync.Mutex
+
+func SchedulerClient() *scheduler.Client {
+	schedulerClientLock.Lock()
+	defer schedulerClientLock.Unlock()
+	if schedulerClient == nil {
+		schedulerClient = scheduler.NewFromConfig(*Session())
+	}
+	return schedulerClient
+}
+
+func SchedulerClientExplicit(accessKeyID, accessKeySecret, region string) *scheduler.Client {
+	return scheduler.NewFromConfig(*SessionExplicit(accessKeyID, accessKeySecret, region))
+}
+
+func SchedulerListGroups(ctx context.Context) ([]schedulertypes.ScheduleGroupSummary, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SchedulerListGroups"}
+		d.Start()
+		defer d.End()
+	}
+	var token *string
+	var groups []schedulertypes.ScheduleGroupSummary
+	for {
+		out, err := SchedulerClient().ListScheduleGroups(ctx, &scheduler.ListScheduleGroupsInput{
+			NextToken: token,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		groups = append(groups, out.ScheduleGroups...)
+		if out.NextToken == nil {
+			break
+		}
+		token = out.NextToken
+	}
+	return groups, nil
+}
+
+func SchedulerListSchedules(ctx context.Context, groupName string) ([]schedulertypes.ScheduleSummary, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SchedulerListSchedules"}
+		d.Start()
+		defer d.End()
+	}
+	var token *string
+	var schedules []schedulertypes.ScheduleSummary
+	for {
+		out, err := SchedulerClient().ListSchedules(ctx, &scheduler.ListSchedulesInput{
+			GroupName: aws.String(groupName),
+			NextToken: token,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		schedules = append(schedules, out.Schedules...)
+		if out.NextToken == nil {
+			break
+		}
+		token = out.NextToken
+	}
+	return schedules, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -62,6 +64,209 @@ func SNSListSubscriptions(ctx context.Context, topicArn string) ([]snstypes.Subs
 	return subscriptions, nil
 }
 
+const (
+	snsAttrKmsKey        = "kms-key"
+	snsAttrContentDedup  = "content-dedup"
+	snsAttrKmsKeyDefault = "alias/aws/sns"
+	snsAttrKmsKeyNone    = "none"
+
+	snsFifoSuffix = ".fifo"
+
+	snsProtocolSQS    = "sqs"
+	snsProtocolEmail  = "email"
+	snsProtocolHttp   = "http"
+	snsProtocolHttps  = "https"
+	snsProtocolLambda = "lambda"
+
+	snsSubscriptionAttrRaw = "raw"
+
+	snsPendingConfirmation = "PendingConfirmation"
+)
+
+// subscriptions managed by an sns topic, lambda subscriptions are managed by sns triggers
+var snsManagedProtocols = []string{snsProtocolSQS, snsProtocolEmail, snsProtocolHttp, snsProtocolHttps}
+
+type snsSubscription struct {
+	protocol string
+	endpoint string // queue name for sqs
+	raw      bool
+}
+
+type snsEnsureInput struct {
+	infraSetName  string
+	name          string
+	kmsKey        string
+	contentDedup  bool
+	subscriptions []*snsSubscription
+}
+
+func (input *snsEnsureInput) fifo() bool {
+	return strings.HasSuffix(input.name, snsFifoSuffix)
+}
+
+func (input *snsEnsureInput) Attrs() map[string]string {
+	attrs := map[string]string{}
+	if input.kmsKey != snsAttrKmsKeyNone {
+		attrs["KmsMasterKeyId"] = input.kmsKey
+	}
+	if input.fifo() {
+		attrs["FifoTopic"] = "true"
+		attrs["ContentBasedDeduplication"] = fmt.Sprint(input.contentDedup)
+	}
+	return attrs
+}
+
+// snsSubscriptionInput parses a subscription like:
+//
+//	sqs:QUEUE_NAME raw=true
+//	email:ADDRESS
+//	https://example.com/hook raw=true
+func snsSubscriptionInput(subscription string) (*snsSubscription, error) {
+	parts := SplitWhiteSpace(subscription)
+	if len(parts) == 0 {
+		err := fmt.Errorf("empty sns subscription")
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	sub := &snsSubscription{}
+	if strings.HasPrefix(parts[0], "https://") || strings.HasPrefix(parts[0], "http://") {
+		sub.protocol, _, _ = strings.Cut(parts[0], ":")
+		sub.endpoint = parts[0]
+	} else {
+		protocol, endpoint, err := SplitOnce(parts[0], ":")
+		if err != nil || endpoint == "" || !slices.Contains([]string{snsProtocolSQS, snsProtocolEmail}, protocol) {
+			err := fmt.Errorf("sns subscription should be sqs:QUEUE, email:ADDRESS or an http(s) url, got: %s", subscription)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		sub.protocol = protocol
+		sub.endpoint = endpoint
+	}
+	for _, attr := range parts[1:] {
+		k, v, err := SplitOnce(attr, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case snsSubscriptionAttrRaw:
+			if v != "true" && v != "false" {
+				err := fmt.Errorf("sns subscription attr %s should be true or false, got: %s", k, v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if sub.protocol == snsProtocolEmail {
+				err := fmt.Errorf("sns subscription attr %s is not valid for email: %s", k, subscription)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			sub.raw = v == "true"
+		default:
+			err := fmt.Errorf("unknown sns subscription attr: %s", attr)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	return sub, nil
+}
+
+func (sub *snsSubscription) String() string {
+	s := sub.protocol + ":" + sub.endpoint
+	if sub.protocol == snsProtocolHttp || sub.protocol == snsProtocolHttps {
+		s = sub.endpoint
+	}
+	if sub.raw {
+		s += " " + snsSubscriptionAttrRaw + "=true"
+	}
+	return s
+}
+
+func SNSEnsureInput(infraSetName, topicName string, attrs, subscriptions []string) (*snsEnsureInput, error) {
+	input := &snsEnsureInput{
+		infraSetName: infraSetName,
+		name:         topicName,
+		kmsKey:       snsAttrKmsKeyDefault,
+	}
+	for _, attr := range attrs {
+		k, v, err := SplitOnce(attr, "=")
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		switch k {
+		case snsAttrKmsKey:
+			input.kmsKey = v
+		case snsAttrContentDedup:
+			if v != "true" && v != "false" {
+				err := fmt.Errorf("sns attr %s should be true or false, got: %s", k, v)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if !input.fifo() {
+				err := fmt.Errorf("sns attr %s is only valid for fifo topics ending in %s: %s", k, snsFifoSuffix, topicName)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.contentDedup = v == "true"
+		default:
+			err := fmt.Errorf("unknown sns attr: %s", attr)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+	}
+	for _, subscription := range subscriptions {
+		sub, err := snsSubscriptionInput(subscription)
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if input.fifo() && sub.protocol != snsProtocolSQS {
+			err := fmt.Errorf("fifo sns topics only support sqs subscriptions: %s %s", topicName, subscription)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if input.fifo() && !strings.HasSuffix(sub.endpoint, snsFifoSuffix) {
+			err := fmt.Errorf("fifo sns topics only support fifo sqs queues: %s %s", topicName, subscription)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		input.subscriptions = append(input.subscriptions, sub)
+	}
+	return input, nil
+}
+
+func SNSListTopics(ctx context.Context) ([]string, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SNSListTopics"}
+		d.Start()
+		defer d.End()
+	}
+	var nextToken *string
+	var topicArns []string
+	for {
+		out, err := SNSClient().ListTopics(ctx, &sns.ListTopicsInput{
+			NextToken: nextToken,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, topic := range out.Topics {
+			topicArns = append(topicArns, *topic.TopicArn)
+		}
+		if out.NextToken == nil {
+			break
+		}
+		nextToken = out.NextToken
+	}
+	return topicArns, nil
+}
+
+func SNSArnToName(arn string) string {
+	return Last(strings.Split(arn, ":"))
+}
+
+// SNSEnsure creates a topic with default attrs if it does not exist, an existing topic is left unchanged
 func SNSEnsure(ctx context.Context, name string, preview bool) error {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "SNSEnsure"}
@@ -76,24 +281,313 @@ func SNSEnsure(ctx context.Context, name string, preview bool) error {
 	_, err = SNSClient().GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
 		TopicArn: aws.String(snsArn),
 	})
+	if err == nil {
+		return nil
+	}
+	var nfe *snstypes.NotFoundException
+	if !errors.As(err, &nfe) {
+		Logger.Println("error:", err)
+		return err
+	}
+	input, err := SNSEnsureInput("", name, nil, nil)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	return SNSEnsureTopic(ctx, input, preview)
+}
+
+// SNSEnsureTopic reconciles a topic's attrs and managed subscriptions with input
+func SNSEnsureTopic(ctx context.Context, input *snsEnsureInput, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SNSEnsureTopic"}
+		d.Start()
+		defer d.End()
+	}
+	snsArn, err := SNSArn(ctx, input.name)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	var existing []snstypes.Subscription
+	out, err := SNSClient().GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: aws.String(snsArn),
+	})
 	if err != nil {
 		var nfe *snstypes.NotFoundException
 		if !errors.As(err, &nfe) {
+			Logger.Println("error:", err)
 			return err
 		}
 		if !preview {
 			_, err = SNSClient().CreateTopic(ctx, &sns.CreateTopicInput{
-				Name: aws.String(name),
-				Attributes: map[string]string{
-					"KmsMasterKeyId": "alias/aws/sns",
-				},
+				Name:       aws.String(input.name),
+				Attributes: input.Attrs(),
+				Tags: []snstypes.Tag{{
+					Key:   aws.String(infraSetTagName),
+					Value: aws.String(input.infraSetName),
+				}},
 			})
 			if err != nil {
 				Logger.Println("error:", err)
 				return err
 			}
 		}
-		Logger.Println(PreviewString(preview)+"create sns topic:", name)
+		Logger.Println(PreviewString(preview)+"created sns topic:", input.name)
+		for k, v := range input.Attrs() {
+			Logger.Println(PreviewString(preview)+"created attribute for", input.name+":", k, "=", v)
+		}
+	} else {
+		for k, v := range input.Attrs() {
+			if k == "FifoTopic" || out.Attributes[k] == v {
+				continue
+			}
+			if !preview {
+				_, err := SNSClient().SetTopicAttributes(ctx, &sns.SetTopicAttributesInput{
+					TopicArn:       aws.String(snsArn),
+					AttributeName:  aws.String(k),
+					AttributeValue: aws.String(v),
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Printf(PreviewString(preview)+"updated attr %s for %s: %s => %s\n", k, input.name, out.Attributes[k], v)
+		}
+		if input.kmsKey == snsAttrKmsKeyNone && out.Attributes["KmsMasterKeyId"] != "" {
+			if !preview {
+				_, err := SNSClient().SetTopicAttributes(ctx, &sns.SetTopicAttributesInput{
+					TopicArn:       aws.String(snsArn),
+					AttributeName:  aws.String("KmsMasterKeyId"),
+					AttributeValue: aws.String(""),
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Printf(PreviewString(preview)+"updated attr %s for %s: %s => %s\n", "KmsMasterKeyId", input.name, out.Attributes["KmsMasterKeyId"], snsAttrKmsKeyNone)
+		}
+		existing, err = SNSListSubscriptions(ctx, snsArn)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	var wanted []string
+	for _, sub := range input.subscriptions {
+		endpoint := sub.endpoint
+		if sub.protocol == snsProtocolSQS {
+			endpoint, err = SQSArn(ctx, sub.endpoint)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			err := SQSEnsurePolicyAllows(ctx, sub.endpoint, "sns.amazonaws.com", snsArn, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		wanted = append(wanted, sub.protocol+":"+endpoint)
+		var subscriptionArn string
+		for _, e := range existing {
+			if *e.Protocol == sub.protocol && *e.Endpoint == endpoint {
+				subscriptionArn = *e.SubscriptionArn
+				break
+			}
+		}
+		if subscriptionArn == "" {
+			if !preview {
+				attrs := map[string]string{}
+				if sub.raw {
+					attrs["RawMessageDelivery"] = "true"
+				}
+				_, err := SNSClient().Subscribe(ctx, &sns.SubscribeInput{
+					TopicArn:   aws.String(snsArn),
+					Protocol:   aws.String(sub.protocol),
+					Endpoint:   aws.String(endpoint),
+					Attributes: attrs,
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"created sns subscription:", input.name, sub.String())
+			continue
+		}
+		if subscriptionArn == snsPendingConfirmation {
+			continue
+		}
+		attrsOut, err := SNSClient().GetSubscriptionAttributes(ctx, &sns.GetSubscriptionAttributesInput{
+			SubscriptionArn: aws.String(subscriptionArn),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if sub.protocol != snsProtocolEmail && attrsOut.Attributes["RawMessageDelivery"] != fmt.Sprint(sub.raw) {
+			if !preview {
+				_, err := SNSClient().SetSubscriptionAttributes(ctx, &sns.SetSubscriptionAttributesInput{
+					SubscriptionArn: aws.String(subscriptionArn),
+					AttributeName:   aws.String("RawMessageDelivery"),
+					AttributeValue:  aws.String(fmt.Sprint(sub.raw)),
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+					return err
+				}
+			}
+			Logger.Println(PreviewString(preview)+"updated sns subscription:", input.name, sub.String())
+		}
+	}
+	for _, e := range existing {
+		if !slices.Contains(snsManagedProtocols, *e.Protocol) || slices.Contains(wanted, *e.Protocol+":"+*e.Endpoint) {
+			continue
+		}
+		if *e.SubscriptionArn == snsPendingConfirmation {
+			Logger.Println("pending sns subscription cannot be removed until confirmed:", input.name, *e.Protocol, *e.Endpoint)
+			continue
+		}
+		if !preview {
+			_, err := SNSClient().Unsubscribe(ctx, &sns.UnsubscribeInput{
+				SubscriptionArn: e.SubscriptionArn,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+		Logger.Println(PreviewString(preview)+"deleted sns subscription:", input.name, *e.Protocol, *e.Endpoint)
+		if *e.Protocol == snsProtocolSQS {
+			err := SQSRemovePolicyAllows(ctx, SQSArnToName(*e.Endpoint), "sns.amazonaws.com", snsArn, preview)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func SNSDeleteTopic(ctx context.Context, name string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SNSDeleteTopic"}
+		d.Start()
+		defer d.End()
+	}
+	snsArn, err := SNSArn(ctx, name)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	_, err = SNSClient().GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: aws.String(snsArn),
+	})
+	if err != nil {
+		var nfe *snstypes.NotFoundException
+		if errors.As(err, &nfe) {
+			return nil
+		}
+		Logger.Println("error:", err)
+		return err
+	}
+	if !preview {
+		_, err := SNSClient().DeleteTopic(ctx, &sns.DeleteTopicInput{
+			TopicArn: aws.String(snsArn),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	Logger.Println(PreviewString(preview)+"deleted sns topic:", name)
+	return nil
+}
+
+// SNSEnsurePolicyAllows ensures the topic policy allows a service like
+// s3.amazonaws.com to publish from a source arn, keeping other statements
+func SNSEnsurePolicyAllows(ctx context.Context, topicName, service, sourceArn string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SNSEnsurePolicyAllows"}
+		d.Start()
+		defer d.End()
+	}
+	topicArn, err := SNSArn(ctx, topicName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	statement := map[string]any{
+		"Sid":       iamServicePolicySid(service, sourceArn),
+		"Effect":    "Allow",
+		"Principal": map[string]any{"Service": service},
+		"Action":    "sns:Publish",
+		"Resource":  topicArn,
+		"Condition": map[string]any{"ArnEquals": map[string]any{"aws:SourceArn": sourceArn}},
+	}
+	return snsEnsurePolicyStatement(ctx, topicName, iamServicePolicySid(service, sourceArn), statement, preview)
+}
+
+// SNSRemovePolicyAllows removes a statement added by SNSEnsurePolicyAllows
+func SNSRemovePolicyAllows(ctx context.Context, topicName, service, sourceArn string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SNSRemovePolicyAllows"}
+		d.Start()
+		defer d.End()
+	}
+	return snsEnsurePolicyStatement(ctx, topicName, iamServicePolicySid(service, sourceArn), nil, preview)
+}
+
+// snsEnsurePolicyStatement sets or, if statement is nil, removes the policy statement with sid
+func snsEnsurePolicyStatement(ctx context.Context, topicName, sid string, statement map[string]any, preview bool) error {
+	topicArn, err := SNSArn(ctx, topicName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	out, err := SNSClient().GetTopicAttributes(ctx, &sns.GetTopicAttributesInput{
+		TopicArn: aws.String(topicArn),
+	})
+	if err != nil {
+		var nfe *snstypes.NotFoundException
+		if errors.As(err, &nfe) {
+			if statement == nil {
+				return nil
+			}
+			if preview {
+				Logger.Println(PreviewString(preview)+"updated topic policy statement:", topicName, sid)
+				return nil
+			}
+		}
+		Logger.Println("error:", err)
+		return err
+	}
+	policy, changed, err := iamPolicyWithStatement(out.Attributes["Policy"], sid, statement)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	if !preview {
+		_, err := SNSClient().SetTopicAttributes(ctx, &sns.SetTopicAttributesInput{
+			TopicArn:       aws.String(topicArn),
+			AttributeName:  aws.String("Policy"),
+			AttributeValue: aws.String(policy),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	if statement == nil {
+		Logger.Println(PreviewString(preview)+"removed topic policy statement:", topicName, sid)
+	} else {
+		Logger.Println(PreviewString(preview)+"updated topic policy statement:", topicName, sid)
 	}
 	return nil
 }
@@ -0,0 +1,38 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSNSEnsureInput(t *testing.T) {
+	input, err := SNSEnsureInput("", "test-topic", []string{"kms-key=none"}, []string{"sqs:test-queue raw=true", "email:ops@example.com", "https://example.com/hook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subscriptions []string
+	for _, sub := range input.subscriptions {
+		subscriptions = append(subscriptions, sub.String())
+	}
+	expected := []string{"sqs:test-queue raw=true", "email:ops@example.com", "https://example.com/hook"}
+	if !reflect.DeepEqual(subscriptions, expected) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", subscriptions, expected)
+	}
+	if len(input.Attrs()) != 0 {
+		t.Errorf("\nexpected no attrs, got: %v", input.Attrs())
+	}
+	for _, args := range [][2][]string{
+		{{"content-dedup=true"}, nil},
+		{nil, {"lambda:test-lambda"}},
+		{nil, {"email:ops@example.com raw=true"}},
+	} {
+		_, err := SNSEnsureInput("", "test-topic", args[0], args[1])
+		if err == nil {
+			t.Errorf("\nexpected error for: %v", args)
+		}
+	}
+	_, err = SNSEnsureInput("", "test-topic.fifo", []string{"content-dedup=true"}, []string{"sqs:test-queue"})
+	if err == nil {
+		t.Errorf("\nexpected error for non fifo queue on fifo topic")
+	}
+}
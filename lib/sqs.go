@@ -2,6 +2,8 @@ package lib
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"slices"
 	"strconv"
@@ -12,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
 )
 
 var sqsClient *sqs.Client
@@ -138,6 +141,32 @@ func SQSNumMessages(ctx context.Context, queueUrl string) (*SQSNumMessageOutput,
 	}, nil
 }
 
+// SQSBatchSize is the most messages a single sqs batch request accepts
+const SQSBatchSize = 10
+
+const (
+	sqsBatchMaxBytes            = 256 * 1024 // total payload of a send batch
+	sqsSendAttempts             = 8
+	sqsRedriveEmptyReceives     = 5
+	sqsFifoSuffix               = ".fifo"
+	sqsDefaultMaxReceiveCount   = 5
+	sqsDeduplicationScopeQueue  = "queue"
+	sqsDeduplicationScopeGroup  = "messageGroup"
+	sqsFifoThroughputLimitQueue = "perQueue"
+	sqsFifoThroughputLimitGroup = "perMessageGroupId"
+	sqsAttrContentDedup         = "contentbaseddeduplication"
+	sqsAttrContentDedupShort    = "contentdedup"
+	sqsAttrHighThroughput       = "highthroughput"
+	sqsAttrDeadLetterQueue      = "deadletterqueue"
+	sqsAttrDeadLetterQueueShort = "dlq"
+	sqsAttrMaxReceiveCount      = "maxreceivecount"
+	sqsAttrMaxReceiveCountShort = "maxreceive"
+	sqsAttrKmsKey               = "kmsmasterkeyid"
+	sqsAttrKmsKeyShort          = "kms"
+	sqsKmsKeyDefault            = "alias/aws/sqs"
+	sqsKmsKeyNone               = "none"
+)
+
 type sqsEnsureInput struct {
 	infraSetName                  string
 	name                          string
@@ -147,11 +176,133 @@ type sqsEnsureInput struct {
 	receiveMessageWaitTimeSeconds int
 	visibilityTimeout             int
 	kmsDataKeyReusePeriodSeconds  int
+	kmsKey                        string // none disables kms and enables sqs managed encryption
+	contentDedup                  bool
+	highThroughput                bool
+	deadLetterQueue               string
+	maxReceiveCount               int
+}
+
+type sqsRedrivePolicy struct {
+	DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	MaxReceiveCount     any    `json:"maxReceiveCount"` // number or string depending on who set it
+}
+
+func (input *sqsEnsureInput) fifo() bool {
+	return strings.HasSuffix(input.name, sqsFifoSuffix)
+}
+
+func (input *sqsEnsureInput) deduplicationScope() string {
+	if input.highThroughput {
+		return sqsDeduplicationScopeGroup
+	}
+	return sqsDeduplicationScopeQueue
+}
+
+func (input *sqsEnsureInput) fifoThroughputLimit() string {
+	if input.highThroughput {
+		return sqsFifoThroughputLimitGroup
+	}
+	return sqsFifoThroughputLimitQueue
+}
+
+// RedrivePolicy returns the policy json for the dead-letter queue, or an
+// empty string when there is none, which removes an existing policy.
+func (input *sqsEnsureInput) RedrivePolicy(ctx context.Context) (string, error) {
+	if input.deadLetterQueue == "" {
+		return "", nil
+	}
+	arn, err := SQSArn(ctx, input.deadLetterQueue)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	data, err := json.Marshal(sqsRedrivePolicy{
+		DeadLetterTargetArn: arn,
+		MaxReceiveCount:     input.maxReceiveCount,
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", err
+	}
+	return string(data), nil
+}
+
+// sqsParseRedrivePolicy returns the dead-letter queue name and max receive
+// count from a RedrivePolicy attribute, or an empty name if there is none.
+func sqsParseRedrivePolicy(policy string) (string, int, error) {
+	if policy == "" {
+		return "", 0, nil
+	}
+	var redrive sqsRedrivePolicy
+	err := json.Unmarshal([]byte(policy), &redrive)
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", 0, err
+	}
+	count, err := strconv.Atoi(fmt.Sprint(redrive.MaxReceiveCount))
+	if err != nil {
+		Logger.Println("error:", err)
+		return "", 0, err
+	}
+	return SQSArnToName(redrive.DeadLetterTargetArn), count, nil
+}
+
+// sqsEnsureOrder returns queue names ordered so that every dead-letter queue
+// comes before the queues that redrive to it.
+func sqsEnsureOrder(inputs map[string]*sqsEnsureInput) ([]string, error) {
+	var names []string
+	for name := range inputs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var order []string
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		if done[name] {
+			return nil
+		}
+		path = append(path, name)
+		if visiting[name] {
+			err := fmt.Errorf("sqs dead-letter queues form a cycle: %s", strings.Join(path, " => "))
+			Logger.Println("error:", err)
+			return err
+		}
+		visiting[name] = true
+		input, ok := inputs[name]
+		if !ok {
+			err := fmt.Errorf("sqs queue %s has unknown dead-letter queue: %s", path[len(path)-2], name)
+			Logger.Println("error:", err)
+			return err
+		}
+		if input.deadLetterQueue != "" {
+			err := visit(input.deadLetterQueue, path)
+			if err != nil {
+				return err
+			}
+		}
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		err := visit(name, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
 }
 
 func (input *sqsEnsureInput) Attrs() map[string]string {
 	m := map[string]string{}
-	m["KmsMasterKeyId"] = "alias/aws/sqs"
+	if input.kmsKey != sqsKmsKeyNone {
+		m["KmsMasterKeyId"] = input.kmsKey
+	} else {
+		m["SqsManagedSseEnabled"] = "true"
+	}
 	if input.delaySeconds != -1 {
 		m["DelaySeconds"] = fmt.Sprint(input.delaySeconds)
 	}
@@ -170,6 +321,11 @@ func (input *sqsEnsureInput) Attrs() map[string]string {
 	if input.kmsDataKeyReusePeriodSeconds != -1 {
 		m["KmsDataKeyReusePeriodSeconds"] = fmt.Sprint(input.kmsDataKeyReusePeriodSeconds)
 	}
+	if input.fifo() {
+		m["ContentBasedDeduplication"] = fmt.Sprint(input.contentDedup)
+		m["DeduplicationScope"] = input.deduplicationScope()
+		m["FifoThroughputLimit"] = input.fifoThroughputLimit()
+	}
 	if len(m) != 0 {
 		return m
 	}
@@ -186,14 +342,16 @@ func SQSEnsureInput(infraSetName, queueName string, attrs []string) (*sqsEnsureI
 		receiveMessageWaitTimeSeconds: -1,
 		visibilityTimeout:             -1,
 		kmsDataKeyReusePeriodSeconds:  -1,
+		kmsKey:                        sqsKmsKeyDefault,
+		maxReceiveCount:               -1,
 	}
 	for _, line := range attrs {
-		line = strings.ToLower(line)
 		attr, value, err := SplitOnce(line, "=")
 		if err != nil {
 			Logger.Println("error:", err)
 			return nil, err
 		}
+		attr = strings.ToLower(attr)
 		switch attr {
 		case "delayseconds", "delay":
 			num, err := strconv.Atoi(value)
@@ -237,15 +395,84 @@ func SQSEnsureInput(infraSetName, queueName string, attrs []string) (*sqsEnsureI
 				return nil, err
 			}
 			input.kmsDataKeyReusePeriodSeconds = num
+		case sqsAttrKmsKey, sqsAttrKmsKeyShort:
+			if value == "" {
+				err := fmt.Errorf("sqs attr %s should be %s or a kms key id, got empty value: %s", attr, sqsKmsKeyNone, queueName)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.kmsKey = value
+		case sqsAttrContentDedup, sqsAttrContentDedupShort, sqsAttrHighThroughput:
+			value = strings.ToLower(value)
+			if value != "true" && value != "false" {
+				err := fmt.Errorf("sqs attr %s should be true or false, got: %s", attr, value)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if !input.fifo() {
+				err := fmt.Errorf("sqs attr %s is only valid for fifo queues ending in %s: %s", attr, sqsFifoSuffix, queueName)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if attr == sqsAttrHighThroughput {
+				input.highThroughput = value == "true"
+			} else {
+				input.contentDedup = value == "true"
+			}
+		case sqsAttrDeadLetterQueue, sqsAttrDeadLetterQueueShort:
+			input.deadLetterQueue = value
+		case sqsAttrMaxReceiveCount, sqsAttrMaxReceiveCountShort:
+			num, err := strconv.Atoi(value)
+			if err != nil {
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			if num < 1 || num > 1000 {
+				err := fmt.Errorf("sqs attr %s should be between 1 and 1000, got: %d", attr, num)
+				Logger.Println("error:", err)
+				return nil, err
+			}
+			input.maxReceiveCount = num
 		default:
 			err := fmt.Errorf("unknown sqs attr: %s", line)
 			Logger.Println("error:", err)
 			return nil, err
 		}
 	}
+	if input.deadLetterQueue == "" && input.maxReceiveCount != -1 {
+		err := fmt.Errorf("sqs attr %s requires %s: %s", sqsAttrMaxReceiveCount, sqsAttrDeadLetterQueueShort, queueName)
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	if input.deadLetterQueue != "" {
+		if input.deadLetterQueue == queueName {
+			err := fmt.Errorf("sqs queue cannot be its own dead-letter queue: %s", queueName)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if input.fifo() != strings.HasSuffix(input.deadLetterQueue, sqsFifoSuffix) {
+			err := fmt.Errorf("sqs dead-letter queue must be the same type, fifo or standard, as its source queue: %s => %s", queueName, input.deadLetterQueue)
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		if input.maxReceiveCount == -1 {
+			input.maxReceiveCount = sqsDefaultMaxReceiveCount
+		}
+	}
 	return input, nil
 }
 
+// sqsValidatePublisher checks that a service like s3 or sns can send to the
+// queue, which it cannot when the queue uses the aws managed kms key.
+func sqsValidatePublisher(input *sqsEnsureInput, publisher string) error {
+	if input.kmsKey == sqsKmsKeyDefault {
+		err := fmt.Errorf("%s cannot publish to sqs queue %s encrypted with %s, use %s=%s or a customer managed key", publisher, input.name, sqsKmsKeyDefault, sqsAttrKmsKeyShort, sqsKmsKeyNone)
+		Logger.Println("error:", err)
+		return err
+	}
+	return nil
+}
+
 func SQSEnsure(ctx context.Context, input *sqsEnsureInput, preview bool) error {
 	if doDebug {
 		d := &Debug{start: time.Now(), name: "SQSEnsure"}
@@ -257,6 +484,11 @@ func SQSEnsure(ctx context.Context, input *sqsEnsureInput, preview bool) error {
 		Logger.Println("error:", err)
 		return err
 	}
+	redrivePolicy, err := input.RedrivePolicy(ctx)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
 	attrsOut, err := SQSClient().GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
 		QueueUrl: aws.String(sqsUrl),
 		AttributeNames: []sqstypes.QueueAttributeName{
@@ -266,6 +498,12 @@ func SQSEnsure(ctx context.Context, input *sqsEnsureInput, preview bool) error {
 			sqstypes.QueueAttributeNameReceiveMessageWaitTimeSeconds,
 			sqstypes.QueueAttributeNameVisibilityTimeout,
 			sqstypes.QueueAttributeNameKmsDataKeyReusePeriodSeconds,
+			sqstypes.QueueAttributeNameKmsMasterKeyId,
+			sqstypes.QueueAttributeNameSqsManagedSseEnabled,
+			sqstypes.QueueAttributeNameContentBasedDeduplication,
+			sqstypes.QueueAttributeNameDeduplicationScope,
+			sqstypes.QueueAttributeNameFifoThroughputLimit,
+			sqstypes.QueueAttributeNameRedrivePolicy,
 		},
 	})
 	if err != nil {
@@ -273,10 +511,17 @@ func SQSEnsure(ctx context.Context, input *sqsEnsureInput, preview bool) error {
 			Logger.Println("error:", err)
 			return err
 		}
+		createAttrs := input.Attrs()
+		if input.fifo() {
+			createAttrs["FifoQueue"] = "true"
+		}
+		if redrivePolicy != "" {
+			createAttrs["RedrivePolicy"] = redrivePolicy
+		}
 		if !preview {
 			_, err := SQSClient().CreateQueue(ctx, &sqs.CreateQueueInput{
 				QueueName:  aws.String(input.name),
-				Attributes: input.Attrs(),
+				Attributes: createAttrs,
 				Tags: map[string]string{
 					infraSetTagName: input.infraSetName,
 				},
@@ -299,8 +544,8 @@ func SQSEnsure(ctx context.Context, input *sqsEnsureInput, preview bool) error {
 			}
 		}
 		Logger.Printf(PreviewString(preview)+"created queue: %s\n", input.name)
-		for k, v := range input.Attrs() {
-			if v != "" && k != "KmsMasterKeyId" {
+		for k, v := range createAttrs {
+			if v != "" && (k != "KmsMasterKeyId" || v != sqsKmsKeyDefault) {
 				Logger.Println(PreviewString(preview)+"created attribute for", input.name+":", k, "=", v)
 			}
 		}
@@ -331,11 +576,40 @@ func SQSEnsure(ctx context.Context, input *sqsEnsureInput, preview bool) error {
 			Logger.Printf(PreviewString(preview)+"will update attr %s for %s: %d => %d\n", "KmsDataKeyReusePeriodSeconds", input.name, Atoi(attrs["KmsDataKeyReusePeriodSeconds"]), input.kmsDataKeyReusePeriodSeconds)
 			needsUpdate = true
 		}
+		kmsKey := input.Attrs()["KmsMasterKeyId"]
+		if kmsKey != attrs["KmsMasterKeyId"] {
+			Logger.Printf(PreviewString(preview)+"will update attr %s for %s: %q => %q\n", "KmsMasterKeyId", input.name, attrs["KmsMasterKeyId"], kmsKey)
+			needsUpdate = true
+		}
+		if input.kmsKey == sqsKmsKeyNone && attrs["SqsManagedSseEnabled"] != "true" {
+			Logger.Printf(PreviewString(preview)+"will update attr %s for %s: %q => %q\n", "SqsManagedSseEnabled", input.name, attrs["SqsManagedSseEnabled"], "true")
+			needsUpdate = true
+		}
+		if input.fifo() {
+			for _, attr := range []string{"ContentBasedDeduplication", "DeduplicationScope", "FifoThroughputLimit"} {
+				if attrs[attr] != input.Attrs()[attr] {
+					Logger.Printf(PreviewString(preview)+"will update attr %s for %s: %s => %s\n", attr, input.name, attrs[attr], input.Attrs()[attr])
+					needsUpdate = true
+				}
+			}
+		}
+		dlq, maxReceiveCount, err := sqsParseRedrivePolicy(attrs["RedrivePolicy"])
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if dlq != input.deadLetterQueue || (dlq != "" && maxReceiveCount != input.maxReceiveCount) {
+			Logger.Printf(PreviewString(preview)+"will update attr %s for %s: %q => %q\n", "RedrivePolicy", input.name, attrs["RedrivePolicy"], redrivePolicy)
+			needsUpdate = true
+		}
 		if needsUpdate {
+			updateAttrs := input.Attrs()
+			updateAttrs["RedrivePolicy"] = redrivePolicy
+			updateAttrs["KmsMasterKeyId"] = kmsKey // empty disables kms, and Attrs() then enables sqs managed encryption
 			if !preview {
 				_, err := SQSClient().SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
 					QueueUrl:   aws.String(sqsUrl),
-					Attributes: input.Attrs(),
+					Attributes: updateAttrs,
 				})
 				if err != nil {
 					Logger.Println("error:", err)
@@ -372,3 +646,547 @@ func SQSDeleteQueue(ctx context.Context, name string, preview bool) error {
 	Logger.Println(PreviewString(preview)+"deleted queue:", name)
 	return nil
 }
+
+type SQSSendEntry struct {
+	Body              string
+	GroupID           string // fifo only
+	DeduplicationID   string // fifo only, unless the queue uses content based deduplication
+	MessageAttributes map[string]sqstypes.MessageAttributeValue
+}
+
+// sqsEntrySize is the size of an entry as counted against the batch payload limit
+func sqsEntrySize(entry SQSSendEntry) int {
+	size := len(entry.Body)
+	for name, attr := range entry.MessageAttributes {
+		size += len(name) + len(aws.ToString(attr.DataType)) + len(aws.ToString(attr.StringValue)) + len(attr.BinaryValue)
+	}
+	return size
+}
+
+// sqsSendChunks splits entries into batches of at most ten entries and
+// sqsBatchMaxBytes, an entry larger than that is sent alone and rejected by sqs.
+func sqsSendChunks(entries []SQSSendEntry) [][]SQSSendEntry {
+	var chunks [][]SQSSendEntry
+	var chunk []SQSSendEntry
+	chunkSize := 0
+	for _, entry := range entries {
+		size := sqsEntrySize(entry)
+		if len(chunk) == SQSBatchSize || (len(chunk) > 0 && chunkSize+size > sqsBatchMaxBytes) {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			chunkSize = 0
+		}
+		chunk = append(chunk, entry)
+		chunkSize += size
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// sqsSendMessageBatchAPI is the part of the sqs client used by SQSSendBatch
+type sqsSendMessageBatchAPI interface {
+	SendMessageBatch(ctx context.Context, input *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error)
+}
+
+// SQSSendBatch sends entries in batches of up to ten entries and 256KiB,
+// retrying entries that fail without a sender fault.
+func SQSSendBatch(ctx context.Context, queueUrl string, entries []SQSSendEntry) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SQSSendBatch"}
+		d.Start()
+		defer d.End()
+	}
+	return sqsSendBatch(ctx, SQSClient(), queueUrl, entries)
+}
+
+func sqsSendBatch(ctx context.Context, client sqsSendMessageBatchAPI, queueUrl string, entries []SQSSendEntry) error {
+	for _, batch := range sqsSendChunks(entries) {
+		var requests []sqstypes.SendMessageBatchRequestEntry
+		for i, entry := range batch {
+			request := sqstypes.SendMessageBatchRequestEntry{
+				Id:          aws.String(fmt.Sprint(i)),
+				MessageBody: aws.String(entry.Body),
+			}
+			if entry.GroupID != "" {
+				request.MessageGroupId = aws.String(entry.GroupID)
+			}
+			if entry.DeduplicationID != "" {
+				request.MessageDeduplicationId = aws.String(entry.DeduplicationID)
+			}
+			if len(entry.MessageAttributes) != 0 {
+				request.MessageAttributes = entry.MessageAttributes
+			}
+			requests = append(requests, request)
+		}
+		for attempt := 0; len(requests) > 0; attempt++ {
+			out, err := client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+				QueueUrl: aws.String(queueUrl),
+				Entries:  requests,
+			})
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			if len(out.Failed) == 0 {
+				break
+			}
+			var failed []sqstypes.SendMessageBatchRequestEntry
+			var lastErr error
+			for _, result := range out.Failed {
+				lastErr = fmt.Errorf("failed to send message: %s %s", aws.ToString(result.Code), aws.ToString(result.Message))
+				if result.SenderFault {
+					Logger.Println("error:", lastErr)
+					return lastErr
+				}
+				for _, request := range requests {
+					if *request.Id == aws.ToString(result.Id) {
+						failed = append(failed, request)
+					}
+				}
+			}
+			if attempt+1 == sqsSendAttempts {
+				Logger.Println("error:", lastErr)
+				return lastErr
+			}
+			requests = failed
+			time.Sleep(time.Duration(100<<attempt) * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+type SQSReceiveInput struct {
+	QueueUrl          string
+	MaxMessages       int // at most 10
+	WaitSeconds       int // long polling, at most 20
+	VisibilityTimeout int // seconds, 0 uses the queue default
+	Attributes        bool
+}
+
+// sqsReceiveMessageAPI is the part of the sqs client used by SQSReceive
+type sqsReceiveMessageAPI interface {
+	ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+}
+
+func SQSReceive(ctx context.Context, input *SQSReceiveInput) ([]sqstypes.Message, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SQSReceive"}
+		d.Start()
+		defer d.End()
+	}
+	return sqsReceive(ctx, SQSClient(), input)
+}
+
+func sqsReceive(ctx context.Context, client sqsReceiveMessageAPI, input *SQSReceiveInput) ([]sqstypes.Message, error) {
+	receive := &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(input.QueueUrl),
+		MaxNumberOfMessages: int32(input.MaxMessages),
+		WaitTimeSeconds:     int32(input.WaitSeconds),
+		VisibilityTimeout:   int32(input.VisibilityTimeout),
+	}
+	if input.Attributes {
+		receive.MessageSystemAttributeNames = []sqstypes.MessageSystemAttributeName{sqstypes.MessageSystemAttributeNameAll}
+		receive.MessageAttributeNames = []string{"All"}
+	}
+	out, err := client.ReceiveMessage(ctx, receive)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	return out.Messages, nil
+}
+
+// sqsDeleteMessageBatchAPI is the part of the sqs client used by SQSDeleteMessages
+type sqsDeleteMessageBatchAPI interface {
+	DeleteMessageBatch(ctx context.Context, input *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error)
+}
+
+// SQSDeleteMessages deletes received messages in batches of ten.
+func SQSDeleteMessages(ctx context.Context, queueUrl string, messages []sqstypes.Message) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SQSDeleteMessages"}
+		d.Start()
+		defer d.End()
+	}
+	return sqsDeleteMessages(ctx, SQSClient(), queueUrl, messages)
+}
+
+func sqsDeleteMessages(ctx context.Context, client sqsDeleteMessageBatchAPI, queueUrl string, messages []sqstypes.Message) error {
+	for batch := range slices.Chunk(messages, SQSBatchSize) {
+		var entries []sqstypes.DeleteMessageBatchRequestEntry
+		for i, message := range batch {
+			entries = append(entries, sqstypes.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(fmt.Sprint(i)),
+				ReceiptHandle: message.ReceiptHandle,
+			})
+		}
+		out, err := client.DeleteMessageBatch(ctx, &sqs.DeleteMessageBatchInput{
+			QueueUrl: aws.String(queueUrl),
+			Entries:  entries,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		for _, result := range out.Failed {
+			err := fmt.Errorf("failed to delete message: %s %s", aws.ToString(result.Code), aws.ToString(result.Message))
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+type SQSRedriveInput struct {
+	Source            string
+	Destination       string
+	Contains          string // only move messages whose body contains this
+	MatchPath         string // only move messages whose json body has this value at path, like .detail.type
+	MatchValue        string
+	Rate              int // maximum messages per second, 0 for unlimited
+	VisibilityTimeout int // seconds that skipped messages stay hidden while redriving
+}
+
+func (input *SQSRedriveInput) filtered() bool {
+	return input.Contains != "" || input.MatchPath != ""
+}
+
+func (input *SQSRedriveInput) match(body string) bool {
+	if input.Contains != "" && !strings.Contains(body, input.Contains) {
+		return false
+	}
+	if input.MatchPath != "" && !sqsJSONPathMatch(body, input.MatchPath, input.MatchValue) {
+		return false
+	}
+	return true
+}
+
+// sqsJSONPathMatch reports whether the json body has value at a path like
+// .detail.items.0.type, where strings compare by value and anything else
+// compares by its json encoding.
+func sqsJSONPathMatch(body, path, value string) bool {
+	var current any
+	err := json.Unmarshal([]byte(body), &current)
+	if err != nil {
+		return false
+	}
+	for _, key := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		if key == "" {
+			continue
+		}
+		switch v := current.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return false
+			}
+			current = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return false
+			}
+			current = v[i]
+		default:
+			return false
+		}
+	}
+	s, ok := current.(string)
+	if ok {
+		return s == value
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return false
+	}
+	return string(data) == value
+}
+
+// SQSRedrive moves messages from source to destination. Without filters it
+// uses a sqs message move task, which requires source to be a dead-letter
+// queue, and otherwise falls back to receive, send and delete. Messages
+// skipped by filters stay in source.
+func SQSRedrive(ctx context.Context, input *SQSRedriveInput) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SQSRedrive"}
+		d.Start()
+		defer d.End()
+	}
+	if !input.filtered() {
+		err := sqsRedriveMoveTask(ctx, input)
+		if err == nil {
+			return nil
+		}
+		if !sqsMoveTaskUnavailable(err) {
+			Logger.Println("error:", err)
+			return err
+		}
+		Logger.Println("message move task unavailable, falling back to receive, send and delete:", err)
+	}
+	return sqsRedriveManual(ctx, input)
+}
+
+// sqsMoveTaskUnavailableCodes are the error codes with which StartMessageMoveTask
+// refuses a source, such as one which is not a dead-letter queue, or a caller
+// which is not allowed to start move tasks
+var sqsMoveTaskUnavailableCodes = []string{
+	"AccessDenied",
+	"AccessDeniedException",
+	"AWS.SimpleQueueService.UnsupportedOperation",
+	"InvalidSecurity",
+	"UnsupportedOperation",
+}
+
+// sqsMoveTaskUnavailable reports whether a move task could not be started at
+// all, as opposed to a task which started and then failed partway
+func sqsMoveTaskUnavailable(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return slices.Contains(sqsMoveTaskUnavailableCodes, apiErr.ErrorCode())
+}
+
+func sqsRedriveMoveTask(ctx context.Context, input *SQSRedriveInput) error {
+	sourceArn, err := SQSArn(ctx, input.Source)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	destinationArn, err := SQSArn(ctx, input.Destination)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	start := &sqs.StartMessageMoveTaskInput{
+		SourceArn:      aws.String(sourceArn),
+		DestinationArn: aws.String(destinationArn),
+	}
+	if input.Rate != 0 {
+		start.MaxNumberOfMessagesPerSecond = aws.Int32(int32(input.Rate))
+	}
+	_, err = SQSClient().StartMessageMoveTask(ctx, start)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	Logger.Println("started message move task:", input.Source, "=>", input.Destination)
+	for {
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+			Logger.Println("error:", ctx.Err())
+			return ctx.Err()
+		}
+		out, err := SQSClient().ListMessageMoveTasks(ctx, &sqs.ListMessageMoveTasksInput{
+			SourceArn:  aws.String(sourceArn),
+			MaxResults: aws.Int32(1),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		if len(out.Results) == 0 {
+			err := fmt.Errorf("no message move task found for: %s", input.Source)
+			Logger.Println("error:", err)
+			return err
+		}
+		task := out.Results[0]
+		Logger.Printf("moved: %d/%d status: %s\n", task.ApproximateNumberOfMessagesMoved, aws.ToInt64(task.ApproximateNumberOfMessagesToMove), aws.ToString(task.Status))
+		switch aws.ToString(task.Status) {
+		case "COMPLETED":
+			return nil
+		case "FAILED", "CANCELLED":
+			err := fmt.Errorf("message move task %s: %s", strings.ToLower(aws.ToString(task.Status)), aws.ToString(task.FailureReason))
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+}
+
+func sqsRedriveManual(ctx context.Context, input *SQSRedriveInput) error {
+	sourceUrl, err := SQSQueueUrl(ctx, input.Source)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	destinationUrl, err := SQSQueueUrl(ctx, input.Destination)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	fifo := strings.HasSuffix(input.Destination, sqsFifoSuffix)
+	moved := 0
+	skipped := 0
+	empty := 0 // consecutive receives with no new messages
+	seen := map[string]bool{}
+	for {
+		started := time.Now()
+		maxMessages := SQSBatchSize
+		if input.Rate != 0 {
+			maxMessages = min(maxMessages, input.Rate)
+		}
+		messages, err := SQSReceive(ctx, &SQSReceiveInput{
+			QueueUrl:          sourceUrl,
+			MaxMessages:       maxMessages,
+			WaitSeconds:       1,
+			VisibilityTimeout: input.VisibilityTimeout,
+			Attributes:        true,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		unseen := 0
+		var entries []SQSSendEntry
+		var matched []sqstypes.Message
+		for _, message := range messages {
+			if seen[*message.MessageId] {
+				continue
+			}
+			seen[*message.MessageId] = true
+			unseen++
+			if !input.match(aws.ToString(message.Body)) {
+				skipped++
+				continue
+			}
+			entry := SQSSendEntry{
+				Body:              aws.ToString(message.Body),
+				MessageAttributes: message.MessageAttributes,
+			}
+			if fifo {
+				entry.GroupID = message.Attributes[string(sqstypes.MessageSystemAttributeNameMessageGroupId)]
+				entry.DeduplicationID = message.Attributes[string(sqstypes.MessageSystemAttributeNameMessageDeduplicationId)]
+				if entry.GroupID == "" {
+					entry.GroupID = input.Source // from a standard queue
+				}
+				if entry.DeduplicationID == "" {
+					entry.DeduplicationID = *message.MessageId
+				}
+			}
+			entries = append(entries, entry)
+			matched = append(matched, message)
+		}
+		if unseen == 0 { // empty, or only skipped messages that became visible again
+			empty++
+			if empty >= sqsRedriveEmptyReceives {
+				break
+			}
+			// receives can come back empty while messages remain, so also check the queue
+			num, err := SQSNumMessages(ctx, sourceUrl)
+			if err != nil {
+				Logger.Println("error:", err)
+				return err
+			}
+			if num.Messages == 0 {
+				break
+			}
+			continue
+		}
+		empty = 0
+		err = SQSSendBatch(ctx, destinationUrl, entries)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		err = SQSDeleteMessages(ctx, sourceUrl, matched)
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+		moved += len(matched)
+		Logger.Printf("moved: %d skipped: %d\n", moved, skipped)
+		if input.Rate != 0 {
+			time.Sleep(time.Until(started.Add(time.Duration(len(messages)) * time.Second / time.Duration(input.Rate))))
+		}
+	}
+	Logger.Printf("done, moved: %d skipped: %d\n", moved, skipped)
+	return nil
+}
+
+// SQSEnsurePolicyAllows ensures the queue policy allows a service like
+// sns.amazonaws.com to send messages from a source arn, keeping other statements
+func SQSEnsurePolicyAllows(ctx context.Context, queueName, service, sourceArn string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SQSEnsurePolicyAllows"}
+		d.Start()
+		defer d.End()
+	}
+	queueArn, err := SQSArn(ctx, queueName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	statement := map[string]any{
+		"Sid":       iamServicePolicySid(service, sourceArn),
+		"Effect":    "Allow",
+		"Principal": map[string]any{"Service": service},
+		"Action":    "sqs:SendMessage",
+		"Resource":  queueArn,
+		"Condition": map[string]any{"ArnEquals": map[string]any{"aws:SourceArn": sourceArn}},
+	}
+	return sqsEnsurePolicyStatement(ctx, queueName, iamServicePolicySid(service, sourceArn), statement, preview)
+}
+
+// SQSRemovePolicyAllows removes a statement added by SQSEnsurePolicyAllows
+func SQSRemovePolicyAllows(ctx context.Context, queueName, service, sourceArn string, preview bool) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SQSRemovePolicyAllows"}
+		d.Start()
+		defer d.End()
+	}
+	return sqsEnsurePolicyStatement(ctx, queueName, iamServicePolicySid(service, sourceArn), nil, preview)
+}
+
+// sqsEnsurePolicyStatement sets or, if statement is nil, removes the policy statement with sid
+func sqsEnsurePolicyStatement(ctx context.Context, queueName, sid string, statement map[string]any, preview bool) error {
+	queueUrl, err := SQSQueueUrl(ctx, queueName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	out, err := SQSClient().GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueUrl),
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNamePolicy},
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "AWS.SimpleQueueService.NonExistentQueue") {
+			if statement == nil {
+				return nil
+			}
+			if preview {
+				Logger.Println(PreviewString(preview)+"updated queue policy statement:", queueName, sid)
+				return nil
+			}
+		}
+		Logger.Println("error:", err)
+		return err
+	}
+	policy, changed, err := iamPolicyWithStatement(out.Attributes["Policy"], sid, statement)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	attrs := map[string]string{"Policy": policy}
+	if !preview {
+		_, err := SQSClient().SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+			QueueUrl:   aws.String(queueUrl),
+			Attributes: attrs,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	if statement == nil {
+		Logger.Println(PreviewString(preview)+"removed queue policy statement:", queueName, sid)
+	} else {
+		Logger.Println(PreviewString(preview)+"updated queue policy statement:", queueName, sid)
+	}
+	return nil
+}
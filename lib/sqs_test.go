@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
 	"github.com/gofrs/uuid"
 )
 
@@ -413,3 +417,225 @@ func TestSQSEnsureVisibilityTimeout(t *testing.T) {
 		return
 	}
 }
+
+func TestSQSEnsureOrder(t *testing.T) {
+	attrs := map[string][]string{
+		"a":      {"dlq=b"},
+		"b":      {"dlq=c", "maxreceive=2"},
+		"c":      {},
+		"d.fifo": {"contentdedup=true", "dlq=e.fifo"},
+		"e.fifo": {"highthroughput=true"},
+	}
+	inputs := map[string]*sqsEnsureInput{}
+	for name, attr := range attrs {
+		input, err := SQSEnsureInput("", name, attr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[name] = input
+	}
+	if inputs["a"].maxReceiveCount != sqsDefaultMaxReceiveCount || inputs["b"].maxReceiveCount != 2 {
+		t.Errorf("\nbad max receive count: %d %d", inputs["a"].maxReceiveCount, inputs["b"].maxReceiveCount)
+	}
+	order, err := sqsEnsureOrder(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"c", "b", "a", "e.fifo", "d.fifo"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", order, expected)
+	}
+	inputs["c"].deadLetterQueue = "a"
+	_, err = sqsEnsureOrder(inputs)
+	if err == nil {
+		t.Errorf("\nexpected cycle error")
+	}
+	for name, attr := range map[string][]string{
+		"q":      {"contentdedup=true"},
+		"q.fifo": {"dlq=standard"},
+		"r":      {"dlq=r"},
+		"s":      {"maxreceive=3"},
+	} {
+		_, err := SQSEnsureInput("", name, attr)
+		if err == nil {
+			t.Errorf("\nexpected error for: %s %v", name, attr)
+		}
+	}
+}
+
+func TestSQSValidatePublisher(t *testing.T) {
+	type test struct {
+		attrs  []string
+		kmsKey string
+		err    bool
+	}
+	tests := []test{
+		{nil, sqsKmsKeyDefault, true},
+		{[]string{"kms=alias/aws/sqs"}, sqsKmsKeyDefault, true},
+		{[]string{"kms=none"}, "", false},
+		{[]string{"KmsMasterKeyId=alias/my-key"}, "alias/my-key", false},
+	}
+	for _, test := range tests {
+		input, err := SQSEnsureInput("", "test-queue", test.attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if input.Attrs()["KmsMasterKeyId"] != test.kmsKey {
+			t.Errorf("\nattrs: %v\nexpected kms key: %q\ngot: %q\n", test.attrs, test.kmsKey, input.Attrs()["KmsMasterKeyId"])
+		}
+		if (input.Attrs()["SqsManagedSseEnabled"] == "true") != (test.kmsKey == "") {
+			t.Errorf("\nattrs: %v\nexpected sqs managed encryption only without a kms key, got: %v\n", test.attrs, input.Attrs())
+		}
+		err = sqsValidatePublisher(input, "s3")
+		if (err != nil) != test.err {
+			t.Errorf("\nattrs: %v\nexpected err: %v\ngot: %v\n", test.attrs, test.err, err)
+		}
+	}
+	_, err := SQSEnsureInput("", "test-queue", []string{"kms="})
+	if err == nil {
+		t.Errorf("\nexpected error for empty kms key")
+	}
+}
+
+func TestSQSSendChunks(t *testing.T) {
+	entry := func(size int) SQSSendEntry {
+		return SQSSendEntry{Body: strings.Repeat("a", size)}
+	}
+	type test struct {
+		entries  []SQSSendEntry
+		expected []int
+	}
+	tests := []test{
+		{nil, nil},
+		{[]SQSSendEntry{entry(1)}, []int{1}},
+		{slices.Repeat([]SQSSendEntry{entry(1)}, 25), []int{10, 10, 5}},
+		{slices.Repeat([]SQSSendEntry{entry(100 * 1024)}, 5), []int{2, 2, 1}},
+		{[]SQSSendEntry{entry(sqsBatchMaxBytes), entry(1)}, []int{1, 1}},
+		{[]SQSSendEntry{entry(sqsBatchMaxBytes + 1), entry(1), entry(1)}, []int{1, 2}},
+		{[]SQSSendEntry{entry(sqsBatchMaxBytes - 10), {Body: "", MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+			"name": {DataType: aws.String("String"), StringValue: aws.String("value")},
+		}}, entry(1)}, []int{1, 2}}, // attributes count towards the batch size
+	}
+	for _, test := range tests {
+		var sizes []int
+		for _, chunk := range sqsSendChunks(test.entries) {
+			sizes = append(sizes, len(chunk))
+		}
+		if !reflect.DeepEqual(sizes, test.expected) {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\n", sizes, test.expected)
+		}
+	}
+}
+
+type sqsFakeSendClient struct {
+	calls  [][]string
+	failed func(call int, id string) *sqstypes.BatchResultErrorEntry
+}
+
+func (c *sqsFakeSendClient) SendMessageBatch(ctx context.Context, input *sqs.SendMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageBatchOutput, error) {
+	var bodies []string
+	out := &sqs.SendMessageBatchOutput{}
+	for _, entry := range input.Entries {
+		bodies = append(bodies, *entry.MessageBody)
+		if c.failed != nil {
+			result := c.failed(len(c.calls), *entry.Id)
+			if result != nil {
+				out.Failed = append(out.Failed, *result)
+			}
+		}
+	}
+	c.calls = append(c.calls, bodies)
+	return out, nil
+}
+
+func TestSQSSendBatch(t *testing.T) {
+	var entries []SQSSendEntry
+	for i := range 12 {
+		entries = append(entries, SQSSendEntry{Body: fmt.Sprint(i)})
+	}
+	client := &sqsFakeSendClient{
+		failed: func(call int, id string) *sqstypes.BatchResultErrorEntry {
+			if call == 0 && id == "3" {
+				return &sqstypes.BatchResultErrorEntry{Id: aws.String(id), Code: aws.String("InternalError")}
+			}
+			return nil
+		},
+	}
+	err := sqsSendBatch(context.Background(), client, "url", entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+		{"3"},
+		{"10", "11"},
+	}
+	if !reflect.DeepEqual(client.calls, expected) {
+		t.Errorf("\ngot:\n%v\nwant:\n%v\n", client.calls, expected)
+	}
+	client = &sqsFakeSendClient{
+		failed: func(call int, id string) *sqstypes.BatchResultErrorEntry {
+			if id == "1" {
+				return &sqstypes.BatchResultErrorEntry{Id: aws.String(id), Code: aws.String("InvalidMessageContents"), SenderFault: true}
+			}
+			return nil
+		},
+	}
+	err = sqsSendBatch(context.Background(), client, "url", entries)
+	if err == nil {
+		t.Errorf("\nexpected error for sender fault")
+	}
+	if len(client.calls) != 1 {
+		t.Errorf("\nexpected no retry for sender fault, got calls: %v", client.calls)
+	}
+}
+
+func TestSQSMoveTaskUnavailable(t *testing.T) {
+	type test struct {
+		err         error
+		unavailable bool
+	}
+	tests := []test{
+		{&sqstypes.UnsupportedOperation{Message: aws.String("source queue is not a dead-letter queue")}, true},
+		{fmt.Errorf("wrapped: %w", &sqstypes.InvalidSecurity{}), true},
+		{&smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform sqs:StartMessageMoveTask"}, true},
+		{fmt.Errorf("wrapped: %w", &smithy.GenericAPIError{Code: "AWS.SimpleQueueService.UnsupportedOperation"}), true},
+		{&smithy.GenericAPIError{Code: "InvalidParameterValue", Message: "Value for parameter MaxNumberOfMessagesPerSecond is invalid"}, false},
+		{&smithy.GenericAPIError{Code: "AccessDeniedLater"}, false},
+		{fmt.Errorf("message move task failed: AccessDenied"), false},
+		{&sqstypes.RequestThrottled{}, false},
+	}
+	for _, test := range tests {
+		if sqsMoveTaskUnavailable(test.err) != test.unavailable {
+			t.Errorf("\nerr: %v\nexpected unavailable: %v\n", test.err, test.unavailable)
+		}
+	}
+}
+
+func TestSQSJSONPathMatch(t *testing.T) {
+	body := `{"detail": {"type": "order", "count": 2, "items": [{"id": "a"}], "ok": true}}`
+	type test struct {
+		path  string
+		value string
+		match bool
+	}
+	tests := []test{
+		{".detail.type", "order", true},
+		{".detail.type", "refund", false},
+		{".detail.count", "2", true},
+		{".detail.ok", "true", true},
+		{".detail.items.0.id", "a", true},
+		{".detail.items.1.id", "a", false},
+		{".detail.missing", "", false},
+		{".detail", `{"count":2,"items":[{"id":"a"}],"ok":true,"type":"order"}`, true},
+	}
+	for _, test := range tests {
+		match := sqsJSONPathMatch(body, test.path, test.value)
+		if match != test.match {
+			t.Errorf("\ngot:\n%v\nwant:\n%v\nfor: %s=%s", match, test.match, test.path, test.value)
+		}
+	}
+	if sqsJSONPathMatch("not json", ".a", "b") {
+		t.Errorf("\nexpected no match for invalid json")
+	}
+}
@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SQSWorker long polls a queue and runs Handler for each message with bounded
+// concurrency. While a handler runs its message visibility is extended, on
+// success the message is deleted, and on error it becomes visible again after
+// VisibilityTimeout to be retried or moved to a dead-letter queue. Receive
+// errors are logged and retried with backoff.
+//
+// When the context passed to Run is cancelled, for example by SignalHandler,
+// the worker stops receiving and waits for in progress handlers to finish. The
+// context passed to Handler is not cancelled by shutdown.
+type SQSWorker struct {
+	QueueName         string
+	Handler           func(ctx context.Context, message sqstypes.Message) error
+	Concurrency       int // default: 1
+	VisibilityTimeout int // seconds, extended every half timeout while a handler runs, default: 30
+	WaitSeconds       int // long polling, default: 20
+
+	// optional hooks for metrics
+	OnReceive func(count int)
+	OnSuccess func(message sqstypes.Message, duration time.Duration)
+	OnFailure func(message sqstypes.Message, duration time.Duration, err error)
+}
+
+// sqsWorkerAPI is the part of the sqs client used by SQSWorker
+type sqsWorkerAPI interface {
+	sqsReceiveMessageAPI
+	sqsDeleteMessageBatchAPI
+	ChangeMessageVisibility(ctx context.Context, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+func (w *SQSWorker) Run(ctx context.Context) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "SQSWorker.Run"}
+		d.Start()
+		defer d.End()
+	}
+	if w.Handler == nil {
+		err := fmt.Errorf("sqs worker needs a handler: %s", w.QueueName)
+		Logger.Println("error:", err)
+		return err
+	}
+	url, err := SQSQueueUrl(ctx, w.QueueName)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	return w.run(ctx, SQSClient(), url)
+}
+
+func (w *SQSWorker) run(ctx context.Context, client sqsWorkerAPI, url string) error {
+	concurrency := w.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	visibilityTimeout := w.VisibilityTimeout
+	if visibilityTimeout <= 0 {
+		visibilityTimeout = 30
+	}
+	waitSeconds := w.WaitSeconds
+	if waitSeconds <= 0 {
+		waitSeconds = 20
+	}
+	workCtx := context.WithoutCancel(ctx)
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	backoff := 100 * time.Millisecond
+	for {
+		// wait for one free slot, then take any others that are free
+		select {
+		case <-ctx.Done():
+			return nil
+		case slots <- struct{}{}:
+		}
+		free := 1
+	acquire:
+		for free < min(concurrency, SQSBatchSize) {
+			select {
+			case slots <- struct{}{}:
+				free++
+			default:
+				break acquire
+			}
+		}
+		messages, err := sqsReceive(ctx, client, &SQSReceiveInput{
+			QueueUrl:          url,
+			MaxMessages:       free,
+			WaitSeconds:       waitSeconds,
+			VisibilityTimeout: visibilityTimeout,
+			Attributes:        true,
+		})
+		for range free - len(messages) {
+			<-slots
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			Logger.Println("error:", err)
+			Logger.Println("receive failed, backing off:", backoff)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(backoff/2 + rand.N(backoff)):
+			}
+			backoff = min(backoff*2, 20*time.Second)
+			continue
+		}
+		backoff = 100 * time.Millisecond
+		if len(messages) > 0 && w.OnReceive != nil {
+			w.OnReceive(len(messages))
+		}
+		for _, message := range messages {
+			wg.Add(1)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						logRecover(r)
+					}
+				}()
+				defer wg.Done()
+				defer func() { <-slots }()
+				w.process(workCtx, client, url, visibilityTimeout, message)
+			}()
+		}
+	}
+}
+
+func (w *SQSWorker) process(ctx context.Context, client sqsWorkerAPI, url string, visibilityTimeout int, message sqstypes.Message) {
+	start := time.Now()
+	done := make(chan struct{})
+	heartbeat := make(chan struct{})
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logRecover(r)
+			}
+		}()
+		defer close(heartbeat)
+		ticker := time.NewTicker(time.Duration(visibilityTimeout) * time.Second / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, err := client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+					QueueUrl:          aws.String(url),
+					ReceiptHandle:     message.ReceiptHandle,
+					VisibilityTimeout: int32(visibilityTimeout),
+				})
+				if err != nil {
+					Logger.Println("error:", err)
+				}
+			}
+		}
+	}()
+	err := w.Handler(ctx, message)
+	close(done)
+	<-heartbeat
+	if err == nil {
+		err = sqsDeleteMessages(ctx, client, url, []sqstypes.Message{message})
+	}
+	if err != nil {
+		Logger.Println("error:", aws.ToString(message.MessageId), err)
+		if w.OnFailure != nil {
+			w.OnFailure(message, time.Since(start), err)
+		}
+		return
+	}
+	if w.OnSuccess != nil {
+		w.OnSuccess(message, time.Since(start))
+	}
+}
@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// sqsFakeWorkerClient serves pending messages to receives and records every call
+type sqsFakeWorkerClient struct {
+	lock        sync.Mutex
+	pending     []sqstypes.Message
+	inflight    int // received but not yet handled
+	maxInflight int
+	receives    []int32
+	heartbeats  map[string]int
+	deleted     []string
+	failures    int // receives that fail before any succeed
+}
+
+func (c *sqsFakeWorkerClient) ReceiveMessage(ctx context.Context, input *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	c.lock.Lock()
+	c.receives = append(c.receives, input.MaxNumberOfMessages)
+	if c.failures > 0 {
+		c.failures--
+		c.lock.Unlock()
+		return nil, fmt.Errorf("throttled")
+	}
+	n := min(int(input.MaxNumberOfMessages), len(c.pending))
+	messages := c.pending[:n]
+	c.pending = c.pending[n:]
+	c.inflight += n
+	c.maxInflight = max(c.maxInflight, c.inflight)
+	c.lock.Unlock()
+	if n == 0 {
+		select { // an empty long poll
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (c *sqsFakeWorkerClient) ChangeMessageVisibility(ctx context.Context, input *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.heartbeats[*input.ReceiptHandle]++
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (c *sqsFakeWorkerClient) DeleteMessageBatch(ctx context.Context, input *sqs.DeleteMessageBatchInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageBatchOutput, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, entry := range input.Entries {
+		c.deleted = append(c.deleted, *entry.ReceiptHandle)
+	}
+	return &sqs.DeleteMessageBatchOutput{}, nil
+}
+
+func (c *sqsFakeWorkerClient) handled() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.inflight--
+}
+
+func sqsFakeMessages(n int) []sqstypes.Message {
+	var messages []sqstypes.Message
+	for i := range n {
+		messages = append(messages, sqstypes.Message{
+			MessageId:     aws.String(fmt.Sprint(i)),
+			ReceiptHandle: aws.String(fmt.Sprint("handle-", i)),
+			Body:          aws.String(fmt.Sprint("body-", i)),
+		})
+	}
+	return messages
+}
+
+// sqsRunFakeWorker runs the worker until every message has been handled
+func sqsRunFakeWorker(t *testing.T, w *SQSWorker, client *sqsFakeWorkerClient) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remaining := len(client.pending)
+	var lock sync.Mutex
+	done := func() {
+		client.handled()
+		lock.Lock()
+		defer lock.Unlock()
+		remaining--
+		if remaining == 0 {
+			cancel()
+		}
+	}
+	onSuccess := w.OnSuccess
+	w.OnSuccess = func(message sqstypes.Message, duration time.Duration) {
+		if onSuccess != nil {
+			onSuccess(message, duration)
+		}
+		done()
+	}
+	onFailure := w.OnFailure
+	w.OnFailure = func(message sqstypes.Message, duration time.Duration, err error) {
+		if onFailure != nil {
+			onFailure(message, duration, err)
+		}
+		done()
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- w.run(ctx, client, "url")
+	}()
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for worker")
+	}
+}
+
+func TestSQSWorkerSlots(t *testing.T) {
+	type test struct {
+		concurrency int
+		messages    int
+	}
+	tests := []test{
+		{1, 5},
+		{3, 7},
+		{25, 40},
+	}
+	for _, test := range tests {
+		client := &sqsFakeWorkerClient{pending: sqsFakeMessages(test.messages), heartbeats: map[string]int{}}
+		var lock sync.Mutex
+		active := 0
+		maxActive := 0
+		w := &SQSWorker{
+			Concurrency: test.concurrency,
+			Handler: func(ctx context.Context, message sqstypes.Message) error {
+				lock.Lock()
+				active++
+				maxActive = max(maxActive, active)
+				lock.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				lock.Lock()
+				active--
+				lock.Unlock()
+				return nil
+			},
+		}
+		sqsRunFakeWorker(t, w, client)
+		if client.maxInflight > test.concurrency {
+			t.Errorf("\nconcurrency: %d\nreceived more messages than free slots: %d", test.concurrency, client.maxInflight)
+		}
+		if maxActive > test.concurrency {
+			t.Errorf("\nconcurrency: %d\nran too many handlers: %d", test.concurrency, maxActive)
+		}
+		for _, n := range client.receives {
+			if n < 1 || n > int32(min(test.concurrency, SQSBatchSize)) {
+				t.Errorf("\nconcurrency: %d\nbad max messages for receive: %d", test.concurrency, n)
+			}
+		}
+		if len(client.deleted) != test.messages {
+			t.Errorf("\nconcurrency: %d\nexpected %d deletes, got: %d", test.concurrency, test.messages, len(client.deleted))
+		}
+	}
+}
+
+func TestSQSWorkerReceiveErrors(t *testing.T) {
+	client := &sqsFakeWorkerClient{pending: sqsFakeMessages(3), heartbeats: map[string]int{}, failures: 3}
+	handled := 0
+	w := &SQSWorker{
+		Handler: func(ctx context.Context, message sqstypes.Message) error {
+			handled++
+			return nil
+		},
+	}
+	sqsRunFakeWorker(t, w, client)
+	if handled != 3 {
+		t.Errorf("\nexpected the worker to keep receiving after errors and handle 3 messages, got: %d", handled)
+	}
+	if len(client.receives) < 4 {
+		t.Errorf("\nexpected at least 4 receives, got: %d", len(client.receives))
+	}
+}
+
+func TestSQSWorkerHeartbeat(t *testing.T) {
+	client := &sqsFakeWorkerClient{pending: sqsFakeMessages(2), heartbeats: map[string]int{}}
+	w := &SQSWorker{
+		Concurrency:       2,
+		VisibilityTimeout: 1,
+		Handler: func(ctx context.Context, message sqstypes.Message) error {
+			if *message.MessageId == "0" {
+				time.Sleep(1300 * time.Millisecond) // heartbeats at 500ms and 1000ms
+			}
+			return nil
+		},
+	}
+	sqsRunFakeWorker(t, w, client)
+	if client.heartbeats["handle-0"] < 2 {
+		t.Errorf("\nexpected at least 2 heartbeats for a slow handler, got: %d", client.heartbeats["handle-0"])
+	}
+	if client.heartbeats["handle-1"] != 0 {
+		t.Errorf("\nexpected no heartbeats for a fast handler, got: %d", client.heartbeats["handle-1"])
+	}
+}
+
+func TestSQSWorkerDeleteOnSuccess(t *testing.T) {
+	client := &sqsFakeWorkerClient{pending: sqsFakeMessages(6), heartbeats: map[string]int{}}
+	var lock sync.Mutex
+	var succeeded []string
+	var failed []string
+	w := &SQSWorker{
+		Concurrency: 4,
+		Handler: func(ctx context.Context, message sqstypes.Message) error {
+			if Atoi(*message.MessageId)%2 == 1 {
+				return fmt.Errorf("failed: %s", *message.MessageId)
+			}
+			return nil
+		},
+		OnSuccess: func(message sqstypes.Message, duration time.Duration) {
+			lock.Lock()
+			defer lock.Unlock()
+			succeeded = append(succeeded, *message.ReceiptHandle)
+		},
+		OnFailure: func(message sqstypes.Message, duration time.Duration, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			failed = append(failed, *message.ReceiptHandle)
+		},
+	}
+	sqsRunFakeWorker(t, w, client)
+	slices.Sort(client.deleted)
+	slices.Sort(succeeded)
+	slices.Sort(failed)
+	expected := []string{"handle-0", "handle-2", "handle-4"}
+	if !slices.Equal(client.deleted, expected) || !slices.Equal(succeeded, expected) {
+		t.Errorf("\nexpected deleted and succeeded: %v\ngot deleted: %v\ngot succeeded: %v", expected, client.deleted, succeeded)
+	}
+	if !slices.Equal(failed, []string{"handle-1", "handle-3", "handle-5"}) {
+		t.Errorf("\nunexpected failures: %v", failed)
+	}
+}
@@ -0,0 +1,372 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	apimgmttypes "github.com/aws/aws-sdk-go-v2/service/apigatewaymanagementapi/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// websocket connections are tracked in a dynamodb table from the infraset with keys:
+//
+//	channel:s:hash
+//	connection_id:s:range
+//
+// every connection has a row in WebsocketChannelAll, which also records the
+// other channels it has joined, so disconnect can remove all of its rows.
+
+const (
+	WebsocketChannelAll = "$all"
+
+	websocketAttrChannel      = "channel"
+	websocketAttrConnectionID = "connection_id"
+	websocketAttrChannels     = "channels"
+	websocketAttrConnectedAt  = "connected_at"
+
+	websocketBroadcastConcurrencyDefault = 32
+)
+
+// WebsocketUserChannel is the channel used to send to every connection of a user
+func WebsocketUserChannel(userID string) string {
+	return "user:" + userID
+}
+
+func websocketKey(channel, connectionID string) map[string]ddbtypes.AttributeValue {
+	return map[string]ddbtypes.AttributeValue{
+		websocketAttrChannel:      &ddbtypes.AttributeValueMemberS{Value: channel},
+		websocketAttrConnectionID: &ddbtypes.AttributeValueMemberS{Value: connectionID},
+	}
+}
+
+func websocketValidateChannels(channels []string) error {
+	for _, channel := range channels {
+		if channel == "" || channel == WebsocketChannelAll {
+			err := fmt.Errorf("invalid websocket channel: %q", channel)
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// WebsocketConnect records a new connection, optionally joining channels
+func WebsocketConnect(ctx context.Context, table, connectionID string, channels ...string) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "WebsocketConnect"}
+		d.Start()
+		defer d.End()
+	}
+	err := websocketValidateChannels(channels)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	item := websocketKey(WebsocketChannelAll, connectionID)
+	item[websocketAttrConnectedAt] = &ddbtypes.AttributeValueMemberN{Value: fmt.Sprint(time.Now().Unix())}
+	if len(channels) > 0 {
+		item[websocketAttrChannels] = &ddbtypes.AttributeValueMemberSS{Value: channels}
+	}
+	_, err = DynamoDBClient().PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      item,
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, channel := range channels {
+		_, err := DynamoDBClient().PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(table),
+			Item:      websocketKey(channel, connectionID),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// WebsocketJoin adds a connected connection to channels
+func WebsocketJoin(ctx context.Context, table, connectionID string, channels ...string) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "WebsocketJoin"}
+		d.Start()
+		defer d.End()
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+	err := websocketValidateChannels(channels)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	_, err = DynamoDBClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(table),
+		Key:                 websocketKey(WebsocketChannelAll, connectionID),
+		UpdateExpression:    aws.String("ADD #channels :channels"),
+		ConditionExpression: aws.String("attribute_exists(#connection_id)"), // never resurrect a disconnected connection
+		ExpressionAttributeNames: map[string]string{
+			"#channels":      websocketAttrChannels,
+			"#connection_id": websocketAttrConnectionID,
+		},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":channels": &ddbtypes.AttributeValueMemberSS{Value: channels},
+		},
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, channel := range channels {
+		_, err := DynamoDBClient().PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(table),
+			Item:      websocketKey(channel, connectionID),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// WebsocketLeave removes a connection from channels
+func WebsocketLeave(ctx context.Context, table, connectionID string, channels ...string) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "WebsocketLeave"}
+		d.Start()
+		defer d.End()
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+	err := websocketValidateChannels(channels)
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	for _, channel := range channels {
+		_, err := DynamoDBClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(table),
+			Key:       websocketKey(channel, connectionID),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	_, err = DynamoDBClient().UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(table),
+		Key:              websocketKey(WebsocketChannelAll, connectionID),
+		UpdateExpression: aws.String("DELETE #channels :channels"),
+		ExpressionAttributeNames: map[string]string{
+			"#channels": websocketAttrChannels,
+		},
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":channels": &ddbtypes.AttributeValueMemberSS{Value: channels},
+		},
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	return nil
+}
+
+// WebsocketDisconnect removes a connection from every channel it joined
+func WebsocketDisconnect(ctx context.Context, table, connectionID string) error {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "WebsocketDisconnect"}
+		d.Start()
+		defer d.End()
+	}
+	out, err := DynamoDBClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:    aws.String(table),
+		Key:          websocketKey(WebsocketChannelAll, connectionID),
+		ReturnValues: ddbtypes.ReturnValueAllOld,
+	})
+	if err != nil {
+		Logger.Println("error:", err)
+		return err
+	}
+	channels, ok := out.Attributes[websocketAttrChannels].(*ddbtypes.AttributeValueMemberSS)
+	if !ok {
+		return nil
+	}
+	for _, channel := range channels.Value {
+		_, err := DynamoDBClient().DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(table),
+			Key:       websocketKey(channel, connectionID),
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// WebsocketAuthorizerUserID returns the principalId set by a lambda authorizer
+// on $connect, or empty if there is none
+func WebsocketAuthorizerUserID(event *events.APIGatewayWebsocketProxyRequest) string {
+	authorizer, ok := event.RequestContext.Authorizer.(map[string]any)
+	if !ok {
+		return ""
+	}
+	userID, _ := authorizer["principalId"].(string)
+	return userID
+}
+
+// websocketConnectChannels returns the channels joined on $connect, which are
+// channels plus the user channel of the authorizer principalId if any
+func websocketConnectChannels(event *events.APIGatewayWebsocketProxyRequest, channels []string) []string {
+	channels = slices.Clone(channels)
+	userID := WebsocketAuthorizerUserID(event)
+	if userID != "" && !slices.Contains(channels, WebsocketUserChannel(userID)) {
+		channels = append(channels, WebsocketUserChannel(userID))
+	}
+	return channels
+}
+
+// WebsocketHandleEvent records $connect and $disconnect events, returning
+// true if the event was one of them. on $connect the connection joins
+// channels, and the user channel of the authorizer principalId if any.
+func WebsocketHandleEvent(ctx context.Context, table string, event *events.APIGatewayWebsocketProxyRequest, channels ...string) (bool, error) {
+	switch event.RequestContext.RouteKey {
+	case lambdaDollarConnect:
+		err := WebsocketConnect(ctx, table, event.RequestContext.ConnectionID, websocketConnectChannels(event, channels)...)
+		if err != nil {
+			Logger.Println("error:", err)
+			return true, err
+		}
+		return true, nil
+	case lambdaDollarDisconnect:
+		err := WebsocketDisconnect(ctx, table, event.RequestContext.ConnectionID)
+		if err != nil {
+			Logger.Println("error:", err)
+			return true, err
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// WebsocketConnections lists the connection ids in a channel, use
+// WebsocketChannelAll for every connection
+func WebsocketConnections(ctx context.Context, table, channel string) ([]string, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "WebsocketConnections"}
+		d.Start()
+		defer d.End()
+	}
+	var connectionIDs []string
+	var start map[string]ddbtypes.AttributeValue
+	for {
+		out, err := DynamoDBClient().Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(table),
+			KeyConditionExpression: aws.String("#channel = :channel"),
+			ProjectionExpression:   aws.String("#connection_id"),
+			ExpressionAttributeNames: map[string]string{
+				"#channel":       websocketAttrChannel,
+				"#connection_id": websocketAttrConnectionID,
+			},
+			ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+				":channel": &ddbtypes.AttributeValueMemberS{Value: channel},
+			},
+			ExclusiveStartKey: start,
+		})
+		if err != nil {
+			Logger.Println("error:", err)
+			return nil, err
+		}
+		for _, item := range out.Items {
+			connectionID, ok := item[websocketAttrConnectionID].(*ddbtypes.AttributeValueMemberS)
+			if ok {
+				connectionIDs = append(connectionIDs, connectionID.Value)
+			}
+		}
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		start = out.LastEvaluatedKey
+	}
+	return connectionIDs, nil
+}
+
+type WebsocketBroadcastResult struct {
+	Sent int
+	Gone int
+}
+
+// WebsocketBroadcast sends data to every connection in a channel concurrently,
+// removing connections that are gone. the first unexpected error is returned
+// after all sends complete.
+func WebsocketBroadcast(ctx context.Context, table, domain, channel string, data []byte, concurrency int) (*WebsocketBroadcastResult, error) {
+	if doDebug {
+		d := &Debug{start: time.Now(), name: "WebsocketBroadcast"}
+		d.Start()
+		defer d.End()
+	}
+	if concurrency <= 0 {
+		concurrency = websocketBroadcastConcurrencyDefault
+	}
+	connectionIDs, err := WebsocketConnections(ctx, table, channel)
+	if err != nil {
+		Logger.Println("error:", err)
+		return nil, err
+	}
+	result := &WebsocketBroadcastResult{}
+	var firstErr error
+	lock := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	sem := make(chan struct{}, concurrency)
+	for _, connectionID := range connectionIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logRecover(r)
+				}
+			}()
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := ApiWebsocketSend(ctx, domain, connectionID, data)
+			var gone *apimgmttypes.GoneException
+			if errors.As(err, &gone) {
+				err = WebsocketDisconnect(ctx, table, connectionID)
+				lock.Lock()
+				result.Gone++
+				lock.Unlock()
+			} else if err == nil {
+				lock.Lock()
+				result.Sent++
+				lock.Unlock()
+			}
+			if err != nil {
+				lock.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		Logger.Println("error:", firstErr)
+		return result, firstErr
+	}
+	return result, nil
+}
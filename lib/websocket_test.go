@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWebsocketValidateChannels(t *testing.T) {
+	type test struct {
+		channels []string
+		err      bool
+	}
+	tests := []test{
+		{nil, false},
+		{[]string{"room:1", WebsocketUserChannel("jane")}, false},
+		{[]string{""}, true},
+		{[]string{"room:1", WebsocketChannelAll}, true},
+	}
+	for _, test := range tests {
+		err := websocketValidateChannels(test.channels)
+		if test.err != (err != nil) {
+			t.Errorf("\nexpected error %v for: %v, got: %v", test.err, test.channels, err)
+		}
+	}
+}
+
+func TestWebsocketConnectChannels(t *testing.T) {
+	type test struct {
+		authorizer any
+		channels   []string
+		userID     string
+		output     []string
+	}
+	tests := []test{
+		{nil, nil, "", nil},
+		{nil, []string{"room:1"}, "", []string{"room:1"}},
+		{map[string]any{"principalId": "jane"}, nil, "jane", []string{"user:jane"}},
+		{map[string]any{"principalId": "jane", "role": "admin"}, []string{"room:1"}, "jane", []string{"room:1", "user:jane"}},
+		{map[string]any{"principalId": "jane"}, []string{"user:jane"}, "jane", []string{"user:jane"}},
+		{map[string]any{"principalId": 123}, []string{"room:1"}, "", []string{"room:1"}},
+		{"unexpected", []string{"room:1"}, "", []string{"room:1"}},
+	}
+	for _, test := range tests {
+		event := &events.APIGatewayWebsocketProxyRequest{
+			RequestContext: events.APIGatewayWebsocketProxyRequestContext{
+				RouteKey:   lambdaDollarConnect,
+				Authorizer: test.authorizer,
+			},
+		}
+		userID := WebsocketAuthorizerUserID(event)
+		output := websocketConnectChannels(event, test.channels)
+		if userID != test.userID || !reflect.DeepEqual(output, test.output) {
+			t.Errorf("\ngot:\n%s %v\nwant:\n%s %v\n", userID, output, test.userID, test.output)
+		}
+	}
+}
+
+func TestApiWebsocketApi(t *testing.T) {
+	a := apiWebsocketApi("a.example.com")
+	if a != apiWebsocketApi("a.example.com") {
+		t.Errorf("\nexpected one client per domain")
+	}
+	if a == apiWebsocketApi("b.example.com") {
+		t.Errorf("\nexpected a client for each domain")
+	}
+}
@@ -22,12 +22,14 @@ import (
 	_ "github.com/nathants/libaws/cmd/events"
 	_ "github.com/nathants/libaws/cmd/iam"
 	_ "github.com/nathants/libaws/cmd/infra"
+	_ "github.com/nathants/libaws/cmd/kinesis"
 	_ "github.com/nathants/libaws/cmd/lambda"
 	_ "github.com/nathants/libaws/cmd/logs"
 	_ "github.com/nathants/libaws/cmd/organizations"
 	_ "github.com/nathants/libaws/cmd/route53"
 	_ "github.com/nathants/libaws/cmd/s3"
 	_ "github.com/nathants/libaws/cmd/ses"
+	_ "github.com/nathants/libaws/cmd/sns"
 	_ "github.com/nathants/libaws/cmd/sqs"
 	_ "github.com/nathants/libaws/cmd/ssh"
 	_ "github.com/nathants/libaws/cmd/vpc"